@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"iot-agriculture-backend/internal/models"
+	"iot-agriculture-backend/internal/services"
+)
+
+// maxRuleBodyBytes limits the size of alert rule request bodies
+const maxRuleBodyBytes = 64 << 10
+
+// AlertsHandler handles alert and alert rule requests
+type AlertsHandler struct {
+	alertService *services.AlertService
+}
+
+// NewAlertsHandler creates a new alerts handler
+func NewAlertsHandler(sensorService *services.SensorService) *AlertsHandler {
+	return &AlertsHandler{
+		alertService: sensorService.GetAlertService(),
+	}
+}
+
+// Handle lists active and historical alerts
+// Supports:
+// - state: active (pending and firing, default), pending, firing, resolved or all
+// - Filtering by greenhouse_id and/or node_id
+// - limit on the number of alerts returned
+func (h *AlertsHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	greenhouseID := r.URL.Query().Get("greenhouse_id")
+	nodeID := r.URL.Query().Get("node_id")
+	limit, err := parseLimit(r, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var alerts []models.Alert
+	state := r.URL.Query().Get("state")
+	switch state {
+	case "", "active":
+		alerts = h.alertService.ActiveAlerts()
+	case models.AlertPending, models.AlertFiring:
+		for _, alert := range h.alertService.ActiveAlerts() {
+			if alert.State == state {
+				alerts = append(alerts, alert)
+			}
+		}
+	case models.AlertResolved:
+		alerts = h.alertService.History()
+	case "all":
+		alerts = append(h.alertService.ActiveAlerts(), h.alertService.History()...)
+	default:
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid state: %s (expected active, pending, firing, resolved or all)", state))
+		return
+	}
+
+	results := make([]models.Alert, 0, len(alerts))
+	for _, alert := range alerts {
+		if (greenhouseID != "" && alert.GreenhouseID != greenhouseID) || (nodeID != "" && alert.NodeID != nodeID) {
+			continue
+		}
+		if len(results) == limit {
+			break
+		}
+		results = append(results, alert)
+	}
+	sendSuccess(w, results, "Alerts retrieved successfully")
+}
+
+// HandleRules lists alert rules (GET) or creates one (POST)
+func (h *AlertsHandler) HandleRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sendSuccess(w, h.alertService.ListRules(), "Alert rules retrieved successfully")
+	case http.MethodPost:
+		rule, ok := decodeRule(w, r)
+		if !ok {
+			return
+		}
+		created, err := h.alertService.CreateRule(rule)
+		if err != nil {
+			sendError(w, ruleErrorStatus(err), err.Error())
+			return
+		}
+		sendCreated(w, created, "Alert rule created")
+	default:
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// HandleRule reads (GET), replaces (PUT) or deletes (DELETE) the alert rule given by {id}
+func (h *AlertsHandler) HandleRule(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := h.alertService.GetRule(id)
+		if err != nil {
+			sendError(w, ruleErrorStatus(err), err.Error())
+			return
+		}
+		sendSuccess(w, rule, "Alert rule retrieved successfully")
+	case http.MethodPut:
+		rule, ok := decodeRule(w, r)
+		if !ok {
+			return
+		}
+		updated, err := h.alertService.UpdateRule(id, rule)
+		if err != nil {
+			sendError(w, ruleErrorStatus(err), err.Error())
+			return
+		}
+		sendSuccess(w, updated, "Alert rule updated")
+	case http.MethodDelete:
+		if err := h.alertService.DeleteRule(id); err != nil {
+			sendError(w, ruleErrorStatus(err), err.Error())
+			return
+		}
+		sendSuccess(w, nil, "Alert rule deleted")
+	default:
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// decodeRule decodes an alert rule from the request body, rejecting unknown fields
+func decodeRule(w http.ResponseWriter, r *http.Request) (models.AlertRule, bool) {
+	var rule models.AlertRule
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRuleBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rule); err != nil {
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid alert rule: %v", err))
+		return models.AlertRule{}, false
+	}
+	return rule, true
+}
+
+// ruleErrorStatus maps an alert service error to an HTTP status code
+func ruleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidRule):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrRuleNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -37,6 +37,14 @@ func NewServer(sensorService *services.SensorService, mqttClient *mqtt.Client, r
 	dbHealthHandler := NewDatabaseHealthHandler(sensorService)
 	mqttHealthHandler := NewMQTTHealthHandler(sensorService, mqttClient)
 	sensorAveragesHandler := NewSensorAveragesHandler(sensorService)
+	sensorRawHandler := NewSensorRawHandler(sensorService)
+	sensorDLIHandler := NewSensorDLIHandler(sensorService)
+	alertsHandler := NewAlertsHandler(sensorService)
+	nodesHandler := NewNodesHandler(sensorService)
+	ingestHandler := NewIngestHandler(sensorService)
+	irrigationHandler := NewIrrigationHandler(sensorService)
+	rainHandler := NewRainHandler(sensorService)
+	cropsHandler := NewCropsHandler(sensorService)
 
 	// Create monitoring middleware
 	monitoringMiddleware := MonitoringMiddleware(sensorService.GetMetricsService())
@@ -55,6 +63,22 @@ func NewServer(sensorService *services.SensorService, mqttClient *mqtt.Client, r
 	mux.HandleFunc("/sensors/averages", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(sensorAveragesHandler.Handle)))))
 	mux.HandleFunc("/sensors/averages/latest", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(sensorAveragesHandler.HandleLatest)))))
 	mux.HandleFunc("/sensors/averages/all", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(sensorAveragesHandler.HandleAll)))))
+	mux.HandleFunc("/sensors/raw", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(sensorRawHandler.Handle)))))
+	mux.HandleFunc("/sensors/dli", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(sensorDLIHandler.Handle)))))
+	mux.HandleFunc("/alerts", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(alertsHandler.Handle)))))
+	mux.HandleFunc("/alerts/rules", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(alertsHandler.HandleRules)))))
+	mux.HandleFunc("/alerts/rules/{id}", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(alertsHandler.HandleRule)))))
+	mux.HandleFunc("/nodes", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(nodesHandler.Handle)))))
+	mux.HandleFunc("/nodes/devices", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(nodesHandler.HandleDevices)))))
+	mux.HandleFunc("/nodes/events", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(nodesHandler.HandleEvents)))))
+	mux.HandleFunc("/nodes/{greenhouse_id}/{node_id}", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(nodesHandler.HandleNode)))))
+	mux.HandleFunc("/nodes/{greenhouse_id}/{node_id}/substrate", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(nodesHandler.HandleSubstrate)))))
+	mux.HandleFunc("/ingest/dead-letters", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(ingestHandler.HandleDeadLetters)))))
+	mux.HandleFunc("/ingest/dead-letters/replay", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(ingestHandler.HandleReplay)))))
+	mux.HandleFunc("/ingest/dead-letters/{id}", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(ingestHandler.HandleDeadLetter)))))
+	mux.HandleFunc("/irrigation/events", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(irrigationHandler.HandleEvents)))))
+	mux.HandleFunc("/rain/events", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(rainHandler.HandleEvents)))))
+	mux.HandleFunc("/greenhouses/{greenhouse_id}/crop", SecurityMiddleware(rateLimiter.RateLimitMiddleware(rateLimitConfig)(monitoringMiddleware(CORSMiddleware(cropsHandler.HandleCrop)))))
 
 	// Metrics endpoint (no rate limiting for Prometheus scraping)
 	mux.HandleFunc("/metrics", SecurityMiddleware(CORSMiddleware(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"iot-agriculture-backend/internal/models"
+	"iot-agriculture-backend/internal/services"
+)
+
+// maxPlantingBodyBytes limits the size of crop planting request bodies
+const maxPlantingBodyBytes = 64 << 10
+
+// CropsHandler handles greenhouse crop requests
+type CropsHandler struct {
+	crops *services.CropService
+}
+
+// NewCropsHandler creates a new crops handler
+func NewCropsHandler(sensorService *services.SensorService) *CropsHandler {
+	return &CropsHandler{
+		crops: sensorService.GetCropService(),
+	}
+}
+
+// HandleCrop reports the GDD and stage (GET), plants (PUT) or removes (DELETE)
+// the crop of the greenhouse given by {greenhouse_id}
+func (h *CropsHandler) HandleCrop(w http.ResponseWriter, r *http.Request) {
+	greenhouseID := r.PathValue("greenhouse_id")
+	if err := services.ValidateIdentifier("greenhouse_id", greenhouseID); err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		status, err := h.crops.Status(greenhouseID, time.Now())
+		if err != nil {
+			sendError(w, cropErrorStatus(err), err.Error())
+			return
+		}
+		sendSuccess(w, status, "Crop status retrieved successfully")
+	case http.MethodPut:
+		var planting models.CropPlanting
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPlantingBodyBytes))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&planting); err != nil {
+			sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid crop planting: %v", err))
+			return
+		}
+		saved, err := h.crops.SetPlanting(greenhouseID, planting)
+		if err != nil {
+			sendError(w, cropErrorStatus(err), err.Error())
+			return
+		}
+		sendSuccess(w, saved, "Crop planted")
+	case http.MethodDelete:
+		if err := h.crops.DeletePlanting(greenhouseID); err != nil {
+			sendError(w, cropErrorStatus(err), err.Error())
+			return
+		}
+		sendSuccess(w, nil, "Crop removed")
+	default:
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// cropErrorStatus maps a crop service error to an HTTP status code
+func cropErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidPlanting):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrPlantingNotFound):
+		return http.StatusNotFound
+	default:
+		return queryErrorStatus(err)
+	}
+}
@@ -41,14 +41,11 @@ func (h *DatabaseHealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		isConnected := influxService.IsConnected()
 		connectionInfo := influxService.GetConnectionInfo()
 
-		health["status"] = "ok"
-		if isConnected {
-			health["status"] = "connected"
-		} else {
-			health["status"] = "disconnected"
-		}
+		// connected, connecting (retrying in the background) or disabled
+		health["status"] = influxService.ConnectionStatus()
 		health["connected"] = isConnected
 		health["message"] = connectionInfo
+		health["wal_queued_points"] = influxService.WALStats().Points
 	}
 
 	// Return success response
@@ -47,7 +47,7 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check all services
-	services := make(map[string]ServiceInfo)
+	checks := make(map[string]ServiceInfo)
 
 	// Check MQTT connection
 	mqttStatus := "healthy"
@@ -56,7 +56,7 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		mqttStatus = "unhealthy"
 		mqttMessage = "MQTT client not connected"
 	}
-	services["mqtt"] = ServiceInfo{
+	checks["mqtt"] = ServiceInfo{
 		Status:    mqttStatus,
 		Message:   mqttMessage,
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
@@ -66,11 +66,21 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	influxService := h.sensorService.GetInfluxDBService()
 	influxStatus := "healthy"
 	influxMessage := "Connected to InfluxDB"
-	if influxService == nil || !influxService.IsConnected() {
+	if influxService == nil {
 		influxStatus = "unhealthy"
 		influxMessage = "InfluxDB not connected"
+	} else {
+		switch influxService.ConnectionStatus() {
+		case services.ConnectionConnecting:
+			// Writes are queued on disk (if enabled) while the connection is retried
+			influxStatus = "degraded"
+			influxMessage = influxService.GetConnectionInfo()
+		case services.ConnectionDisabled:
+			influxStatus = "unhealthy"
+			influxMessage = "InfluxDB disabled (INFLUXDB_TOKEN not set)"
+		}
 	}
-	services["influxdb"] = ServiceInfo{
+	checks["influxdb"] = ServiceInfo{
 		Status:    influxStatus,
 		Message:   influxMessage,
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
@@ -84,7 +94,7 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		avgStatus = "unhealthy"
 		avgMessage = "Averaging service not initialized"
 	}
-	services["averaging"] = ServiceInfo{
+	checks["averaging"] = ServiceInfo{
 		Status:    avgStatus,
 		Message:   avgMessage,
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
@@ -98,7 +108,7 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		metricsStatus = "unhealthy"
 		metricsMessage = "Metrics service not initialized"
 	}
-	services["metrics"] = ServiceInfo{
+	checks["metrics"] = ServiceInfo{
 		Status:    metricsStatus,
 		Message:   metricsMessage,
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
@@ -108,12 +118,15 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	overallStatus := "healthy"
 	httpStatus := http.StatusOK
 
-	for _, service := range services {
+	for _, service := range checks {
 		if service.Status == "unhealthy" {
 			overallStatus = "unhealthy"
 			httpStatus = http.StatusServiceUnavailable
 			break
 		}
+		if service.Status == "degraded" {
+			overallStatus = "degraded"
+		}
 	}
 
 	// Create health response
@@ -122,7 +135,7 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 		Uptime:    time.Since(metricsService.GetStartTime()).String(),
 		Version:   "1.0.0",
-		Services:  services,
+		Services:  checks,
 	}
 
 	// Set appropriate HTTP status code
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"iot-agriculture-backend/internal/services"
+)
+
+// maxReplayBodyBytes limits the size of dead-letter replay request bodies
+const maxReplayBodyBytes = 64 << 10
+
+// IngestHandler handles requests about rejected data messages
+type IngestHandler struct {
+	sensorService *services.SensorService
+	deadLetters   *services.DeadLetterStore
+}
+
+// NewIngestHandler creates a new ingest handler
+func NewIngestHandler(sensorService *services.SensorService) *IngestHandler {
+	return &IngestHandler{
+		sensorService: sensorService,
+		deadLetters:   sensorService.GetDeadLetterStore(),
+	}
+}
+
+// HandleDeadLetters lists rejected messages, newest first (GET), or discards them all (DELETE)
+// Supports:
+// - Filtering by greenhouse_id and/or node_id
+// - reason: invalid_json, invalid_value, missing_identity, identity_mismatch, missing_field or out_of_range
+// - limit on the number of messages returned
+func (h *IngestHandler) HandleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query := services.DeadLetterQuery{
+			GreenhouseID: r.URL.Query().Get("greenhouse_id"),
+			NodeID:       r.URL.Query().Get("node_id"),
+			Reason:       r.URL.Query().Get("reason"),
+		}
+		limit, err := parseLimit(r, defaultPageLimit, maxPageLimit)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		query.Limit = limit
+		sendSuccess(w, h.deadLetters.List(query), "Dead letters retrieved successfully")
+	case http.MethodDelete:
+		removed := h.deadLetters.Delete(h.deadLetters.IDs()...)
+		sendSuccess(w, map[string]int{"deleted": removed}, "Dead letters deleted")
+	default:
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// HandleDeadLetter reads (GET) or discards (DELETE) the rejected message given by {id}
+func (h *IngestHandler) HandleDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid dead letter id: %s", r.PathValue("id")))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		entry, ok := h.deadLetters.Get(id)
+		if !ok {
+			sendError(w, http.StatusNotFound, fmt.Sprintf("dead letter %d not found", id))
+			return
+		}
+		sendSuccess(w, entry, "Dead letter retrieved successfully")
+	case http.MethodDelete:
+		if h.deadLetters.Delete(id) == 0 {
+			sendError(w, http.StatusNotFound, fmt.Sprintf("dead letter %d not found", id))
+			return
+		}
+		sendSuccess(w, nil, "Dead letter deleted")
+	default:
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// HandleReplay re-runs rejected messages through validation and averaging (POST)
+// The optional body {"ids": [1, 2]} selects messages; without it every message is replayed
+func (h *IngestHandler) HandleReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	var body struct {
+		IDs []int64 `json:"ids"`
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReplayBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid replay request: %v", err))
+		return
+	}
+
+	sendSuccess(w, h.sensorService.ReplayDeadLetters(body.IDs), "Dead letters replayed")
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"iot-agriculture-backend/internal/services"
+)
+
+// IrrigationHandler handles irrigation event requests
+type IrrigationHandler struct {
+	sensorService *services.SensorService
+	irrigation    *services.IrrigationService
+}
+
+// NewIrrigationHandler creates a new irrigation handler
+func NewIrrigationHandler(sensorService *services.SensorService) *IrrigationHandler {
+	return &IrrigationHandler{
+		sensorService: sensorService,
+		irrigation:    sensorService.GetIrrigationService(),
+	}
+}
+
+// HandleEvents returns irrigations from the irrigation_event measurement, newest first,
+// with the daily totals of each greenhouse over the requested range
+// Supports:
+// - Filtering by greenhouse_id and/or node_id
+// - start/end as RFC3339 timestamps or relative offsets (default: last 7 days)
+// - limit on the number of events returned (daily totals cover every event in the range)
+// A range holding more than maxPageLimit events is rejected rather than summed in part
+func (h *IrrigationHandler) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.irrigation.Enabled() {
+		sendError(w, http.StatusNotFound, "Irrigation detection is disabled (set IRRIGATION_SENSOR)")
+		return
+	}
+	if err := validateSensorQuery(r, h.sensorService.GetSensorRegistry()); err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := services.IrrigationQuery{
+		GreenhouseID: r.URL.Query().Get("greenhouse_id"),
+		NodeID:       r.URL.Query().Get("node_id"),
+		Limit:        maxPageLimit + 1,
+	}
+	start, end, err := parseTimeRange(r, 7*24*time.Hour)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.Start, query.End = start, end
+	limit, err := parseLimit(r, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := h.sensorService.GetInfluxDBService().GetIrrigationEventsFromDB(query)
+	if err != nil {
+		sendError(w, queryErrorStatus(err), err.Error())
+		return
+	}
+	if len(events) > maxPageLimit {
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("more than %d irrigation events in range; narrow start/end or filter by greenhouse_id or node_id", maxPageLimit))
+		return
+	}
+	daily := services.DailyIrrigation(events, h.irrigation.Location())
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	sendSuccess(w, map[string]interface{}{
+		"events": events,
+		"daily":  daily,
+	}, "Irrigation events retrieved from database")
+}
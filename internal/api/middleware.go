@@ -19,10 +19,11 @@ type ErrorResponse struct {
 
 // SuccessResponse represents a standardized success response
 type SuccessResponse struct {
-	Status  string      `json:"status"`
-	Data    interface{} `json:"data,omitempty"`
-	Message string      `json:"message,omitempty"`
-	Time    string      `json:"timestamp"`
+	Status     string      `json:"status"`
+	Data       interface{} `json:"data,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Time       string      `json:"timestamp"`
 }
 
 // sendError sends a standardized error response
@@ -52,6 +53,35 @@ func sendSuccess(w http.ResponseWriter, data interface{}, message string) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// sendCreated sends a standardized success response with status 201 Created
+func sendCreated(w http.ResponseWriter, data interface{}, message string) {
+	response := SuccessResponse{
+		Status:  "success",
+		Data:    data,
+		Message: message,
+		Time:    time.Now().UTC().Format(time.RFC3339),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// sendSuccessPage sends a standardized success response for one page of results
+// nextCursor is empty on the last page
+func sendSuccessPage(w http.ResponseWriter, data interface{}, message, nextCursor string) {
+	response := SuccessResponse{
+		Status:     "success",
+		Data:       data,
+		Message:    message,
+		NextCursor: nextCursor,
+		Time:       time.Now().UTC().Format(time.RFC3339),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // CORSMiddleware adds CORS headers to responses
 func CORSMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -109,6 +139,9 @@ func MonitoringMiddleware(metricsService *services.MetricsService) func(http.Han
 			// Record metrics
 			duration := time.Since(start)
 			endpoint := r.URL.Path
+			if r.Pattern != "" {
+				endpoint = r.Pattern // e.g. /alerts/rules/{id}, so IDs do not become labels
+			}
 			method := r.Method
 			status := strconv.Itoa(responseWriter.statusCode)
 
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"iot-agriculture-backend/internal/models"
+	"iot-agriculture-backend/internal/services"
+)
+
+// NodesHandler handles node liveness requests
+type NodesHandler struct {
+	nodeRegistry  *services.NodeRegistry
+	substrate     *services.SubstrateService
+	influxService *services.InfluxDBService
+}
+
+// NewNodesHandler creates a new nodes handler
+func NewNodesHandler(sensorService *services.SensorService) *NodesHandler {
+	return &NodesHandler{
+		nodeRegistry:  sensorService.GetNodeRegistry(),
+		substrate:     sensorService.GetSubstrateService(),
+		influxService: sensorService.GetInfluxDBService(),
+	}
+}
+
+// Handle lists every node seen since startup with its liveness
+// Supports:
+// - Filtering by greenhouse_id
+// - status: online, stale or offline
+func (h *NodesHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	greenhouseID := r.URL.Query().Get("greenhouse_id")
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.NodeOnline, models.NodeStale, models.NodeOffline:
+	default:
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid status: %s (expected online, stale or offline)", status))
+		return
+	}
+
+	nodes := make([]models.NodeStatus, 0)
+	for _, node := range h.nodeRegistry.Nodes(time.Now()) {
+		if (greenhouseID != "" && node.GreenhouseID != greenhouseID) || (status != "" && node.Status != status) {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	sendSuccess(w, nodes, "Nodes retrieved successfully")
+}
+
+// HandleNode returns the liveness of the node given by {greenhouse_id}/{node_id}
+func (h *NodesHandler) HandleNode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	greenhouseID := r.PathValue("greenhouse_id")
+	nodeID := r.PathValue("node_id")
+	node, ok := h.nodeRegistry.Node(greenhouseID, nodeID, time.Now())
+	if !ok {
+		sendError(w, http.StatusNotFound, fmt.Sprintf("node %s/%s has not been seen", greenhouseID, nodeID))
+		return
+	}
+	sendSuccess(w, node, "Node retrieved successfully")
+}
+
+// HandleSubstrate returns the substrate moisture profile of the node given by {greenhouse_id}/{node_id}
+// Supports:
+// - windows: number of recent windows the dry-down rate is fitted over (default: all kept windows)
+func (h *NodesHandler) HandleSubstrate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.substrate.Enabled() {
+		sendError(w, http.StatusNotFound, "Substrate profile is disabled (set SUBSTRATE_PROBES)")
+		return
+	}
+	windows := 0
+	if v := r.URL.Query().Get("windows"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 2 || n > h.substrate.MaxWindows() {
+			sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid windows: %s (expected 2-%d)", v, h.substrate.MaxWindows()))
+			return
+		}
+		windows = n
+	}
+	greenhouseID := r.PathValue("greenhouse_id")
+	nodeID := r.PathValue("node_id")
+	profile, ok := h.substrate.Profile(greenhouseID, nodeID, windows)
+	if !ok {
+		sendError(w, http.StatusNotFound, fmt.Sprintf("no substrate probe readings for node %s/%s yet", greenhouseID, nodeID))
+		return
+	}
+	sendSuccess(w, profile, "Substrate profile retrieved successfully")
+}
+
+// HandleDevices returns the last status reported by each device, e.g. the ESP32 on simulator/status
+func (h *NodesHandler) HandleDevices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	sendSuccess(w, h.nodeRegistry.Devices(), "Devices retrieved successfully")
+}
+
+// HandleEvents returns online/offline transitions from the node_status measurement, newest first
+// Supports:
+// - Filtering by greenhouse_id and/or node_id, or by device
+// - start/end as RFC3339 timestamps or relative offsets (default: last 24 hours)
+// - limit on the number of events returned
+func (h *NodesHandler) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	query := services.NodeStatusQuery{
+		GreenhouseID: r.URL.Query().Get("greenhouse_id"),
+		NodeID:       r.URL.Query().Get("node_id"),
+		Device:       r.URL.Query().Get("device"),
+	}
+	start, end, err := parseTimeRange(r, 24*time.Hour)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.Start, query.End = start, end
+	if query.Limit, err = parseLimit(r, defaultPageLimit, maxPageLimit); err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := h.influxService.GetNodeStatusEventsFromDB(query)
+	if err != nil {
+		sendError(w, queryErrorStatus(err), err.Error())
+		return
+	}
+	sendSuccess(w, events, "Node status events retrieved from database")
+}
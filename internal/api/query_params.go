@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"iot-agriculture-backend/internal/services"
+)
+
+// Pagination limits for historical queries
+const (
+	defaultPageLimit = 1000
+	maxPageLimit     = 10000
+)
+
+// cursorIDPattern restricts the identifiers that may appear in a pagination cursor
+var cursorIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{0,64}$`)
+
+// parseRelativeDuration parses a signed duration, additionally accepting
+// day ("d") and week ("w") units, e.g. "-7d" or "-2w"
+func parseRelativeDuration(value string) (time.Duration, error) {
+	sign := time.Duration(1)
+	v := value
+	if strings.HasPrefix(v, "-") {
+		sign = -1
+		v = v[1:]
+	}
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, found := strings.CutSuffix(v, suffix); found {
+			count, err := strconv.Atoi(n)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration: %s", value)
+			}
+			return sign * time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+	return sign * d, nil
+}
+
+// parseTimeParam parses an RFC3339 timestamp, "now", or an offset relative to now (e.g. "-6h")
+func parseTimeParam(value string, now time.Time) (time.Time, error) {
+	if value == "now" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	offset, err := parseRelativeDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s (expected RFC3339, now, or a relative offset such as -7d)", value)
+	}
+	return now.Add(offset), nil
+}
+
+// parseTimeRange parses the start and end query parameters
+// end defaults to now and start defaults to defaultSpan before end
+func parseTimeRange(r *http.Request, defaultSpan time.Duration) (time.Time, time.Time, error) {
+	now := time.Now()
+	end := now
+	if v := r.URL.Query().Get("end"); v != "" {
+		t, err := parseTimeParam(v, now)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end: %w", err)
+		}
+		end = t
+	}
+	start := end.Add(-defaultSpan)
+	if v := r.URL.Query().Get("start"); v != "" {
+		t, err := parseTimeParam(v, now)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start: %w", err)
+		}
+		start = t
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start must be before end")
+	}
+	return start, end, nil
+}
+
+// parseLimit parses the limit query parameter
+func parseLimit(r *http.Request, defaultLimit, maxLimit int) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 || limit > maxLimit {
+		return 0, fmt.Errorf("invalid limit: %s (expected 1-%d)", v, maxLimit)
+	}
+	return limit, nil
+}
+
+// parseUnits parses the units query parameter (default: the registry's canonical units)
+func parseUnits(r *http.Request, registry *services.SensorRegistry) (*services.UnitSelection, error) {
+	return services.ParseUnitSelection(r.URL.Query().Get("units"), registry)
+}
+
+// encodeCursor encodes a pagination cursor as an opaque URL-safe string
+func encodeCursor(c *services.AveragesCursor) string {
+	raw := fmt.Sprintf("%d|%s|%s", c.Time.UnixNano(), c.GreenhouseID, c.NodeID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor decodes a pagination cursor produced by encodeCursor
+func decodeCursor(value string) (*services.AveragesCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 || !cursorIDPattern.MatchString(parts[1]) || !cursorIDPattern.MatchString(parts[2]) {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &services.AveragesCursor{
+		Time:         time.Unix(0, nanos),
+		GreenhouseID: parts[1],
+		NodeID:       parts[2],
+	}, nil
+}
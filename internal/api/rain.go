@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"iot-agriculture-backend/internal/services"
+)
+
+// RainHandler handles rain event requests
+type RainHandler struct {
+	sensorService *services.SensorService
+	rain          *services.RainService
+}
+
+// NewRainHandler creates a new rain handler
+func NewRainHandler(sensorService *services.SensorService) *RainHandler {
+	return &RainHandler{
+		sensorService: sensorService,
+		rain:          sensorService.GetRainService(),
+	}
+}
+
+// HandleEvents returns completed rain events from the rain_event measurement, newest first,
+// the events in progress, and the rainy minutes of each node and local day over the requested range
+// Supports:
+// - Filtering by greenhouse_id and/or node_id
+// - start/end as RFC3339 timestamps or relative offsets (default: last 7 days)
+// - limit on the number of events returned (daily totals cover every event in the range)
+// A range holding more than maxPageLimit events is rejected rather than summed in part
+func (h *RainHandler) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.rain.Enabled() {
+		sendError(w, http.StatusNotFound, "Rain detection is disabled (set RAIN_SENSOR)")
+		return
+	}
+	if err := validateSensorQuery(r, h.sensorService.GetSensorRegistry()); err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := services.RainQuery{
+		GreenhouseID: r.URL.Query().Get("greenhouse_id"),
+		NodeID:       r.URL.Query().Get("node_id"),
+		Limit:        maxPageLimit + 1,
+	}
+	start, end, err := parseTimeRange(r, 7*24*time.Hour)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.Start, query.End = start, end
+	limit, err := parseLimit(r, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := h.sensorService.GetInfluxDBService().GetRainEventsFromDB(query)
+	if err != nil {
+		sendError(w, queryErrorStatus(err), err.Error())
+		return
+	}
+	if len(events) > maxPageLimit {
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("more than %d rain events in range; narrow start/end or filter by greenhouse_id or node_id", maxPageLimit))
+		return
+	}
+	active := h.rain.Active(query.GreenhouseID, query.NodeID)
+	daily := services.DailyRain(append(events, active...), h.rain.Location())
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	sendSuccess(w, map[string]interface{}{
+		"events": events,
+		"active": active,
+		"daily":  daily,
+	}, "Rain events retrieved from database")
+}
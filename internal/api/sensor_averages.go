@@ -1,11 +1,13 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"iot-agriculture-backend/internal/models"
 	"iot-agriculture-backend/internal/services"
 )
 
@@ -26,6 +28,7 @@ func NewSensorAveragesHandler(sensorService *services.SensorService) *SensorAver
 // - Listing all node averages
 // - Filtering by greenhouse_id and/or node_id
 // - Selecting specific sensors with the 'sensors' query param
+// - Converting values with the 'units' query param (e.g. "imperial" or "Air_Temp:°F")
 func (h *SensorAveragesHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -37,6 +40,11 @@ func (h *SensorAveragesHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		sendError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	units, err := parseUnits(r, h.sensorService.GetSensorRegistry())
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Get query parameters
 	sensors := r.URL.Query().Get("sensors") // e.g., "S1,S2,S3" or "all"
@@ -62,58 +70,13 @@ func (h *SensorAveragesHandler) Handle(w http.ResponseWriter, r *http.Request) {
 			"node_id":       averages.NodeID,
 			"duration":      averages.Duration,
 			"readings":      averages.Readings,
+			"window_start":  averages.WindowStart.UTC().Format(time.RFC3339),
+			"window_end":    averages.WindowEnd.UTC().Format(time.RFC3339),
 			"timestamp":     time.Now().UTC().Format("2006-01-02T15:04:05Z"),
-			"sensors":       make(map[string]interface{}),
-		}
-
-		sensorMap := map[string]interface{}{}
-		if averages.BagTemp != nil {
-			sensorMap["Bag_Temp"] = *averages.BagTemp
-		}
-		if averages.LightPar != nil {
-			sensorMap["Light_Par"] = *averages.LightPar
-		}
-		if averages.AirTemp != nil {
-			sensorMap["Air_Temp"] = *averages.AirTemp
-		}
-		if averages.AirRh != nil {
-			sensorMap["Air_Rh"] = *averages.AirRh
-		}
-		if averages.LeafTemp != nil {
-			sensorMap["Leaf_temp"] = *averages.LeafTemp
-		}
-		if averages.DripWeight != nil {
-			sensorMap["drip_weight"] = *averages.DripWeight
-		}
-		if averages.BagRh1 != nil {
-			sensorMap["Bag_Rh1"] = *averages.BagRh1
-		}
-		if averages.BagRh2 != nil {
-			sensorMap["Bag_Rh2"] = *averages.BagRh2
-		}
-		if averages.BagRh3 != nil {
-			sensorMap["Bag_Rh3"] = *averages.BagRh3
-		}
-		if averages.BagRh4 != nil {
-			sensorMap["Bag_Rh4"] = *averages.BagRh4
-		}
-		if averages.Rain != nil {
-			sensorMap["Rain"] = *averages.Rain
-		}
-
-		// Filter sensors based on request
-		if sensors == "" || sensors == "all" {
-			response["sensors"] = sensorMap
-		} else {
-			requestedSensors := strings.Split(sensors, ",")
-			filteredSensors := make(map[string]interface{})
-			for _, sensor := range requestedSensors {
-				sensor = strings.TrimSpace(sensor)
-				if value, exists := sensorMap[sensor]; exists {
-					filteredSensors[sensor] = value
-				}
-			}
-			response["sensors"] = filteredSensors
+			"sensors":       h.selectMeans(averages.Stats, sensors, units),
+			"stats":         h.selectStats(averages.Stats, sensors, units),
+			"derived":       units.Derived(averages.Derived),
+			"units":         h.selectUnits(averages, sensors, units),
 		}
 
 		results = append(results, response)
@@ -128,11 +91,33 @@ func (h *SensorAveragesHandler) Handle(w http.ResponseWriter, r *http.Request) {
 }
 
 // validateQueryParams validates query parameters
+// State sensors are rejected, since they have no averages
 func (h *SensorAveragesHandler) validateQueryParams(r *http.Request) error {
+	registry := h.sensorService.GetSensorRegistry()
+	if err := validateSensorQuery(r, registry); err != nil {
+		return err
+	}
+	for _, s := range strings.Split(r.URL.Query().Get("sensors"), ",") {
+		if s = strings.TrimSpace(s); registry.IsValid(s) && !registry.IsAggregated(s) {
+			return fmt.Errorf("sensor %s is a state sensor without averages", s)
+		}
+	}
+	return nil
+}
+
+// validateSensorQuery validates the greenhouse_id, node_id and sensors query parameters
+func validateSensorQuery(r *http.Request, registry *services.SensorRegistry) error {
+	for _, param := range []string{"greenhouse_id", "node_id"} {
+		if v := r.URL.Query().Get(param); v != "" {
+			if err := services.ValidateIdentifier(param, v); err != nil {
+				return err
+			}
+		}
+	}
+
 	sensors := r.URL.Query().Get("sensors")
 
 	if sensors != "" && sensors != "all" {
-		validSensors := []string{"Bag_Temp", "Light_Par", "Air_Temp", "Air_Rh", "Leaf_temp", "drip_weight", "Bag_Rh1", "Bag_Rh2", "Bag_Rh3", "Bag_Rh4", "Rain"}
 		requested := strings.Split(sensors, ",")
 
 		for _, s := range requested {
@@ -141,15 +126,7 @@ func (h *SensorAveragesHandler) validateQueryParams(r *http.Request) error {
 				continue
 			}
 
-			valid := false
-			for _, validSensor := range validSensors {
-				if s == validSensor {
-					valid = true
-					break
-				}
-			}
-
-			if !valid {
+			if !registry.IsValid(s) {
 				return fmt.Errorf("invalid sensor: %s", s)
 			}
 		}
@@ -158,6 +135,65 @@ func (h *SensorAveragesHandler) validateQueryParams(r *http.Request) error {
 	return nil
 }
 
+// queryErrorStatus maps a database query error to an HTTP status code
+func queryErrorStatus(err error) int {
+	if errors.Is(err, services.ErrInvalidQuery) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// selectedSensorNames returns the requested sensor names, in registry order
+// An empty selection or "all" returns every registered sensor
+func (h *SensorAveragesHandler) selectedSensorNames(sensors string) []string {
+	if sensors == "" || sensors == "all" {
+		return h.sensorService.GetSensorRegistry().Names()
+	}
+	names := make([]string, 0)
+	for _, sensor := range strings.Split(sensors, ",") {
+		if sensor = strings.TrimSpace(sensor); sensor != "" {
+			names = append(names, sensor)
+		}
+	}
+	return names
+}
+
+// selectMeans returns the window mean of each requested sensor with data, in the selected units
+func (h *SensorAveragesHandler) selectMeans(stats map[string]models.SensorStats, sensors string, units *services.UnitSelection) map[string]interface{} {
+	selected := make(map[string]interface{})
+	for _, name := range h.selectedSensorNames(sensors) {
+		if s, exists := stats[name]; exists {
+			selected[name] = units.Value(name, s.Mean)
+		}
+	}
+	return selected
+}
+
+// selectStats returns the full window aggregates of each requested sensor with data, in the selected units
+func (h *SensorAveragesHandler) selectStats(stats map[string]models.SensorStats, sensors string, units *services.UnitSelection) map[string]models.SensorStats {
+	selected := make(map[string]models.SensorStats)
+	for _, name := range h.selectedSensorNames(sensors) {
+		if s, exists := stats[name]; exists {
+			selected[name] = units.Stats(name, s)
+		}
+	}
+	return selected
+}
+
+// selectUnits returns the unit of each requested sensor with data and of each derived metric
+func (h *SensorAveragesHandler) selectUnits(result models.AverageResult, sensors string, units *services.UnitSelection) map[string]string {
+	names := make([]string, 0, len(result.Stats)+len(result.Derived))
+	for _, name := range h.selectedSensorNames(sensors) {
+		if _, exists := result.Stats[name]; exists {
+			names = append(names, name)
+		}
+	}
+	for name := range result.Derived {
+		names = append(names, name)
+	}
+	return units.Units(names)
+}
+
 // SensorAveragesLatestHandler handles latest averages from DB
 func (h *SensorAveragesHandler) HandleLatest(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -168,12 +204,17 @@ func (h *SensorAveragesHandler) HandleLatest(w http.ResponseWriter, r *http.Requ
 		sendError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	units, err := parseUnits(r, h.sensorService.GetSensorRegistry())
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	sensors := r.URL.Query().Get("sensors")
 	greenhouseID := r.URL.Query().Get("greenhouse_id")
 	nodeID := r.URL.Query().Get("node_id")
 	averages, err := h.sensorService.GetInfluxDBService().GetLatestAveragesFromDB(greenhouseID, nodeID)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err.Error())
+		sendError(w, queryErrorStatus(err), err.Error())
 		return
 	}
 	results := make([]map[string]interface{}, 0)
@@ -181,54 +222,11 @@ func (h *SensorAveragesHandler) HandleLatest(w http.ResponseWriter, r *http.Requ
 		response := map[string]interface{}{
 			"greenhouse_id": avg.GreenhouseID,
 			"node_id":       avg.NodeID,
-			"sensors":       make(map[string]interface{}),
-		}
-		sensorMap := map[string]interface{}{}
-		if avg.BagTemp != nil {
-			sensorMap["Bag_Temp"] = *avg.BagTemp
-		}
-		if avg.LightPar != nil {
-			sensorMap["Light_Par"] = *avg.LightPar
-		}
-		if avg.AirTemp != nil {
-			sensorMap["Air_Temp"] = *avg.AirTemp
-		}
-		if avg.AirRh != nil {
-			sensorMap["Air_Rh"] = *avg.AirRh
-		}
-		if avg.LeafTemp != nil {
-			sensorMap["Leaf_temp"] = *avg.LeafTemp
-		}
-		if avg.DripWeight != nil {
-			sensorMap["drip_weight"] = *avg.DripWeight
-		}
-		if avg.BagRh1 != nil {
-			sensorMap["Bag_Rh1"] = *avg.BagRh1
-		}
-		if avg.BagRh2 != nil {
-			sensorMap["Bag_Rh2"] = *avg.BagRh2
-		}
-		if avg.BagRh3 != nil {
-			sensorMap["Bag_Rh3"] = *avg.BagRh3
-		}
-		if avg.BagRh4 != nil {
-			sensorMap["Bag_Rh4"] = *avg.BagRh4
-		}
-		if avg.Rain != nil {
-			sensorMap["Rain"] = *avg.Rain
-		}
-		if sensors == "" || sensors == "all" {
-			response["sensors"] = sensorMap
-		} else {
-			requestedSensors := strings.Split(sensors, ",")
-			filteredSensors := make(map[string]interface{})
-			for _, sensor := range requestedSensors {
-				sensor = strings.TrimSpace(sensor)
-				if value, exists := sensorMap[sensor]; exists {
-					filteredSensors[sensor] = value
-				}
-			}
-			response["sensors"] = filteredSensors
+			"timestamp":     avg.WindowEnd.UTC().Format(time.RFC3339),
+			"sensors":       h.selectMeans(avg.Stats, sensors, units),
+			"stats":         h.selectStats(avg.Stats, sensors, units),
+			"derived":       units.Derived(avg.Derived),
+			"units":         h.selectUnits(avg, sensors, units),
 		}
 		results = append(results, response)
 	}
@@ -239,7 +237,13 @@ func (h *SensorAveragesHandler) HandleLatest(w http.ResponseWriter, r *http.Requ
 	sendSuccess(w, results, "Latest sensor averages retrieved from database")
 }
 
-// SensorAveragesAllHandler handles fetching all average data from DB
+// SensorAveragesAllHandler handles fetching historical average data from DB
+// Supports:
+// - start/end as RFC3339 timestamps or relative offsets (e.g. "-7d", "now")
+// - every: downsampling interval applied with aggregateWindow (e.g. "1h")
+// - limit/cursor pagination over time-ordered rows
+// - resolution: raw, 15m, 1h or 1d (default: picked from the time span)
+// - units: "imperial" and/or per-sensor units such as "drip_weight:oz"
 func (h *SensorAveragesHandler) HandleAll(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -249,12 +253,66 @@ func (h *SensorAveragesHandler) HandleAll(w http.ResponseWriter, r *http.Request
 		sendError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	units, err := parseUnits(r, h.sensorService.GetSensorRegistry())
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	sensors := r.URL.Query().Get("sensors")
-	greenhouseID := r.URL.Query().Get("greenhouse_id")
-	nodeID := r.URL.Query().Get("node_id")
-	averages, err := h.sensorService.GetInfluxDBService().GetAllAveragesFromDB(greenhouseID, nodeID)
+	query := services.AveragesQuery{
+		GreenhouseID: r.URL.Query().Get("greenhouse_id"),
+		NodeID:       r.URL.Query().Get("node_id"),
+	}
+
+	// Time range (default last 30 days)
+	start, end, err := parseTimeRange(r, 30*24*time.Hour)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.Start, query.End = start, end
+
+	// Downsampling interval
+	if v := r.URL.Query().Get("every"); v != "" {
+		every, err := parseRelativeDuration(v)
+		if err != nil || every < time.Second {
+			sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid every: %s (expected a positive interval such as 5m or 1h)", v))
+			return
+		}
+		query.Every = every
+	}
+
+	// Pagination
+	limit, err := parseLimit(r, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.Limit = limit
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		cursor, err := decodeCursor(v)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		query.After = cursor
+	}
+
+	// Resolution: picked from the span unless given explicitly
+	resolution := r.URL.Query().Get("resolution")
+	if resolution == "" || resolution == "auto" {
+		resolution = services.ResolutionForSpan(end.Sub(start))
+	}
+	measurement, ok := services.MeasurementForResolution(resolution)
+	if !ok {
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid resolution: %s (expected auto, raw, 15m, 1h or 1d)", resolution))
+		return
+	}
+	query.Measurement = measurement
+
+	averages, next, err := h.sensorService.GetInfluxDBService().GetAllAveragesFromDB(query)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err.Error())
+		sendError(w, queryErrorStatus(err), err.Error())
 		return
 	}
 	results := make([]map[string]interface{}, 0)
@@ -262,54 +320,13 @@ func (h *SensorAveragesHandler) HandleAll(w http.ResponseWriter, r *http.Request
 		response := map[string]interface{}{
 			"greenhouse_id": avg.GreenhouseID,
 			"node_id":       avg.NodeID,
-			"sensors":       make(map[string]interface{}),
-		}
-		sensorMap := map[string]interface{}{}
-		if avg.BagTemp != nil {
-			sensorMap["Bag_Temp"] = *avg.BagTemp
-		}
-		if avg.LightPar != nil {
-			sensorMap["Light_Par"] = *avg.LightPar
-		}
-		if avg.AirTemp != nil {
-			sensorMap["Air_Temp"] = *avg.AirTemp
-		}
-		if avg.AirRh != nil {
-			sensorMap["Air_Rh"] = *avg.AirRh
-		}
-		if avg.LeafTemp != nil {
-			sensorMap["Leaf_temp"] = *avg.LeafTemp
-		}
-		if avg.DripWeight != nil {
-			sensorMap["drip_weight"] = *avg.DripWeight
-		}
-		if avg.BagRh1 != nil {
-			sensorMap["Bag_Rh1"] = *avg.BagRh1
-		}
-		if avg.BagRh2 != nil {
-			sensorMap["Bag_Rh2"] = *avg.BagRh2
-		}
-		if avg.BagRh3 != nil {
-			sensorMap["Bag_Rh3"] = *avg.BagRh3
-		}
-		if avg.BagRh4 != nil {
-			sensorMap["Bag_Rh4"] = *avg.BagRh4
-		}
-		if avg.Rain != nil {
-			sensorMap["Rain"] = *avg.Rain
-		}
-		if sensors == "" || sensors == "all" {
-			response["sensors"] = sensorMap
-		} else {
-			requestedSensors := strings.Split(sensors, ",")
-			filteredSensors := make(map[string]interface{})
-			for _, sensor := range requestedSensors {
-				sensor = strings.TrimSpace(sensor)
-				if value, exists := sensorMap[sensor]; exists {
-					filteredSensors[sensor] = value
-				}
-			}
-			response["sensors"] = filteredSensors
+			"timestamp":     avg.WindowEnd.UTC().Format(time.RFC3339),
+			"resolution":    resolution,
+			"readings":      avg.Readings,
+			"sensors":       h.selectMeans(avg.Stats, sensors, units),
+			"stats":         h.selectStats(avg.Stats, sensors, units),
+			"derived":       units.Derived(avg.Derived),
+			"units":         h.selectUnits(avg, sensors, units),
 		}
 		results = append(results, response)
 	}
@@ -317,5 +334,9 @@ func (h *SensorAveragesHandler) HandleAll(w http.ResponseWriter, r *http.Request
 		sendError(w, http.StatusNotFound, "No sensor averages found for the specified criteria")
 		return
 	}
-	sendSuccess(w, results, "All sensor averages retrieved from database")
+	nextCursor := ""
+	if next != nil {
+		nextCursor = encodeCursor(next)
+	}
+	sendSuccessPage(w, results, "All sensor averages retrieved from database", nextCursor)
 }
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"iot-agriculture-backend/internal/services"
+)
+
+// defaultDLIHistorySpan is the span of DLI history returned without start/end
+const defaultDLIHistorySpan = 30 * 24 * time.Hour
+
+// SensorDLIHandler handles daily light integral requests
+type SensorDLIHandler struct {
+	sensorService *services.SensorService
+	light         *services.LightService
+}
+
+// NewSensorDLIHandler creates a new daily light integral handler
+func NewSensorDLIHandler(sensorService *services.SensorService) *SensorDLIHandler {
+	return &SensorDLIHandler{
+		sensorService: sensorService,
+		light:         sensorService.GetLightService(),
+	}
+}
+
+// Handle returns the running DLI of today and the completed days from the sensor_dli measurement
+// Supports:
+// - Filtering by greenhouse_id and/or node_id
+// - start/end as RFC3339 timestamps or relative offsets (default: last 30 days)
+// - limit on the number of completed days returned
+func (h *SensorDLIHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.light.Enabled() {
+		sendError(w, http.StatusNotFound, "Daily light integral is disabled (set LIGHT_PAR_SENSOR)")
+		return
+	}
+	if err := validateSensorQuery(r, h.sensorService.GetSensorRegistry()); err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := services.DLIQuery{
+		GreenhouseID: r.URL.Query().Get("greenhouse_id"),
+		NodeID:       r.URL.Query().Get("node_id"),
+	}
+	start, end, err := parseTimeRange(r, defaultDLIHistorySpan)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.Start, query.End = start, end
+	if query.Limit, err = parseLimit(r, defaultPageLimit, maxPageLimit); err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	history, err := h.sensorService.GetInfluxDBService().GetDailyLightIntegralsFromDB(query)
+	if err != nil {
+		sendError(w, queryErrorStatus(err), err.Error())
+		return
+	}
+	sendSuccess(w, map[string]interface{}{
+		"unit":    "mol/m²/day",
+		"target":  h.light.Target(),
+		"today":   h.light.Today(query.GreenhouseID, query.NodeID),
+		"history": history,
+	}, "Daily light integrals retrieved successfully")
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"iot-agriculture-backend/internal/services"
+)
+
+// SensorRawHandler handles raw sensor reading requests
+type SensorRawHandler struct {
+	sensorService *services.SensorService
+}
+
+// NewSensorRawHandler creates a new raw sensor reading handler
+func NewSensorRawHandler(sensorService *services.SensorService) *SensorRawHandler {
+	return &SensorRawHandler{
+		sensorService: sensorService,
+	}
+}
+
+// Handle handles fetching individual readings from the sensor_raw measurement
+// Supports:
+// - Filtering by greenhouse_id and/or node_id
+// - Selecting specific sensors with the 'sensors' query param
+// - start/end as RFC3339 timestamps or relative offsets (default: last hour)
+// - limit/cursor pagination over time-ordered rows
+// - units: "imperial" and/or per-sensor units such as "Air_Temp:°F"
+func (h *SensorRawHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if err := validateSensorQuery(r, h.sensorService.GetSensorRegistry()); err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	units, err := parseUnits(r, h.sensorService.GetSensorRegistry())
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	influxService := h.sensorService.GetInfluxDBService()
+	if !influxService.RawPersistenceEnabled() {
+		sendError(w, http.StatusNotFound, "Raw reading persistence is disabled (set RAW_PERSISTENCE_NODES)")
+		return
+	}
+
+	query := services.RawQuery{
+		GreenhouseID: r.URL.Query().Get("greenhouse_id"),
+		NodeID:       r.URL.Query().Get("node_id"),
+	}
+	if sensors := r.URL.Query().Get("sensors"); sensors != "" && sensors != "all" {
+		for _, sensor := range strings.Split(sensors, ",") {
+			if sensor = strings.TrimSpace(sensor); sensor != "" {
+				query.Sensors = append(query.Sensors, sensor)
+			}
+		}
+	}
+
+	// Time range (default last hour)
+	start, end, err := parseTimeRange(r, time.Hour)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.Start, query.End = start, end
+
+	// Pagination
+	limit, err := parseLimit(r, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.Limit = limit
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		cursor, err := decodeCursor(v)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		query.After = cursor
+	}
+
+	readings, next, err := influxService.GetRawReadingsFromDB(query)
+	if err != nil {
+		sendError(w, queryErrorStatus(err), err.Error())
+		return
+	}
+	results := make([]map[string]interface{}, 0, len(readings))
+	for _, reading := range readings {
+		values := make(map[string]float64, len(reading.Values))
+		names := make([]string, 0, len(reading.Values))
+		for name, value := range reading.Values {
+			values[name] = units.Value(name, value)
+			names = append(names, name)
+		}
+		results = append(results, map[string]interface{}{
+			"greenhouse_id": reading.GreenhouseID,
+			"node_id":       reading.NodeID,
+			"timestamp":     reading.Timestamp.UTC().Format(time.RFC3339Nano),
+			"sensors":       values,
+			"units":         units.Units(names),
+		})
+	}
+	if len(results) == 0 {
+		sendError(w, http.StatusNotFound, "No raw readings found for the specified criteria")
+		return
+	}
+	nextCursor := ""
+	if next != nil {
+		nextCursor = encodeCursor(next)
+	}
+	sendSuccessPage(w, results, "Raw sensor readings retrieved from database", nextCursor)
+}
@@ -4,18 +4,46 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // MQTTConfig holds MQTT broker configuration
 type MQTTConfig struct {
-	Broker   string
-	Port     int
-	ClientID string
-	Topic    string
-	Username string
-	Password string
+	Broker         string
+	Port           int
+	ClientID       string
+	Topic          string // Data topic filter used when MQTT_SUBSCRIPTIONS is not set
+	Username       string
+	Password       string
+	Subscriptions  []SubscriptionConfig
+	SharedGroup    string // If set, data and telemetry subscriptions become $share/<group>/<filter>
+	IdentityPolicy string // topic, payload or reject: which greenhouse/node IDs a data message is attributed to
+	QuarantineLog  string // JSON lines file recording topic/payload identity mismatches ("" = disabled)
+}
+
+// Identity policies for data messages whose topic and payload IDs differ
+const (
+	IdentityTrustTopic   = "topic"   // Attribute the reading to the topic's IDs
+	IdentityTrustPayload = "payload" // Attribute the reading to the payload's IDs
+	IdentityReject       = "reject"  // Drop the reading
+)
+
+// Message handlers a subscription can be routed to
+const (
+	HandlerData      = "data"      // Sensor readings
+	HandlerStatus    = "status"    // online/offline LWT messages
+	HandlerTelemetry = "telemetry" // Node diagnostics (RSSI, heap, uptime...)
+	HandlerAcks      = "acks"      // Command acknowledgements
+)
+
+// SubscriptionConfig is one topic filter and the handler its messages are routed to
+type SubscriptionConfig struct {
+	Handler string
+	Topic   string // May be a shared subscription: $share/<group>/<filter>
+	QoS     byte
 }
 
 // DatabaseConfig holds database configuration
@@ -34,6 +62,25 @@ type InfluxDBConfig struct {
 	Token  string
 	Org    string
 	Bucket string
+	Raw    RawPersistenceConfig
+	WAL    WALConfig
+}
+
+// WALConfig holds the on-disk write-ahead queue used while InfluxDB is unavailable
+type WALConfig struct {
+	Dir            string        // Directory holding queue segments ("off" = disabled)
+	MaxBytes       int           // Oldest segments are dropped beyond this size
+	SegmentBytes   int           // A new segment is started once the active one reaches this size
+	ReplayInterval time.Duration // How often the queue is drained while InfluxDB is reachable
+}
+
+// RawPersistenceConfig controls persistence of individual readings to the sensor_raw measurement
+type RawPersistenceConfig struct {
+	Bucket        string        // Separate bucket so raw data can have a shorter retention
+	Retention     time.Duration // Retention used when the bucket has to be created (0 = infinite)
+	Nodes         []string      // greenhouse_id/node_id patterns, e.g. "GH1/*" or "*/Node05" (empty = disabled)
+	BatchSize     int           // Points per batched write
+	FlushInterval time.Duration // Maximum time a point waits in the write batch
 }
 
 // APIConfig holds API server configuration
@@ -48,13 +95,102 @@ type RedisConfig struct {
 	DB       int
 }
 
+// AveragingConfig holds event-time windowing configuration
+type AveragingConfig struct {
+	Window          time.Duration // Length of each wall-clock aligned window
+	AllowedLateness time.Duration // How long a window stays open after it ends
+	FlushInterval   time.Duration // How often closed windows are flushed
+}
+
+// AlertsConfig holds alerting configuration
+type AlertsConfig struct {
+	RulesFile   string // JSON file the alert rules are persisted to ("" = in memory only)
+	HistorySize int    // Number of resolved alerts kept for /alerts
+}
+
+// NodesConfig holds node liveness configuration
+type NodesConfig struct {
+	ExpectedInterval time.Duration // Publish interval of the nodes (0 = learned per node)
+	StaleAfter       time.Duration // Silence after which a node is stale
+	OfflineAfter     time.Duration // Silence after which a node is offline
+}
+
+// IngestConfig holds data message processing and validation configuration
+type IngestConfig struct {
+	Workers        int           // Workers processing data messages; each node is served by one worker
+	QueueSize      int           // Messages each worker's queue holds
+	Backpressure   string        // What to do when a worker's queue is full
+	BlockTimeout   time.Duration // How long the block policy waits for queue space
+	DeadLetterFile string        // JSON lines file rejected messages are kept in ("" = in memory only)
+	DeadLetterSize int           // Number of rejected messages kept (0 disables the dead-letter store)
+}
+
+// LightConfig holds daily light integral (DLI) configuration
+type LightConfig struct {
+	Sensor    string         // PAR sensor integrated into the DLI, in µmol/m²/s ("" disables the DLI)
+	Location  *time.Location // Time zone whose midnight starts a new day
+	StateFile string         // JSON file the running totals are persisted to ("" = in memory only)
+	MaxGap    time.Duration  // Longest gap between readings that is integrated; longer gaps count as no light
+	Target    float64        // DLI goal in mol/m²/day that flags days for supplemental lighting (0 = no target)
+}
+
+// IrrigationConfig holds irrigation event detection configuration
+type IrrigationConfig struct {
+	Sensor     string         // Substrate weight sensor the events are detected from, in g ("" disables detection)
+	Location   *time.Location // Time zone whose midnight separates the daily totals
+	StartRate  float64        // Weight gain in g/min between two readings that starts an irrigation
+	MinSupply  float64        // Smallest weight gain in g recorded as an irrigation (smaller rises are noise)
+	StopAfter  time.Duration  // Time without a new peak after which the irrigation has stopped
+	SettleTime time.Duration  // Time after the stop during which drainage is measured
+}
+
+// RainConfig holds rain event detection configuration
+type RainConfig struct {
+	Sensor   string         // 0/1 rain flag the events are detected from ("" disables detection)
+	Location *time.Location // Time zone whose midnight separates the daily rainy minutes
+	MaxGap   time.Duration  // Longest gap between readings during rain; a longer gap ends the event at the last reading
+}
+
+// CropConfig holds growing degree day (GDD) and crop stage configuration
+type CropConfig struct {
+	File           string         // JSON file the greenhouse plantings are persisted to ("" = in memory only)
+	Sensor         string         // Air temperature sensor GDD accumulate from, in °C
+	Location       *time.Location // Time zone whose midnight separates the days of a planting
+	ProjectionDays int            // Recent complete days whose mean GDD projects the stage dates
+}
+
+// SubstrateConfig holds substrate moisture profile configuration
+type SubstrateConfig struct {
+	Probes    []string // Moisture probes of one substrate bag, in %RH (empty disables the profile)
+	Windows   int      // Averaging windows kept per node for the dry-down rate
+	Deviation float64  // Distance in %RH from the median of the other probes that flags a probe
+}
+
+// Backpressure policies for full ingest queues
+const (
+	BackpressureDropNewest = "drop_newest" // Drop the incoming message
+	BackpressureDropOldest = "drop_oldest" // Evict the oldest queued message to make room
+	BackpressureBlock      = "block"       // Wait up to BlockTimeout for room, then drop the incoming message
+)
+
 // Config holds all application configuration
 type Config struct {
-	MQTT     MQTTConfig
-	Database DatabaseConfig
-	InfluxDB InfluxDBConfig
-	API      APIConfig
-	Redis    RedisConfig
+	MQTT          MQTTConfig
+	Database      DatabaseConfig
+	InfluxDB      InfluxDBConfig
+	API           APIConfig
+	Redis         RedisConfig
+	Sensors       SensorsConfig
+	Averaging     AveragingConfig
+	Alerts        AlertsConfig
+	Nodes         NodesConfig
+	Notifications NotificationsConfig
+	Ingest        IngestConfig
+	Light         LightConfig
+	Irrigation    IrrigationConfig
+	Substrate     SubstrateConfig
+	Rain          RainConfig
+	Crops         CropConfig
 }
 
 // Load loads configuration from environment variables with defaults
@@ -64,7 +200,7 @@ func Load() *Config {
 			Broker:   getEnv("MQTT_BROKER", "192.168.20.1"),
 			Port:     getEnvAsInt("MQTT_PORT", 1883),
 			ClientID: getEnv("MQTT_CLIENT_ID", "go-mqtt-subscriber-"+fmt.Sprintf("%d", time.Now().Unix())),
-			Topic:    getEnv("MQTT_TOPIC", "esp32/data"),
+			Topic:    getEnv("MQTT_TOPIC", "greenhouse/+/node/+/data"),
 			Username: getEnv("MQTT_USERNAME", ""),
 			Password: getEnv("MQTT_PASSWORD", ""),
 		},
@@ -81,6 +217,19 @@ func Load() *Config {
 			Token:  getEnv("INFLUXDB_TOKEN", "sR5sjCdApIph5swrk-wKJdJKTyGN20pOhIPrwI3OVUhHtkQD-N8VnPs6hASE7fS2Rajocv17Edh5hOIgT-Lerg=="),
 			Org:    getEnv("INFLUXDB_ORG", "iot-agriculture"),
 			Bucket: getEnv("INFLUXDB_BUCKET", "sensor_data"),
+			Raw: RawPersistenceConfig{
+				Bucket:        getEnv("INFLUXDB_RAW_BUCKET", "sensor_raw"),
+				Retention:     getEnvAsDuration("INFLUXDB_RAW_RETENTION", 7*24*time.Hour),
+				Nodes:         getEnvAsList("RAW_PERSISTENCE_NODES"),
+				BatchSize:     getEnvAsInt("INFLUXDB_RAW_BATCH_SIZE", 500),
+				FlushInterval: getEnvAsDuration("INFLUXDB_RAW_FLUSH_INTERVAL", time.Second),
+			},
+			WAL: WALConfig{
+				Dir:            getEnv("INFLUXDB_WAL_DIR", "data/wal"),
+				MaxBytes:       getEnvAsInt("INFLUXDB_WAL_MAX_BYTES", 256<<20),
+				SegmentBytes:   getEnvAsInt("INFLUXDB_WAL_SEGMENT_BYTES", 4<<20),
+				ReplayInterval: getEnvAsDuration("INFLUXDB_WAL_REPLAY_INTERVAL", 10*time.Second),
+			},
 		},
 		API: APIConfig{
 			Port: getEnv("API_PORT", "8080"),
@@ -90,7 +239,75 @@ func Load() *Config {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvAsInt("REDIS_DB", 0),
 		},
+		Sensors: loadSensorsConfig(getEnv("SENSOR_REGISTRY_FILE", "configs/sensors.json")),
+		Averaging: AveragingConfig{
+			Window:          getEnvAsDuration("AVERAGING_WINDOW", 60*time.Second),
+			AllowedLateness: getEnvAsDuration("AVERAGING_ALLOWED_LATENESS", 10*time.Second),
+			FlushInterval:   getEnvAsDuration("AVERAGING_FLUSH_INTERVAL", 5*time.Second),
+		},
+		Alerts: AlertsConfig{
+			RulesFile:   getEnv("ALERT_RULES_FILE", "data/alert_rules.json"),
+			HistorySize: getEnvAsInt("ALERT_HISTORY_SIZE", 1000),
+		},
+		Nodes: NodesConfig{
+			ExpectedInterval: getEnvAsDuration("NODE_EXPECTED_INTERVAL", 0),
+			StaleAfter:       getEnvAsDuration("NODE_STALE_AFTER", 2*time.Minute),
+			OfflineAfter:     getEnvAsDuration("NODE_OFFLINE_AFTER", 10*time.Minute),
+		},
+		Notifications: loadNotificationsConfig(getEnv("NOTIFICATIONS_FILE", "configs/notifications.json")),
+		Ingest: IngestConfig{
+			Workers:        getEnvAsInt("INGEST_WORKERS", 4),
+			QueueSize:      getEnvAsInt("INGEST_QUEUE_SIZE", 1000),
+			Backpressure:   getEnv("INGEST_BACKPRESSURE", BackpressureDropNewest),
+			BlockTimeout:   getEnvAsDuration("INGEST_BLOCK_TIMEOUT", time.Second),
+			DeadLetterFile: getEnv("INGEST_DEAD_LETTER_FILE", "data/dead_letters.jsonl"),
+			DeadLetterSize: getEnvAsInt("INGEST_DEAD_LETTER_SIZE", 1000),
+		},
+		Light: LightConfig{
+			Sensor:    getEnv("LIGHT_PAR_SENSOR", "Light_Par"),
+			StateFile: getEnv("LIGHT_STATE_FILE", "data/dli_state.json"),
+			MaxGap:    getEnvAsDuration("LIGHT_MAX_GAP", 5*time.Minute),
+			Target:    getEnvAsFloat("LIGHT_DLI_TARGET", 0),
+		},
+		Irrigation: IrrigationConfig{
+			Sensor:     getEnv("IRRIGATION_SENSOR", "drip_weight"),
+			StartRate:  getEnvAsFloat("IRRIGATION_START_RATE", 50),
+			MinSupply:  getEnvAsFloat("IRRIGATION_MIN_SUPPLY", 20),
+			StopAfter:  getEnvAsDuration("IRRIGATION_STOP_AFTER", time.Minute),
+			SettleTime: getEnvAsDuration("IRRIGATION_SETTLE_TIME", 10*time.Minute),
+		},
+		Substrate: SubstrateConfig{
+			Probes:    getEnvAsList("SUBSTRATE_PROBES"),
+			Windows:   getEnvAsInt("SUBSTRATE_WINDOWS", 15),
+			Deviation: getEnvAsFloat("SUBSTRATE_DEVIATION", 15),
+		},
+		Rain: RainConfig{
+			Sensor: getEnv("RAIN_SENSOR", "Rain"),
+			MaxGap: getEnvAsDuration("RAIN_MAX_GAP", 5*time.Minute),
+		},
+		Crops: CropConfig{
+			File:           getEnv("CROP_FILE", "data/crops.json"),
+			Sensor:         getEnv("CROP_GDD_SENSOR", "Air_Temp"),
+			ProjectionDays: getEnvAsInt("CROP_PROJECTION_DAYS", 7),
+		},
 	}
+	if len(config.Substrate.Probes) == 0 {
+		config.Substrate.Probes = []string{"Bag_Rh1", "Bag_Rh2", "Bag_Rh3", "Bag_Rh4"}
+	}
+
+	subscriptions, err := parseSubscriptions(os.Getenv("MQTT_SUBSCRIPTIONS"), config.MQTT.Topic)
+	if err != nil {
+		log.Fatalf("Invalid MQTT_SUBSCRIPTIONS: %v", err)
+	}
+	config.MQTT.Subscriptions = subscriptions
+	config.MQTT.SharedGroup = getEnv("MQTT_SHARED_GROUP", "")
+	config.MQTT.IdentityPolicy = getEnv("MQTT_IDENTITY_POLICY", IdentityReject)
+	config.MQTT.QuarantineLog = getEnv("MQTT_QUARANTINE_LOG", "data/quarantine.log")
+
+	config.Light.Location = loadLocation("LIGHT_TIMEZONE", time.Local)
+	config.Irrigation.Location = loadLocation("IRRIGATION_TIMEZONE", config.Light.Location)
+	config.Rain.Location = loadLocation("RAIN_TIMEZONE", config.Light.Location)
+	config.Crops.Location = loadLocation("CROP_TIMEZONE", config.Light.Location)
 
 	// Validate critical configuration
 	config.validate()
@@ -102,8 +319,56 @@ func (c *Config) validate() {
 	if c.MQTT.Broker == "" {
 		log.Fatal("MQTT_BROKER environment variable is required")
 	}
-	if c.MQTT.Topic == "" {
-		log.Fatal("MQTT_TOPIC environment variable is required")
+	if err := c.MQTT.validate(); err != nil {
+		log.Fatalf("Invalid MQTT configuration: %v", err)
+	}
+	if c.Averaging.Window <= 0 || c.Averaging.FlushInterval <= 0 {
+		log.Fatal("AVERAGING_WINDOW and AVERAGING_FLUSH_INTERVAL must be positive durations")
+	}
+	if (15*time.Minute)%c.Averaging.Window != 0 {
+		log.Fatal("AVERAGING_WINDOW must evenly divide 15 minutes so windows align with rollups")
+	}
+	if c.Averaging.AllowedLateness < 0 {
+		log.Fatal("AVERAGING_ALLOWED_LATENESS must not be negative")
+	}
+	if err := c.InfluxDB.Raw.validate(); err != nil {
+		log.Fatalf("Invalid raw persistence configuration: %v", err)
+	}
+	if c.InfluxDB.WAL.Dir == "off" {
+		c.InfluxDB.WAL.Dir = ""
+	}
+	if c.InfluxDB.WAL.Dir != "" && (c.InfluxDB.WAL.MaxBytes <= 0 || c.InfluxDB.WAL.SegmentBytes <= 0 || c.InfluxDB.WAL.ReplayInterval <= 0) {
+		log.Fatal("INFLUXDB_WAL_MAX_BYTES, INFLUXDB_WAL_SEGMENT_BYTES and INFLUXDB_WAL_REPLAY_INTERVAL must be positive")
+	}
+	if c.Alerts.HistorySize < 0 {
+		log.Fatal("ALERT_HISTORY_SIZE must not be negative")
+	}
+	if c.Nodes.ExpectedInterval < 0 || c.Nodes.StaleAfter <= 0 || c.Nodes.OfflineAfter <= c.Nodes.StaleAfter {
+		log.Fatal("NODE_STALE_AFTER must be positive and less than NODE_OFFLINE_AFTER, and NODE_EXPECTED_INTERVAL must not be negative")
+	}
+	if err := c.Ingest.validate(); err != nil {
+		log.Fatalf("Invalid ingest configuration: %v", err)
+	}
+	if err := c.Notifications.validate(); err != nil {
+		log.Fatalf("Invalid notifications configuration %s: %v", c.Notifications.File, err)
+	}
+	if err := c.Sensors.validate(); err != nil {
+		log.Fatalf("Invalid sensor registry %s: %v", c.Sensors.File, err)
+	}
+	if err := c.Light.validate(&c.Sensors); err != nil {
+		log.Fatalf("Invalid light configuration: %v", err)
+	}
+	if err := c.Irrigation.validate(&c.Sensors); err != nil {
+		log.Fatalf("Invalid irrigation configuration: %v", err)
+	}
+	if err := c.Substrate.validate(&c.Sensors); err != nil {
+		log.Fatalf("Invalid substrate configuration: %v", err)
+	}
+	if err := c.Rain.validate(&c.Sensors); err != nil {
+		log.Fatalf("Invalid rain configuration: %v", err)
+	}
+	if err := c.Crops.validate(&c.Sensors); err != nil {
+		log.Fatalf("Invalid crop configuration: %v", err)
 	}
 	// Note: INFLUXDB_TOKEN is optional - service will disable logging if not provided
 }
@@ -126,8 +391,310 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAsDuration gets an environment variable as a duration (e.g. "30s", "5m") or returns a default value
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsFloat gets an environment variable as a float or returns a default value
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsList gets a comma-separated environment variable as a list of non-empty, trimmed values
+func getEnvAsList(key string) []string {
+	var out []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// loadLocation loads the IANA time zone named by an environment variable,
+// or returns fallback if it is not set. An unknown zone is fatal
+func loadLocation(key string, fallback *time.Location) *time.Location {
+	name := os.Getenv(key)
+	if name == "" {
+		return fallback
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return location
+}
+
+// validate checks the raw persistence node patterns and batching settings
+func (c *RawPersistenceConfig) validate() error {
+	for _, pattern := range c.Nodes {
+		if pattern == "*" {
+			continue
+		}
+		if strings.Count(pattern, "/") != 1 {
+			return fmt.Errorf("node pattern %q must have the form greenhouse_id/node_id", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("node pattern %q: %w", pattern, err)
+		}
+	}
+	if c.Bucket == "" {
+		return fmt.Errorf("INFLUXDB_RAW_BUCKET must not be empty")
+	}
+	if c.BatchSize <= 0 || c.FlushInterval <= 0 {
+		return fmt.Errorf("INFLUXDB_RAW_BATCH_SIZE and INFLUXDB_RAW_FLUSH_INTERVAL must be positive")
+	}
+	if c.Retention < 0 {
+		return fmt.Errorf("INFLUXDB_RAW_RETENTION must not be negative")
+	}
+	return nil
+}
+
+// Enabled returns true if raw readings of the given node should be persisted
+// Patterns are matched against "greenhouse_id/node_id"; "*" matches every node
+func (c *RawPersistenceConfig) Enabled(greenhouseID, nodeID string) bool {
+	for _, pattern := range c.Nodes {
+		if pattern == "*" {
+			return true
+		}
+		if ok, _ := path.Match(pattern, greenhouseID+"/"+nodeID); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSubscriptions parses MQTT_SUBSCRIPTIONS, a comma-separated list of
+// handler=topic_filter[@qos] entries. An empty list subscribes the data handler
+// to dataTopic and the status handler to the node and device status topics
+func parseSubscriptions(value, dataTopic string) ([]SubscriptionConfig, error) {
+	if strings.TrimSpace(value) == "" {
+		return []SubscriptionConfig{
+			{Handler: HandlerData, Topic: dataTopic, QoS: 1},
+			{Handler: HandlerStatus, Topic: "greenhouse/+/node/+/status", QoS: 1},
+			{Handler: HandlerStatus, Topic: "+/status", QoS: 1},
+		}, nil
+	}
+
+	var subs []SubscriptionConfig
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		handler, topic, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("entry %q must have the form handler=topic[@qos]", entry)
+		}
+		sub := SubscriptionConfig{Handler: strings.TrimSpace(handler), Topic: strings.TrimSpace(topic), QoS: 1}
+		if i := strings.LastIndex(sub.Topic, "@"); i >= 0 {
+			qos, err := strconv.Atoi(sub.Topic[i+1:])
+			if err != nil || qos < 0 || qos > 2 {
+				return nil, fmt.Errorf("entry %q: QoS must be 0, 1 or 2", entry)
+			}
+			sub.Topic, sub.QoS = sub.Topic[:i], byte(qos)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// validate checks the subscriptions and applies MQTT_SHARED_GROUP
+func (c *MQTTConfig) validate() error {
+	if len(c.Subscriptions) == 0 {
+		return fmt.Errorf("no subscriptions configured")
+	}
+	switch c.IdentityPolicy {
+	case IdentityTrustTopic, IdentityTrustPayload, IdentityReject:
+	default:
+		return fmt.Errorf("MQTT_IDENTITY_POLICY must be topic, payload or reject")
+	}
+	if c.QuarantineLog == "off" {
+		c.QuarantineLog = ""
+	}
+	if strings.ContainsAny(c.SharedGroup, "/+#") {
+		return fmt.Errorf("MQTT_SHARED_GROUP must not contain '/', '+' or '#'")
+	}
+	for i := range c.Subscriptions {
+		sub := &c.Subscriptions[i]
+		switch sub.Handler {
+		case HandlerData, HandlerStatus, HandlerTelemetry, HandlerAcks:
+		default:
+			return fmt.Errorf("unknown handler %q (expected data, status, telemetry or acks)", sub.Handler)
+		}
+		if c.SharedGroup != "" && (sub.Handler == HandlerData || sub.Handler == HandlerTelemetry) &&
+			!strings.HasPrefix(sub.Topic, "$share/") {
+			sub.Topic = "$share/" + c.SharedGroup + "/" + sub.Topic
+		}
+		if err := validateTopicFilter(sub.Topic); err != nil {
+			return fmt.Errorf("%s subscription: %w", sub.Handler, err)
+		}
+	}
+	return nil
+}
+
+// validateTopicFilter checks MQTT wildcard placement and the $share/<group>/ prefix
+func validateTopicFilter(filter string) error {
+	topic := filter
+	if strings.HasPrefix(filter, "$share/") {
+		parts := strings.SplitN(filter, "/", 3)
+		if len(parts) < 3 || parts[1] == "" || strings.ContainsAny(parts[1], "+#") || parts[2] == "" {
+			return fmt.Errorf("shared subscription %q must have the form $share/<group>/<filter>", filter)
+		}
+		topic = parts[2]
+	}
+	if topic == "" {
+		return fmt.Errorf("empty topic filter")
+	}
+	levels := strings.Split(topic, "/")
+	for i, level := range levels {
+		if level == "#" && i != len(levels)-1 {
+			return fmt.Errorf("topic filter %q: '#' must be the last level", filter)
+		}
+		if level != "+" && level != "#" && strings.ContainsAny(level, "+#") {
+			return fmt.Errorf("topic filter %q: wildcards must occupy a whole level", filter)
+		}
+	}
+	return nil
+}
+
 // String returns a string representation of the MQTT configuration
 func (c *MQTTConfig) String() string {
-	return fmt.Sprintf("MQTT Broker: %s:%d, Topic: %s, ClientID: %s",
-		c.Broker, c.Port, c.Topic, c.ClientID)
+	topics := make([]string, len(c.Subscriptions))
+	for i, sub := range c.Subscriptions {
+		topics[i] = sub.Handler + "=" + sub.Topic
+	}
+	return fmt.Sprintf("MQTT Broker: %s:%d, Subscriptions: %s, ClientID: %s",
+		c.Broker, c.Port, strings.Join(topics, ", "), c.ClientID)
+}
+
+// validate checks the worker pool settings and applies "off" to the dead-letter file
+func (c *IngestConfig) validate() error {
+	if c.Workers < 1 || c.QueueSize < 1 {
+		return fmt.Errorf("INGEST_WORKERS and INGEST_QUEUE_SIZE must be at least 1")
+	}
+	switch c.Backpressure {
+	case BackpressureDropNewest, BackpressureDropOldest:
+	case BackpressureBlock:
+		if c.BlockTimeout <= 0 {
+			return fmt.Errorf("INGEST_BLOCK_TIMEOUT must be positive with the block policy")
+		}
+	default:
+		return fmt.Errorf("unknown INGEST_BACKPRESSURE %q (expected %s, %s or %s)",
+			c.Backpressure, BackpressureDropNewest, BackpressureDropOldest, BackpressureBlock)
+	}
+	if c.DeadLetterFile == "off" {
+		c.DeadLetterFile = ""
+	}
+	if c.DeadLetterSize < 0 {
+		return fmt.Errorf("INGEST_DEAD_LETTER_SIZE must not be negative")
+	}
+	return nil
+}
+
+// validate checks the DLI settings against the sensor registry and applies "off"
+func (c *LightConfig) validate(sensors *SensorsConfig) error {
+	if c.Sensor == "off" {
+		c.Sensor = ""
+	}
+	if c.StateFile == "off" {
+		c.StateFile = ""
+	}
+	if c.Sensor == "" {
+		return nil
+	}
+	if _, ok := sensors.sensor(c.Sensor); !ok {
+		return fmt.Errorf("LIGHT_PAR_SENSOR %q is not in the sensor registry", c.Sensor)
+	}
+	if c.MaxGap <= 0 {
+		return fmt.Errorf("LIGHT_MAX_GAP must be positive")
+	}
+	if c.Target < 0 {
+		return fmt.Errorf("LIGHT_DLI_TARGET must not be negative")
+	}
+	return nil
+}
+
+// validate checks the irrigation detection settings against the sensor registry and applies "off"
+func (c *IrrigationConfig) validate(sensors *SensorsConfig) error {
+	if c.Sensor == "off" {
+		c.Sensor = ""
+	}
+	if c.Sensor == "" {
+		return nil
+	}
+	if _, ok := sensors.sensor(c.Sensor); !ok {
+		return fmt.Errorf("IRRIGATION_SENSOR %q is not in the sensor registry", c.Sensor)
+	}
+	if c.StartRate <= 0 || c.MinSupply < 0 {
+		return fmt.Errorf("IRRIGATION_START_RATE must be positive and IRRIGATION_MIN_SUPPLY must not be negative")
+	}
+	if c.StopAfter <= 0 || c.SettleTime <= 0 {
+		return fmt.Errorf("IRRIGATION_STOP_AFTER and IRRIGATION_SETTLE_TIME must be positive")
+	}
+	return nil
+}
+
+// validate checks the substrate probes against the sensor registry and applies "off"
+func (c *SubstrateConfig) validate(sensors *SensorsConfig) error {
+	if len(c.Probes) == 1 && c.Probes[0] == "off" {
+		c.Probes = nil
+	}
+	if len(c.Probes) == 0 {
+		return nil
+	}
+	for _, probe := range c.Probes {
+		if _, ok := sensors.sensor(probe); !ok {
+			return fmt.Errorf("SUBSTRATE_PROBES: %q is not in the sensor registry", probe)
+		}
+	}
+	if c.Windows < 2 {
+		return fmt.Errorf("SUBSTRATE_WINDOWS must be at least 2")
+	}
+	if c.Deviation <= 0 {
+		return fmt.Errorf("SUBSTRATE_DEVIATION must be positive")
+	}
+	return nil
+}
+
+// validate checks the rain detection settings against the sensor registry and applies "off"
+func (c *RainConfig) validate(sensors *SensorsConfig) error {
+	if c.Sensor == "off" {
+		c.Sensor = ""
+	}
+	if c.Sensor == "" {
+		return nil
+	}
+	if _, ok := sensors.sensor(c.Sensor); !ok {
+		return fmt.Errorf("RAIN_SENSOR %q is not in the sensor registry", c.Sensor)
+	}
+	if c.MaxGap <= 0 {
+		return fmt.Errorf("RAIN_MAX_GAP must be positive")
+	}
+	return nil
+}
+
+// validate checks the GDD sensor against the sensor registry and applies "off" to the plantings file
+func (c *CropConfig) validate(sensors *SensorsConfig) error {
+	if c.File == "off" {
+		c.File = ""
+	}
+	if _, ok := sensors.sensor(c.Sensor); !ok {
+		return fmt.Errorf("CROP_GDD_SENSOR %q is not in the sensor registry", c.Sensor)
+	}
+	if c.ProjectionDays < 1 {
+		return fmt.Errorf("CROP_PROJECTION_DAYS must be at least 1")
+	}
+	return nil
 }
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Notification channel types
+const (
+	ChannelWebhook = "webhook"
+	ChannelSMTP    = "smtp"
+	ChannelMQTT    = "mqtt"
+)
+
+// Duration is a time.Duration read from JSON as a string such as "30s" or "5m"
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON formats the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// WebhookChannelConfig configures a JSON webhook
+type WebhookChannelConfig struct {
+	URL          string            `json:"url"`
+	Secret       string            `json:"secret,omitempty"` // HMAC-SHA256 signing key (empty = unsigned)
+	Headers      map[string]string `json:"headers,omitempty"`
+	Timeout      Duration          `json:"timeout,omitempty"`       // Per attempt (default 10s)
+	MaxRetries   int               `json:"max_retries,omitempty"`   // Retries after the first attempt (default 3)
+	RetryBackoff Duration          `json:"retry_backoff,omitempty"` // Doubled after every retry (default 1s)
+}
+
+// SMTPChannelConfig configures email delivery
+type SMTPChannelConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port,omitempty"` // Default 587
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	Timeout  Duration `json:"timeout,omitempty"` // Whole conversation (default 30s)
+}
+
+// MQTTChannelConfig configures publishing alerts to the MQTT broker
+type MQTTChannelConfig struct {
+	Topic  string `json:"topic,omitempty"` // {greenhouse_id} and {node_id} are substituted (default greenhouse/{greenhouse_id}/alerts)
+	QoS    byte   `json:"qos,omitempty"`
+	Retain bool   `json:"retain,omitempty"`
+}
+
+// NotificationChannelConfig describes one alert notification channel and which alerts it receives
+type NotificationChannelConfig struct {
+	Name        string                `json:"name"`
+	Type        string                `json:"type"`                   // webhook, smtp or mqtt
+	Severities  []string              `json:"severities,omitempty"`   // Empty = every severity
+	Greenhouses []string              `json:"greenhouses,omitempty"`  // Empty = every greenhouse
+	DedupWindow Duration              `json:"dedup_window,omitempty"` // Repeats of the same alert state are dropped within this window
+	RateLimit   int                   `json:"rate_limit,omitempty"`   // Max notifications per rate_window (0 = unlimited)
+	RateWindow  Duration              `json:"rate_window,omitempty"`  // Default 1h
+	Webhook     *WebhookChannelConfig `json:"webhook,omitempty"`
+	SMTP        *SMTPChannelConfig    `json:"smtp,omitempty"`
+	MQTT        *MQTTChannelConfig    `json:"mqtt,omitempty"`
+}
+
+// NotificationsConfig holds the alert notification channels
+type NotificationsConfig struct {
+	File     string                      `json:"-"`
+	Channels []NotificationChannelConfig `json:"channels"`
+}
+
+// loadNotificationsConfig loads the notification channels from a JSON file.
+// A missing file means no channels. ${VAR} references in URLs, secrets and
+// passwords are expanded from the environment so they need not be committed
+func loadNotificationsConfig(path string) NotificationsConfig {
+	cfg := NotificationsConfig{File: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg
+	}
+	if err != nil {
+		log.Fatalf("Failed to read notifications file %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		log.Fatalf("Failed to parse notifications file %s: %v", path, err)
+	}
+	cfg.File = path
+
+	for i := range cfg.Channels {
+		ch := &cfg.Channels[i]
+		if ch.Webhook != nil {
+			ch.Webhook.URL = os.ExpandEnv(ch.Webhook.URL)
+			ch.Webhook.Secret = os.ExpandEnv(ch.Webhook.Secret)
+		}
+		if ch.SMTP != nil {
+			ch.SMTP.Username = os.ExpandEnv(ch.SMTP.Username)
+			ch.SMTP.Password = os.ExpandEnv(ch.SMTP.Password)
+		}
+	}
+	log.Printf("Loaded %d notification channels from %s", len(cfg.Channels), path)
+	return cfg
+}
+
+// validate checks the notification channels and fills in defaults
+func (c *NotificationsConfig) validate() error {
+	names := make(map[string]bool)
+	for i := range c.Channels {
+		ch := &c.Channels[i]
+		if ch.Name == "" {
+			return fmt.Errorf("channel #%d has no name", i+1)
+		}
+		if names[ch.Name] {
+			return fmt.Errorf("duplicate channel name: %s", ch.Name)
+		}
+		names[ch.Name] = true
+
+		for _, severity := range ch.Severities {
+			if severity != "info" && severity != "warning" && severity != "critical" {
+				return fmt.Errorf("channel %s: unknown severity %q", ch.Name, severity)
+			}
+		}
+		if ch.DedupWindow < 0 || ch.RateLimit < 0 || ch.RateWindow < 0 {
+			return fmt.Errorf("channel %s: dedup_window, rate_limit and rate_window must not be negative", ch.Name)
+		}
+		if ch.RateWindow == 0 {
+			ch.RateWindow = Duration(time.Hour)
+		}
+
+		if err := ch.validateTarget(); err != nil {
+			return fmt.Errorf("channel %s: %w", ch.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateTarget checks the settings of the channel's type and fills in defaults
+func (ch *NotificationChannelConfig) validateTarget() error {
+	switch ch.Type {
+	case ChannelWebhook:
+		w := ch.Webhook
+		if w == nil || (!strings.HasPrefix(w.URL, "http://") && !strings.HasPrefix(w.URL, "https://")) {
+			return fmt.Errorf("webhook.url must be an http(s) URL")
+		}
+		if w.Timeout == 0 {
+			w.Timeout = Duration(10 * time.Second)
+		}
+		if w.RetryBackoff == 0 {
+			w.RetryBackoff = Duration(time.Second)
+		}
+		if w.MaxRetries == 0 {
+			w.MaxRetries = 3
+		}
+		if w.Timeout < 0 || w.RetryBackoff < 0 || w.MaxRetries < 0 {
+			return fmt.Errorf("webhook timeout, retry_backoff and max_retries must not be negative")
+		}
+	case ChannelSMTP:
+		s := ch.SMTP
+		if s == nil || s.Host == "" || s.From == "" || len(s.To) == 0 {
+			return fmt.Errorf("smtp.host, smtp.from and smtp.to are required")
+		}
+		if s.Port == 0 {
+			s.Port = 587
+		}
+		if s.Timeout == 0 {
+			s.Timeout = Duration(30 * time.Second)
+		}
+		if s.Timeout < 0 {
+			return fmt.Errorf("smtp.timeout must not be negative")
+		}
+	case ChannelMQTT:
+		if ch.MQTT == nil {
+			ch.MQTT = &MQTTChannelConfig{}
+		}
+		if ch.MQTT.Topic == "" {
+			ch.MQTT.Topic = "greenhouse/{greenhouse_id}/alerts"
+		}
+		if ch.MQTT.QoS > 2 {
+			return fmt.Errorf("mqtt.qos must be 0, 1 or 2")
+		}
+		if strings.ContainsAny(ch.MQTT.Topic, "+#") {
+			return fmt.Errorf("mqtt.topic must not contain wildcards")
+		}
+	default:
+		return fmt.Errorf("unknown type %q (expected webhook, smtp or mqtt)", ch.Type)
+	}
+	return nil
+}
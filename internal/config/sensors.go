@@ -0,0 +1,212 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// SensorDefinition describes a single sensor channel published by the nodes
+type SensorDefinition struct {
+	Name      string   `json:"name"`                 // Canonical name used in the API and InfluxDB field names
+	JSONKey   string   `json:"json_key"`             // Key in the ESP32 JSON payload
+	Unit      string   `json:"unit"`                 // Canonical unit of the reading
+	Type      string   `json:"type"`                 // Value type on the wire (float or int)
+	Scale     *float64 `json:"scale,omitempty"`      // Factor converting the wire value to the unit, e.g. 0.1 for tenths (optional)
+	Precision *int     `json:"precision,omitempty"`  // Decimal places readings and aggregates are rounded to (optional)
+	Min       *float64 `json:"min,omitempty"`        // Lowest physically valid value in the unit (optional)
+	Max       *float64 `json:"max,omitempty"`        // Highest physically valid value in the unit (optional)
+	NodeTypes []string `json:"node_types,omitempty"` // Node types publishing this sensor (empty = all)
+	Kind      string   `json:"kind,omitempty"`       // measurement (default) or state, e.g. a 0/1 flag that is not averaged into windows
+}
+
+// NodeTypeDefinition groups the nodes that publish the same set of sensors
+type NodeTypeDefinition struct {
+	Name     string   `json:"name"`
+	Nodes    []string `json:"nodes"`
+	Required []string `json:"required,omitempty"` // Sensors every message from these nodes must carry
+}
+
+// SensorsConfig holds the sensor registry configuration
+type SensorsConfig struct {
+	File      string               `json:"-"`
+	Sensors   []SensorDefinition   `json:"sensors"`
+	NodeTypes []NodeTypeDefinition `json:"node_types"`
+}
+
+// Supported sensor value types
+const (
+	SensorTypeFloat = "float" // Any JSON number
+	SensorTypeInt   = "int"   // Whole numbers only; stored as integers in the raw bucket unless scaled
+)
+
+// Supported sensor kinds
+const (
+	SensorKindMeasurement = "measurement" // Continuous reading aggregated into windows and rollups
+	SensorKindState       = "state"       // Discrete state stored raw but never aggregated; averaging it is meaningless
+)
+
+// maxSensorPrecision is the largest number of decimal places a sensor may declare
+const maxSensorPrecision = 6
+
+// loadSensorsConfig loads the sensor registry from a JSON file,
+// falling back to the built-in defaults when the file does not exist
+func loadSensorsConfig(path string) SensorsConfig {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("Sensor registry file %s not found - using built-in sensor definitions", path)
+		cfg := defaultSensorsConfig()
+		cfg.File = path
+		return cfg
+	}
+	if err != nil {
+		log.Fatalf("Failed to read sensor registry file %s: %v", path, err)
+	}
+
+	var cfg SensorsConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		log.Fatalf("Failed to parse sensor registry file %s: %v", path, err)
+	}
+	cfg.File = path
+	log.Printf("Loaded %d sensor definitions from %s", len(cfg.Sensors), path)
+	return cfg
+}
+
+// validate checks the sensor registry for missing or duplicate entries
+func (c *SensorsConfig) validate() error {
+	if len(c.Sensors) == 0 {
+		return fmt.Errorf("sensor registry defines no sensors")
+	}
+
+	nodeTypes := make(map[string]bool)
+	nodes := make(map[string]string)
+	for _, nt := range c.NodeTypes {
+		if nt.Name == "" {
+			return fmt.Errorf("node type without a name")
+		}
+		if nodeTypes[nt.Name] {
+			return fmt.Errorf("duplicate node type: %s", nt.Name)
+		}
+		nodeTypes[nt.Name] = true
+		for _, node := range nt.Nodes {
+			if other, ok := nodes[node]; ok {
+				return fmt.Errorf("node %s assigned to both %s and %s", node, other, nt.Name)
+			}
+			nodes[node] = nt.Name
+		}
+	}
+
+	names := make(map[string]bool)
+	keys := make(map[string]bool)
+	for i := range c.Sensors {
+		s := &c.Sensors[i]
+		if s.Name == "" {
+			return fmt.Errorf("sensor #%d has no name", i+1)
+		}
+		if s.JSONKey == "" {
+			s.JSONKey = s.Name
+		}
+		if s.Type == "" {
+			s.Type = SensorTypeFloat
+		}
+		if s.Kind == "" {
+			s.Kind = SensorKindMeasurement
+		}
+		if names[s.Name] {
+			return fmt.Errorf("duplicate sensor name: %s", s.Name)
+		}
+		if keys[s.JSONKey] {
+			return fmt.Errorf("duplicate sensor JSON key: %s", s.JSONKey)
+		}
+		names[s.Name] = true
+		keys[s.JSONKey] = true
+
+		if s.Type != SensorTypeFloat && s.Type != SensorTypeInt {
+			return fmt.Errorf("sensor %s has unsupported type %q (expected %s or %s)", s.Name, s.Type, SensorTypeFloat, SensorTypeInt)
+		}
+		if s.Kind != SensorKindMeasurement && s.Kind != SensorKindState {
+			return fmt.Errorf("sensor %s has unsupported kind %q (expected %s or %s)", s.Name, s.Kind, SensorKindMeasurement, SensorKindState)
+		}
+		if s.Scale != nil && *s.Scale <= 0 {
+			return fmt.Errorf("sensor %s has a scale that is not positive", s.Name)
+		}
+		if s.Precision != nil && (*s.Precision < 0 || *s.Precision > maxSensorPrecision) {
+			return fmt.Errorf("sensor %s has precision %d (expected 0-%d)", s.Name, *s.Precision, maxSensorPrecision)
+		}
+		if s.Min != nil && s.Max != nil && *s.Min > *s.Max {
+			return fmt.Errorf("sensor %s has min greater than max", s.Name)
+		}
+		for _, nt := range s.NodeTypes {
+			if !nodeTypes[nt] {
+				return fmt.Errorf("sensor %s references unknown node type %s", s.Name, nt)
+			}
+		}
+	}
+
+	for _, nt := range c.NodeTypes {
+		for _, name := range nt.Required {
+			s, ok := c.sensor(name)
+			if !ok {
+				return fmt.Errorf("node type %s requires unknown sensor %s", nt.Name, name)
+			}
+			if len(s.NodeTypes) > 0 && !containsString(s.NodeTypes, nt.Name) {
+				return fmt.Errorf("node type %s requires sensor %s, which it does not publish", nt.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// sensor returns the definition of the named sensor
+func (c *SensorsConfig) sensor(name string) (SensorDefinition, bool) {
+	for _, s := range c.Sensors {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return SensorDefinition{}, false
+}
+
+// defaultSensorsConfig returns the sensor set published by the stock ESP32 firmware
+func defaultSensorsConfig() SensorsConfig {
+	substrate := []string{"substrate"}
+	all := []string{"substrate", "weather"}
+	weather := []string{"weather"}
+
+	return SensorsConfig{
+		NodeTypes: []NodeTypeDefinition{
+			{Name: "substrate", Nodes: []string{"Node01", "Node02", "Node03", "Node04"}},
+			{Name: "weather", Nodes: []string{"Node05"}},
+		},
+		Sensors: []SensorDefinition{
+			{Name: "Bag_Temp", JSONKey: "Bag_Temp", Unit: "°C", Type: SensorTypeFloat, Min: floatPtr(-20), Max: floatPtr(80), NodeTypes: substrate},
+			{Name: "Light_Par", JSONKey: "Light_Par", Unit: "µmol/m²/s", Type: SensorTypeFloat, Min: floatPtr(0), Max: floatPtr(3000), NodeTypes: all},
+			{Name: "Air_Temp", JSONKey: "Air_Temp", Unit: "°C", Type: SensorTypeFloat, Min: floatPtr(-40), Max: floatPtr(80), NodeTypes: all},
+			{Name: "Air_Rh", JSONKey: "Air_Rh", Unit: "%RH", Type: SensorTypeFloat, Min: floatPtr(0), Max: floatPtr(100), NodeTypes: all},
+			{Name: "Leaf_temp", JSONKey: "Leaf_temp", Unit: "°C", Type: SensorTypeFloat, Min: floatPtr(-20), Max: floatPtr(80), NodeTypes: substrate},
+			{Name: "drip_weight", JSONKey: "drip_weight", Unit: "g", Type: SensorTypeFloat, Min: floatPtr(0), Max: floatPtr(100000), NodeTypes: substrate},
+			{Name: "Bag_Rh1", JSONKey: "Bag_Rh1", Unit: "%RH", Type: SensorTypeFloat, Min: floatPtr(0), Max: floatPtr(100), NodeTypes: substrate},
+			{Name: "Bag_Rh2", JSONKey: "Bag_Rh2", Unit: "%RH", Type: SensorTypeFloat, Min: floatPtr(0), Max: floatPtr(100), NodeTypes: substrate},
+			{Name: "Bag_Rh3", JSONKey: "Bag_Rh3", Unit: "%RH", Type: SensorTypeFloat, Min: floatPtr(0), Max: floatPtr(100), NodeTypes: substrate},
+			{Name: "Bag_Rh4", JSONKey: "Bag_Rh4", Unit: "%RH", Type: SensorTypeFloat, Min: floatPtr(0), Max: floatPtr(100), NodeTypes: substrate},
+			{Name: "Rain", JSONKey: "Rain", Unit: "", Type: SensorTypeInt, Min: floatPtr(0), Max: floatPtr(1), NodeTypes: weather, Kind: SensorKindState},
+		},
+	}
+}
+
+// floatPtr returns a pointer to v, for optional range bounds
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// containsString returns true if the slice contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+// Package ingest queues data messages from the MQTT client and processes them on
+// a pool of workers. Messages are sharded by greenhouse and node, so each node's
+// messages are processed in the order they arrived while different nodes are
+// processed in parallel
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+)
+
+// Reasons a message is dropped instead of processed
+const (
+	DropQueueFull = "queue_full" // Queue full under drop_newest
+	DropEvicted   = "evicted"    // Oldest queued message evicted under drop_oldest
+	DropTimeout   = "timeout"    // No room within the block timeout
+	DropShutdown  = "shutdown"   // Received after the pool was closed
+)
+
+// Latency stages reported to Metrics
+const (
+	StageQueue   = "queue"   // From Submit until a worker picks the message up
+	StageProcess = "process" // Handler run time
+)
+
+// Handler processes one message (same signature as mqtt.MessageHandler)
+type Handler func(ctx context.Context, topic string, payload []byte)
+
+// Metrics receives the pool's queue depth, drops and latencies
+// (implemented by services.MetricsService)
+type Metrics interface {
+	SetIngestQueueDepth(worker, depth int)
+	IncrementIngestDropped(reason string)
+	ObserveIngestLatency(stage string, d time.Duration)
+}
+
+// message is a queued data message
+type message struct {
+	ctx      context.Context
+	topic    string
+	payload  []byte
+	received time.Time
+}
+
+// Pool is a sharded pool of workers processing data messages
+type Pool struct {
+	cfg     *config.IngestConfig
+	handler Handler
+	metrics Metrics
+	queues  []chan message
+
+	mu     sync.RWMutex // Held for reading while enqueueing, for writing to close the queues
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewPool starts cfg.Workers workers calling handler for each submitted message
+func NewPool(cfg *config.IngestConfig, handler Handler, metrics Metrics) *Pool {
+	p := &Pool{
+		cfg:     cfg,
+		handler: handler,
+		metrics: metrics,
+		queues:  make([]chan message, cfg.Workers),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan message, cfg.QueueSize)
+		p.wg.Add(1)
+		go p.work(i)
+	}
+	return p
+}
+
+// Submit queues a message on its node's worker, applying the backpressure policy
+// when the queue is full. It has the signature of an MQTT message handler
+func (p *Pool) Submit(ctx context.Context, topic string, payload []byte) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		p.drop(topic, DropShutdown)
+		return
+	}
+
+	worker := p.shard(ShardKey(topic, payload))
+	queue := p.queues[worker]
+	msg := message{ctx: ctx, topic: topic, payload: payload, received: time.Now()}
+
+	select {
+	case queue <- msg:
+		p.metrics.SetIngestQueueDepth(worker, len(queue))
+		return
+	default:
+	}
+
+	switch p.cfg.Backpressure {
+	case config.BackpressureDropOldest:
+		// Evict the oldest messages until there is room (the worker may free a slot first)
+		for {
+			select {
+			case queue <- msg:
+				p.metrics.SetIngestQueueDepth(worker, len(queue))
+				return
+			default:
+			}
+			select {
+			case old := <-queue:
+				p.drop(old.topic, DropEvicted)
+			default:
+			}
+		}
+	case config.BackpressureBlock:
+		timer := time.NewTimer(p.cfg.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case queue <- msg:
+			p.metrics.SetIngestQueueDepth(worker, len(queue))
+		case <-timer.C:
+			p.drop(topic, DropTimeout)
+		}
+	default:
+		p.drop(topic, DropQueueFull)
+	}
+}
+
+// Close stops accepting messages and waits until the queued ones are processed
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// work processes one worker's queue until it is closed
+func (p *Pool) work(worker int) {
+	defer p.wg.Done()
+	queue := p.queues[worker]
+	for msg := range queue {
+		p.metrics.SetIngestQueueDepth(worker, len(queue))
+		start := time.Now()
+		p.metrics.ObserveIngestLatency(StageQueue, start.Sub(msg.received))
+		p.handler(msg.ctx, msg.topic, msg.payload)
+		p.metrics.ObserveIngestLatency(StageProcess, time.Since(start))
+	}
+}
+
+// drop logs and counts a message that will not be processed
+func (p *Pool) drop(topic, reason string) {
+	log.Printf("WARNING: Dropping MQTT message for topic %s (%s)", topic, reason)
+	p.metrics.IncrementIngestDropped(reason)
+}
+
+// shard returns the worker serving a shard key
+func (p *Pool) shard(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// ShardKey returns the greenhouse_id|node_id a message belongs to, taken from a
+// greenhouse/{greenhouse_id}/node/{node_id}/... topic or else from the payload's
+// IDs. Messages without either are keyed by topic
+func ShardKey(topic string, payload []byte) string {
+	parts := strings.Split(topic, "/")
+	if len(parts) >= 4 && parts[0] == "greenhouse" && parts[2] == "node" {
+		return parts[1] + "|" + parts[3]
+	}
+	var ids struct {
+		GreenhouseID string `json:"greenhouse_id"`
+		NodeID       string `json:"node_id"`
+	}
+	if err := json.Unmarshal(payload, &ids); err == nil && (ids.GreenhouseID != "" || ids.NodeID != "") {
+		return ids.GreenhouseID + "|" + ids.NodeID
+	}
+	return topic
+}
@@ -0,0 +1,160 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+)
+
+// fakeMetrics records dropped messages by reason
+type fakeMetrics struct {
+	mu      sync.Mutex
+	dropped map[string]int
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{dropped: make(map[string]int)}
+}
+
+func (m *fakeMetrics) SetIngestQueueDepth(worker, depth int)              {}
+func (m *fakeMetrics) ObserveIngestLatency(stage string, d time.Duration) {}
+
+func (m *fakeMetrics) IncrementIngestDropped(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropped[reason]++
+}
+
+func (m *fakeMetrics) count(reason string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.dropped[reason]
+}
+
+// recorder collects processed payloads per topic
+type recorder struct {
+	mu      sync.Mutex
+	byTopic map[string][]string
+	release chan struct{} // If set, the handler waits on it before recording
+}
+
+func (r *recorder) handle(ctx context.Context, topic string, payload []byte) {
+	if r.release != nil {
+		<-r.release
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byTopic[topic] = append(r.byTopic[topic], string(payload))
+}
+
+func (r *recorder) all() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, payloads := range r.byTopic {
+		out = append(out, payloads...)
+	}
+	return out
+}
+
+func TestPoolKeepsPerNodeOrder(t *testing.T) {
+	rec := &recorder{byTopic: make(map[string][]string)}
+	cfg := &config.IngestConfig{Workers: 4, QueueSize: 10000, Backpressure: config.BackpressureDropNewest}
+	pool := NewPool(cfg, rec.handle, newFakeMetrics())
+
+	const nodes, messages = 8, 500
+	for i := 0; i < messages; i++ {
+		for n := 0; n < nodes; n++ {
+			pool.Submit(context.Background(), fmt.Sprintf("greenhouse/GH1/node/Node%02d/data", n), []byte(fmt.Sprint(i)))
+		}
+	}
+	pool.Close()
+
+	for n := 0; n < nodes; n++ {
+		topic := fmt.Sprintf("greenhouse/GH1/node/Node%02d/data", n)
+		got := rec.byTopic[topic]
+		if len(got) != messages {
+			t.Fatalf("%s: processed %d messages, want %d", topic, len(got), messages)
+		}
+		for i, payload := range got {
+			if payload != fmt.Sprint(i) {
+				t.Fatalf("%s: message %d is %s, want %d", topic, i, payload, i)
+			}
+		}
+	}
+}
+
+func TestPoolBackpressure(t *testing.T) {
+	tests := []struct {
+		policy     string
+		wantReason string
+		want       []string // Payloads processed, in order
+	}{
+		{config.BackpressureDropNewest, DropQueueFull, []string{"0", "1", "2"}},
+		{config.BackpressureDropOldest, DropEvicted, []string{"0", "2", "3"}},
+		{config.BackpressureBlock, DropTimeout, []string{"0", "1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			rec := &recorder{byTopic: make(map[string][]string), release: make(chan struct{})}
+			metrics := newFakeMetrics()
+			cfg := &config.IngestConfig{Workers: 1, QueueSize: 2, Backpressure: tt.policy, BlockTimeout: 10 * time.Millisecond}
+			pool := NewPool(cfg, rec.handle, metrics)
+
+			topic := "greenhouse/GH1/node/Node01/data"
+			pool.Submit(context.Background(), topic, []byte("0"))
+			time.Sleep(20 * time.Millisecond) // Let the worker pick up "0" and block in the handler
+			for i := 1; i <= 3; i++ {
+				pool.Submit(context.Background(), topic, []byte(fmt.Sprint(i)))
+			}
+			close(rec.release)
+			pool.Close()
+
+			if got := metrics.count(tt.wantReason); got != 1 {
+				t.Errorf("dropped %d messages as %s, want 1", got, tt.wantReason)
+			}
+			got := rec.all()
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("processed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolDropsAfterClose(t *testing.T) {
+	rec := &recorder{byTopic: make(map[string][]string)}
+	metrics := newFakeMetrics()
+	pool := NewPool(&config.IngestConfig{Workers: 2, QueueSize: 10, Backpressure: config.BackpressureBlock, BlockTimeout: time.Second}, rec.handle, metrics)
+	pool.Submit(context.Background(), "greenhouse/GH1/node/Node01/data", []byte("0"))
+	pool.Close()
+	pool.Submit(context.Background(), "greenhouse/GH1/node/Node01/data", []byte("1"))
+
+	if got := rec.all(); len(got) != 1 {
+		t.Errorf("processed %v, want only the message submitted before Close", got)
+	}
+	if got := metrics.count(DropShutdown); got != 1 {
+		t.Errorf("dropped %d messages as %s, want 1", got, DropShutdown)
+	}
+}
+
+func TestShardKey(t *testing.T) {
+	tests := []struct {
+		topic   string
+		payload string
+		want    string
+	}{
+		{"greenhouse/GH1/node/Node01/data", `{"greenhouse_id":"GH2","node_id":"Node09"}`, "GH1|Node01"},
+		{"esp32/data", `{"greenhouse_id":"GH2","node_id":"Node09"}`, "GH2|Node09"},
+		{"esp32/data", `not json`, "esp32/data"},
+		{"esp32/data", `{"Air_Temp":20}`, "esp32/data"},
+	}
+	for _, tt := range tests {
+		if got := ShardKey(tt.topic, []byte(tt.payload)); got != tt.want {
+			t.Errorf("ShardKey(%q, %q) = %q, want %q", tt.topic, tt.payload, got, tt.want)
+		}
+	}
+}
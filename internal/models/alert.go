@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Alert severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Alert states
+const (
+	AlertPending  = "pending"  // Condition met, but not for enough consecutive windows yet
+	AlertFiring   = "firing"   // Condition met for the required number of windows
+	AlertResolved = "resolved" // Condition cleared past the hysteresis band
+)
+
+// AlertRule is a threshold rule evaluated against every window average
+// Example: Air_Temp > 35 for 3 consecutive windows, Leaf_temp - Air_Temp > 4, or air_vpd > 1.5
+type AlertRule struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	GreenhouseID    string    `json:"greenhouse_id,omitempty"`    // Empty matches every greenhouse
+	NodeID          string    `json:"node_id,omitempty"`          // Empty matches every node
+	Sensor          string    `json:"sensor"`                     // Sensor whose window mean is compared, or a derived metric
+	ReferenceSensor string    `json:"reference_sensor,omitempty"` // If set, the value is sensor - reference_sensor
+	Operator        string    `json:"operator"`                   // >, >=, < or <=
+	Threshold       float64   `json:"threshold"`
+	ForWindows      int       `json:"for_windows"` // Consecutive windows before firing (default 1)
+	Hysteresis      float64   `json:"hysteresis"`  // Distance past the threshold needed to resolve
+	Severity        string    `json:"severity"`    // info, warning or critical
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Alert is one occurrence of a rule matching on a node
+type Alert struct {
+	ID           string     `json:"id"`
+	RuleID       string     `json:"rule_id"`
+	RuleName     string     `json:"rule_name"`
+	GreenhouseID string     `json:"greenhouse_id"`
+	NodeID       string     `json:"node_id"`
+	Severity     string     `json:"severity"`
+	State        string     `json:"state"`
+	Value        float64    `json:"value"` // Latest evaluated value
+	Threshold    float64    `json:"threshold"`
+	Windows      int        `json:"windows"` // Consecutive windows the condition held
+	Message      string     `json:"message"`
+	StartedAt    time.Time  `json:"started_at"` // End of the first matching window
+	FiredAt      *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
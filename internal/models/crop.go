@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// CropStage is a growth stage that starts once a planting has accumulated gdd growing degree days
+type CropStage struct {
+	Name string  `json:"name"`
+	GDD  float64 `json:"gdd"`
+}
+
+// CropPlanting assigns a crop to a greenhouse
+// Example: tomato planted 2024-03-01, base 10 °C, cap 30 °C, flowering at 450 GDD, first harvest at 1200 GDD
+type CropPlanting struct {
+	GreenhouseID string      `json:"greenhouse_id"`
+	Crop         string      `json:"crop"`
+	PlantingDate string      `json:"planting_date"` // Local day, YYYY-MM-DD
+	BaseTemp     float64     `json:"base_temp"`     // °C below which no growth accumulates
+	CapTemp      float64     `json:"cap_temp"`      // °C above which no extra growth accumulates
+	Stages       []CropStage `json:"stages"`        // Ordered by gdd
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+// CropDay is the GDD a greenhouse accumulated over one local day
+type CropDay struct {
+	Date     string  `json:"date"`      // Local day, YYYY-MM-DD
+	Hours    int     `json:"hours"`     // Hours with temperature data (missing hours add no GDD)
+	MeanTemp float64 `json:"mean_temp"` // Mean of the hourly greenhouse temperatures, °C
+	GDD      float64 `json:"gdd"`
+	Total    float64 `json:"total"` // GDD accumulated since planting by the end of the day
+}
+
+// CropStageStatus is a stage of a planting with the day it was reached or is projected to be reached
+type CropStageStatus struct {
+	Name      string  `json:"name"`
+	GDD       float64 `json:"gdd"`
+	Reached   bool    `json:"reached"`
+	Date      string  `json:"date,omitempty"` // Day the stage was reached or is projected; empty if no projection is possible
+	Projected bool    `json:"projected"`      // Date is a projection from the recent daily GDD
+}
+
+// CropStatus reports the accumulated GDD and growth stage of a greenhouse's planting
+type CropStatus struct {
+	Planting  CropPlanting      `json:"planting"`
+	Sensor    string            `json:"sensor"`
+	Date      string            `json:"date"`       // Local day the status is for
+	Day       int               `json:"day"`        // Days since planting (1 = planting day)
+	GDD       float64           `json:"gdd"`        // Accumulated since planting, including today so far
+	DailyRate float64           `json:"daily_rate"` // Mean GDD per day over the recent complete days, used for projections
+	Stage     string            `json:"stage"`      // Current stage ("" before the first stage)
+	Stages    []CropStageStatus `json:"stages"`
+	Days      []CropDay         `json:"days"` // Days with temperature data, oldest first
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Reasons a data message is rejected before averaging
+const (
+	RejectInvalidJSON      = "invalid_json"      // Payload is not a JSON object
+	RejectInvalidValue     = "invalid_value"     // A field has the wrong JSON type
+	RejectMissingIdentity  = "missing_identity"  // Neither payload nor topic name the greenhouse/node
+	RejectIdentityMismatch = "identity_mismatch" // Payload and topic IDs differ under the reject policy
+	RejectMissingField     = "missing_field"     // A sensor required for the node type is absent
+	RejectOutOfRange       = "out_of_range"      // A value is outside the sensor's physical range
+)
+
+// Rejection explains why a data message was rejected
+type Rejection struct {
+	Reason  string `json:"reason"`
+	Sensor  string `json:"sensor,omitempty"`
+	Message string `json:"message"`
+}
+
+// DeadLetter is a rejected data message kept for inspection and replay
+type DeadLetter struct {
+	ID           int64       `json:"id"`
+	ReceivedAt   time.Time   `json:"received_at"`
+	Topic        string      `json:"topic"`
+	GreenhouseID string      `json:"greenhouse_id,omitempty"`
+	NodeID       string      `json:"node_id,omitempty"`
+	Payload      string      `json:"payload"`
+	Rejections   []Rejection `json:"rejections"`
+	Replays      int         `json:"replays"` // Replay attempts that were rejected again
+}
+
+// ReplayResult summarizes a dead-letter replay
+type ReplayResult struct {
+	Replayed int     `json:"replayed"`
+	Accepted int     `json:"accepted"` // Removed from the store
+	Late     int     `json:"late"`     // Accepted but their window had closed, so only stored raw
+	Rejected int     `json:"rejected"` // Still invalid, kept with updated reasons
+	Missing  []int64 `json:"missing,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// IrrigationEvent is one irrigation detected from a node's substrate weight
+// Weights are in g; 1 g of water is 1 mL
+type IrrigationEvent struct {
+	GreenhouseID  string    `json:"greenhouse_id"`
+	NodeID        string    `json:"node_id"`
+	Start         time.Time `json:"start"`          // First reading of the weight rise
+	Stop          time.Time `json:"stop"`           // Peak weight (irrigation stopped)
+	End           time.Time `json:"end"`            // Reading with the settled weight (drainage finished)
+	Duration      float64   `json:"duration"`       // Seconds from start to stop
+	StartWeight   float64   `json:"start_weight"`   // Weight before the irrigation
+	PeakWeight    float64   `json:"peak_weight"`    // Highest weight
+	SettledWeight float64   `json:"settled_weight"` // Lowest weight after the peak, once drained
+	Supply        float64   `json:"supply"`         // Water retained at the peak: peak - start
+	Drain         float64   `json:"drain"`          // Water drained after the peak: peak - settled
+	DrainRatio    float64   `json:"drain_ratio"`    // drain / supply
+}
+
+// IrrigationDaily sums the irrigation events of one greenhouse over a local day
+type IrrigationDaily struct {
+	GreenhouseID string  `json:"greenhouse_id"`
+	Date         string  `json:"date"` // Local day, YYYY-MM-DD
+	Events       int     `json:"events"`
+	Supply       float64 `json:"supply"`
+	Drain        float64 `json:"drain"`
+	DrainRatio   float64 `json:"drain_ratio"` // Total drain / total supply
+}
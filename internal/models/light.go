@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// DailyLightIntegral is the photosynthetic light one greenhouse/node received over a local day
+type DailyLightIntegral struct {
+	GreenhouseID string     `json:"greenhouse_id"`
+	NodeID       string     `json:"node_id"`
+	Date         string     `json:"date"`                   // Local day, YYYY-MM-DD
+	DLI          float64    `json:"dli"`                    // mol/m²/day; the running total while the day is in progress
+	Readings     int        `json:"readings"`               // PAR readings integrated
+	Complete     bool       `json:"complete"`               // False while the day is in progress
+	Target       float64    `json:"target,omitempty"`       // DLI goal at the time (0 = no target)
+	BelowTarget  bool       `json:"below_target"`           // DLI below the target (for a running day: not reached yet)
+	LastReading  *time.Time `json:"last_reading,omitempty"` // Event time of the last integrated reading (running days)
+}
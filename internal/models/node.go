@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Node liveness states
+const (
+	NodeOnline  = "online"
+	NodeStale   = "stale"   // Silent for longer than the stale threshold
+	NodeOffline = "offline" // Silent for longer than the offline threshold
+)
+
+// NodeStatus is the liveness of one greenhouse/node
+type NodeStatus struct {
+	GreenhouseID     string     `json:"greenhouse_id"`
+	NodeID           string     `json:"node_id"`
+	NodeType         string     `json:"node_type,omitempty"`
+	Status           string     `json:"status"`
+	Connection       string     `json:"connection,omitempty"` // Last online/offline message on the node's status topic
+	ConnectionAt     *time.Time `json:"connection_at,omitempty"`
+	FirstSeen        time.Time  `json:"first_seen"`
+	LastSeen         time.Time  `json:"last_seen"`
+	SecondsSinceSeen float64    `json:"seconds_since_seen"`
+	Messages         int64      `json:"messages"`          // Messages received since startup
+	MessageRate      float64    `json:"message_rate"`      // Messages per minute, from the smoothed publish interval
+	ExpectedInterval float64    `json:"expected_interval"` // Seconds between messages (configured or learned)
+	StaleAfter       float64    `json:"stale_after"`       // Seconds of silence before the node is stale
+	OfflineAfter     float64    `json:"offline_after"`     // Seconds of silence before the node is offline
+}
+
+// Connection states reported on status (LWT) topics
+const (
+	ConnectionOnline  = "online"
+	ConnectionOffline = "offline"
+)
+
+// NodeStatusEvent is an online/offline message received on a status topic
+// Node events carry greenhouse_id and node_id; device events (e.g. simulator/status) carry device
+type NodeStatusEvent struct {
+	GreenhouseID string    `json:"greenhouse_id,omitempty"`
+	NodeID       string    `json:"node_id,omitempty"`
+	Device       string    `json:"device,omitempty"`
+	Status       string    `json:"status"` // online or offline
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// DeviceStatus is the last status reported by a physical device, e.g. the ESP32 simulating the nodes
+type DeviceStatus struct {
+	Device    string    `json:"device"`
+	Status    string    `json:"status"`
+	ChangedAt time.Time `json:"changed_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RainEvent is one period of rain reported by a node's rain flag
+type RainEvent struct {
+	GreenhouseID string     `json:"greenhouse_id"`
+	NodeID       string     `json:"node_id"`
+	Start        time.Time  `json:"start"`         // First reading with rain
+	End          *time.Time `json:"end,omitempty"` // First dry reading, or the last rainy reading before a gap; nil while raining
+	Duration     float64    `json:"duration"`      // Seconds from start to end (so far, while raining)
+	Active       bool       `json:"active"`        // Still raining
+}
+
+// RainDaily sums the rain of one greenhouse/node over a local day
+type RainDaily struct {
+	GreenhouseID string  `json:"greenhouse_id"`
+	NodeID       string  `json:"node_id"`
+	Date         string  `json:"date"`          // Local day, YYYY-MM-DD
+	Events       int     `json:"events"`        // Events that started on this day
+	RainyMinutes float64 `json:"rainy_minutes"` // Minutes of rain within this day, including events that span midnight
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// SubstrateProfile summarizes the moisture probes of one node's substrate bag
+// over its most recent averaging windows
+type SubstrateProfile struct {
+	GreenhouseID string             `json:"greenhouse_id"`
+	NodeID       string             `json:"node_id"`
+	Unit         string             `json:"unit"`
+	WindowEnd    time.Time          `json:"window_end"`    // End of the latest window
+	Probes       map[string]float64 `json:"probes"`        // Window mean of each probe in the latest window
+	Mean         float64            `json:"mean"`          // Mean across probes
+	Spread       float64            `json:"spread"`        // Highest minus lowest probe
+	StdDev       float64            `json:"stddev"`        // Population standard deviation across probes
+	Deviating    []ProbeDeviation   `json:"deviating"`     // Probes far from their siblings (sensor fault or dry spot)
+	DryDownRate  *float64           `json:"dry_down_rate"` // Loss of the probe mean per hour (negative while wetting); nil with fewer than 2 windows
+	Windows      int                `json:"windows"`       // Windows the dry-down rate is fitted over
+}
+
+// ProbeDeviation is a probe that deviates from the other probes of its bag
+type ProbeDeviation struct {
+	Sensor    string  `json:"sensor"`
+	Value     float64 `json:"value"`
+	Deviation float64 `json:"deviation"` // Value minus the median of the other probes
+}
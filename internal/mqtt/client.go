@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"iot-agriculture-backend/internal/config"
@@ -19,12 +20,22 @@ type MessageHandler func(ctx context.Context, topic string, payload []byte)
 type Client struct {
 	client         MQTT.Client
 	config         *config.MQTTConfig
-	handler        MessageHandler
+	handlers       map[string]MessageHandler // key: handler name (data, status, telemetry, acks)
 	metricsService *services.MetricsService
+
+	mu         sync.Mutex
+	subscribed bool // Set once Subscribe succeeded, so reconnects resubscribe
 }
 
-// NewClient creates a new MQTT client
-func NewClient(cfg *config.MQTTConfig, handler MessageHandler, metricsService *services.MetricsService) (*Client, error) {
+// NewClient creates a new MQTT client. handlers maps the handler names used in
+// the configured subscriptions to the functions processing their messages
+func NewClient(cfg *config.MQTTConfig, handlers map[string]MessageHandler, metricsService *services.MetricsService) (*Client, error) {
+	c := &Client{
+		config:         cfg,
+		handlers:       handlers,
+		metricsService: metricsService,
+	}
+
 	opts := MQTT.NewClientOptions()
 	opts.AddBroker(fmt.Sprintf("tcp://%s:%d", cfg.Broker, cfg.Port))
 	opts.SetClientID(cfg.ClientID)
@@ -55,38 +66,71 @@ func NewClient(cfg *config.MQTTConfig, handler MessageHandler, metricsService *s
 		if metricsService != nil {
 			metricsService.SetMQTTConnectionStatus(true)
 		}
+
+		// A clean session loses its subscriptions, so restore them after a reconnect
+		c.mu.Lock()
+		resubscribe := c.subscribed
+		c.mu.Unlock()
+		if resubscribe {
+			go func() {
+				if err := c.subscribeAll(); err != nil {
+					log.Printf("Failed to resubscribe after reconnect: %v", err)
+				}
+			}()
+		}
 	})
 
-	client := MQTT.NewClient(opts)
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
+	c.client = MQTT.NewClient(opts)
+	if token := c.client.Connect(); token.Wait() && token.Error() != nil {
 		return nil, fmt.Errorf("failed to connect to MQTT broker: %w", token.Error())
 	}
-
-	return &Client{
-		client:         client,
-		config:         cfg,
-		handler:        handler,
-		metricsService: metricsService,
-	}, nil
+	return c, nil
 }
 
-// Subscribe subscribes to the configured topic
+// Subscribe subscribes to every configured topic filter, routing each to its handler
 func (c *Client) Subscribe() error {
-	topic := "greenhouse/+/node/+/data" // Updated to wildcard topic for all nodes
-	if token := c.client.Subscribe(topic, 1, func(client MQTT.Client, msg MQTT.Message) {
-		// Check for empty or null payloads
-		if len(msg.Payload()) == 0 {
-			log.Printf("WARNING: Empty MQTT payload received!")
-			return // Don't process empty messages
+	for _, sub := range c.config.Subscriptions {
+		if c.handlers[sub.Handler] == nil {
+			return fmt.Errorf("no handler registered for %s subscription %s", sub.Handler, sub.Topic)
 		}
+	}
+	if err := c.subscribeAll(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.subscribed = true
+	c.mu.Unlock()
+	return nil
+}
 
-		if c.handler != nil {
-			c.handler(context.Background(), msg.Topic(), msg.Payload())
+// subscribeAll subscribes to the configured topic filters
+func (c *Client) subscribeAll() error {
+	for _, sub := range c.config.Subscriptions {
+		handler := c.handlers[sub.Handler]
+		if token := c.client.Subscribe(sub.Topic, sub.QoS, func(client MQTT.Client, msg MQTT.Message) {
+			// Check for empty or null payloads
+			if len(msg.Payload()) == 0 {
+				log.Printf("WARNING: Empty MQTT payload received on %s", msg.Topic())
+				return // Don't process empty messages
+			}
+			handler(context.Background(), msg.Topic(), msg.Payload())
+		}); token.Wait() && token.Error() != nil {
+			return fmt.Errorf("failed to subscribe to topic %s: %w", sub.Topic, token.Error())
 		}
-	}); token.Wait() && token.Error() != nil {
-		return fmt.Errorf("failed to subscribe to topic %s: %w", topic, token.Error())
+		log.Printf("Subscribed to topic: %s (%s, QoS %d)", sub.Topic, sub.Handler, sub.QoS)
+	}
+	return nil
+}
+
+// Publish publishes a message and waits for the broker to accept it
+func (c *Client) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	token := c.client.Publish(topic, qos, retained, payload)
+	if !token.WaitTimeout(10 * time.Second) {
+		return fmt.Errorf("timed out publishing to %s", topic)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
 	}
-	log.Printf("Subscribed to topic: %s", topic)
 	return nil
 }
 
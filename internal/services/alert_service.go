@@ -0,0 +1,439 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// Errors returned by the alert rule CRUD methods
+var (
+	ErrInvalidRule  = errors.New("invalid alert rule")
+	ErrRuleNotFound = errors.New("alert rule not found")
+)
+
+// maxForWindows caps how many consecutive windows a rule may wait before firing
+const maxForWindows = 1440
+
+// alertOperators are the comparison operators a rule may use
+var alertOperators = map[string]bool{">": true, ">=": true, "<": true, "<=": true}
+
+// alertSeverities are the severities a rule may have
+var alertSeverities = map[string]bool{
+	models.SeverityInfo: true, models.SeverityWarning: true, models.SeverityCritical: true,
+}
+
+// alertRulesFile is the on-disk format of the persisted rules
+type alertRulesFile struct {
+	Rules []models.AlertRule `json:"rules"`
+}
+
+// AlertService evaluates threshold rules against window averages and tracks alert state
+type AlertService struct {
+	mu          sync.Mutex
+	rules       map[string]*models.AlertRule
+	active      map[string]*models.Alert // key: rule_id|greenhouse_id|node_id
+	history     []models.Alert           // Resolved alerts, oldest first
+	historySize int
+	rulesFile   string
+	registry    *SensorRegistry
+}
+
+// NewAlertService creates a new alert service, loading persisted rules if present
+func NewAlertService(cfg *config.AlertsConfig, registry *SensorRegistry) *AlertService {
+	a := &AlertService{
+		rules:       make(map[string]*models.AlertRule),
+		active:      make(map[string]*models.Alert),
+		historySize: cfg.HistorySize,
+		rulesFile:   cfg.RulesFile,
+		registry:    registry,
+	}
+	if a.rulesFile == "" {
+		return a
+	}
+
+	data, err := os.ReadFile(a.rulesFile)
+	if os.IsNotExist(err) {
+		return a
+	}
+	if err != nil {
+		log.Fatalf("Failed to read alert rules %s: %v", a.rulesFile, err)
+	}
+	var file alertRulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Fatalf("Failed to parse alert rules %s: %v", a.rulesFile, err)
+	}
+	for i := range file.Rules {
+		rule := file.Rules[i]
+		if rule.ID == "" {
+			rule.ID = newID()
+		}
+		if err := a.validateRule(&rule); err != nil {
+			log.Printf("Warning: Skipping alert rule %s from %s: %v", rule.ID, a.rulesFile, err)
+			continue
+		}
+		a.rules[rule.ID] = &rule
+	}
+	log.Printf("Loaded %d alert rules from %s", len(a.rules), a.rulesFile)
+	return a
+}
+
+// validateRule checks a rule and fills in defaults
+func (a *AlertService) validateRule(rule *models.AlertRule) error {
+	if !a.isMetric(rule.Sensor) {
+		return fmt.Errorf("%w: unknown sensor or derived metric %q", ErrInvalidRule, rule.Sensor)
+	}
+	if rule.ReferenceSensor != "" {
+		if !a.isMetric(rule.ReferenceSensor) {
+			return fmt.Errorf("%w: unknown reference_sensor %q", ErrInvalidRule, rule.ReferenceSensor)
+		}
+		if rule.ReferenceSensor == rule.Sensor {
+			return fmt.Errorf("%w: reference_sensor must differ from sensor", ErrInvalidRule)
+		}
+	}
+	if !alertOperators[rule.Operator] {
+		return fmt.Errorf("%w: operator must be one of >, >=, <, <=", ErrInvalidRule)
+	}
+	if rule.GreenhouseID != "" {
+		if err := ValidateIdentifier("greenhouse_id", rule.GreenhouseID); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+	if rule.NodeID != "" {
+		if err := ValidateIdentifier("node_id", rule.NodeID); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+	if rule.ForWindows == 0 {
+		rule.ForWindows = 1
+	}
+	if rule.ForWindows < 1 || rule.ForWindows > maxForWindows {
+		return fmt.Errorf("%w: for_windows must be between 1 and %d", ErrInvalidRule, maxForWindows)
+	}
+	if rule.Hysteresis < 0 {
+		return fmt.Errorf("%w: hysteresis must not be negative", ErrInvalidRule)
+	}
+	if rule.Severity == "" {
+		rule.Severity = models.SeverityWarning
+	}
+	if !alertSeverities[rule.Severity] {
+		return fmt.Errorf("%w: severity must be info, warning or critical", ErrInvalidRule)
+	}
+	if rule.Name == "" {
+		rule.Name = describeCondition(rule)
+	}
+	return nil
+}
+
+// isMetric reports whether name is an aggregated sensor or a derived metric
+func (a *AlertService) isMetric(name string) bool {
+	if a.registry.IsAggregated(name) {
+		return true
+	}
+	_, ok := lookupDerivedMetric(name)
+	return ok
+}
+
+// describeCondition formats a rule condition, e.g. "Leaf_temp - Air_Temp > 4"
+func describeCondition(rule *models.AlertRule) string {
+	subject := rule.Sensor
+	if rule.ReferenceSensor != "" {
+		subject += " - " + rule.ReferenceSensor
+	}
+	return fmt.Sprintf("%s %s %g", subject, rule.Operator, rule.Threshold)
+}
+
+// ListRules returns all rules ordered by creation time
+func (a *AlertService) ListRules() []models.AlertRule {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sortedRules()
+}
+
+// sortedRules returns copies of all rules ordered by creation time. Must be called with mu held
+func (a *AlertService) sortedRules() []models.AlertRule {
+	rules := make([]models.AlertRule, 0, len(a.rules))
+	for _, rule := range a.rules {
+		rules = append(rules, *rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if !rules[i].CreatedAt.Equal(rules[j].CreatedAt) {
+			return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
+
+// GetRule returns the rule with the given ID
+func (a *AlertService) GetRule(id string) (models.AlertRule, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rule, ok := a.rules[id]
+	if !ok {
+		return models.AlertRule{}, ErrRuleNotFound
+	}
+	return *rule, nil
+}
+
+// CreateRule validates, stores and persists a new rule
+func (a *AlertService) CreateRule(rule models.AlertRule) (models.AlertRule, error) {
+	if err := a.validateRule(&rule); err != nil {
+		return models.AlertRule{}, err
+	}
+	now := time.Now().UTC()
+	rule.ID = newID()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rules[rule.ID] = &rule
+	if err := a.saveRules(); err != nil {
+		delete(a.rules, rule.ID)
+		return models.AlertRule{}, err
+	}
+	return rule, nil
+}
+
+// UpdateRule replaces a rule; alerts raised by the old version are resolved
+func (a *AlertService) UpdateRule(id string, rule models.AlertRule) (models.AlertRule, error) {
+	if err := a.validateRule(&rule); err != nil {
+		return models.AlertRule{}, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	old, ok := a.rules[id]
+	if !ok {
+		return models.AlertRule{}, ErrRuleNotFound
+	}
+	rule.ID = id
+	rule.CreatedAt = old.CreatedAt
+	rule.UpdatedAt = time.Now().UTC()
+	a.rules[id] = &rule
+	if err := a.saveRules(); err != nil {
+		a.rules[id] = old
+		return models.AlertRule{}, err
+	}
+	a.closeAlertsForRule(id, "rule updated")
+	return rule, nil
+}
+
+// DeleteRule removes a rule; its alerts are resolved
+func (a *AlertService) DeleteRule(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	old, ok := a.rules[id]
+	if !ok {
+		return ErrRuleNotFound
+	}
+	delete(a.rules, id)
+	if err := a.saveRules(); err != nil {
+		a.rules[id] = old
+		return err
+	}
+	a.closeAlertsForRule(id, "rule deleted")
+	return nil
+}
+
+// closeAlertsForRule drops pending alerts of a rule and resolves its firing ones
+// Must be called with mu held
+func (a *AlertService) closeAlertsForRule(ruleID, reason string) {
+	now := time.Now().UTC()
+	for key, alert := range a.active {
+		if alert.RuleID != ruleID {
+			continue
+		}
+		delete(a.active, key)
+		if alert.State == models.AlertFiring {
+			alert.Message += " (" + reason + ")"
+			a.resolve(alert, now)
+		}
+	}
+}
+
+// saveRules writes the rules to the rules file atomically. Must be called with mu held
+func (a *AlertService) saveRules() error {
+	if a.rulesFile == "" {
+		return nil
+	}
+	if err := writeJSONFile(a.rulesFile, alertRulesFile{Rules: a.sortedRules()}); err != nil {
+		return fmt.Errorf("failed to save alert rules: %w", err)
+	}
+	return nil
+}
+
+// Evaluate checks every rule in scope against a window average and returns the
+// alerts that started firing or resolved as a result
+func (a *AlertService) Evaluate(result models.AverageResult) []models.Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	changed := make([]models.Alert, 0)
+	for _, rule := range a.rules {
+		if (rule.GreenhouseID != "" && rule.GreenhouseID != result.GreenhouseID) ||
+			(rule.NodeID != "" && rule.NodeID != result.NodeID) {
+			continue
+		}
+		value, ok := a.ruleValue(rule, result)
+		if !ok {
+			continue // The node did not report the sensor in this window
+		}
+
+		key := rule.ID + "|" + result.GreenhouseID + "|" + result.NodeID
+		alert, exists := a.active[key]
+		breached := compare(rule.Operator, value, rule.Threshold)
+
+		switch {
+		case !exists && breached:
+			alert = &models.Alert{
+				ID:           newID(),
+				RuleID:       rule.ID,
+				RuleName:     rule.Name,
+				GreenhouseID: result.GreenhouseID,
+				NodeID:       result.NodeID,
+				Severity:     rule.Severity,
+				State:        models.AlertPending,
+				Threshold:    rule.Threshold,
+				StartedAt:    result.WindowEnd,
+			}
+			a.active[key] = alert
+		case !exists:
+			continue
+		case alert.State == models.AlertPending && !breached:
+			delete(a.active, key) // Cleared before it fired
+			continue
+		case alert.State == models.AlertFiring && cleared(rule, value):
+			alert.Value = value
+			alert.Message = fmt.Sprintf("%s on %s/%s resolved (value %.2f)",
+				describeCondition(rule), result.GreenhouseID, result.NodeID, value)
+			delete(a.active, key)
+			changed = append(changed, a.resolve(alert, result.WindowEnd))
+			continue
+		}
+
+		if breached {
+			alert.Windows++
+		}
+		alert.Value = value
+		alert.UpdatedAt = result.WindowEnd
+		alert.Message = fmt.Sprintf("%s on %s/%s (value %.2f for %d windows)",
+			describeCondition(rule), result.GreenhouseID, result.NodeID, value, alert.Windows)
+		if alert.State == models.AlertPending && alert.Windows >= rule.ForWindows {
+			firedAt := result.WindowEnd
+			alert.State = models.AlertFiring
+			alert.FiredAt = &firedAt
+			log.Printf("Alert firing [%s] %s", alert.Severity, alert.Message)
+			changed = append(changed, *alert)
+		}
+	}
+	return changed
+}
+
+// resolve marks an alert resolved and adds it to the history. Must be called with mu held
+func (a *AlertService) resolve(alert *models.Alert, at time.Time) models.Alert {
+	alert.State = models.AlertResolved
+	alert.ResolvedAt = &at
+	alert.UpdatedAt = at
+	log.Printf("Alert resolved [%s] %s", alert.Severity, alert.Message)
+
+	a.history = append(a.history, *alert)
+	if len(a.history) > a.historySize {
+		a.history = a.history[len(a.history)-a.historySize:]
+	}
+	return *alert
+}
+
+// ruleValue returns the value a rule compares: the sensor's window mean (or the
+// derived metric's value), or its difference to the reference's
+func (a *AlertService) ruleValue(rule *models.AlertRule, result models.AverageResult) (float64, bool) {
+	value, ok := windowValue(result, rule.Sensor)
+	if !ok {
+		return 0, false
+	}
+	if rule.ReferenceSensor == "" {
+		return value, true
+	}
+	ref, ok := windowValue(result, rule.ReferenceSensor)
+	if !ok {
+		return 0, false
+	}
+	return value - ref, true
+}
+
+// windowValue returns a sensor's window mean or a derived metric's value
+func windowValue(result models.AverageResult, name string) (float64, bool) {
+	if stats, ok := result.Stats[name]; ok {
+		return stats.Mean, true
+	}
+	value, ok := result.Derived[name]
+	return value, ok
+}
+
+// compare applies a rule operator
+func compare(operator string, value, threshold float64) bool {
+	switch operator {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	}
+	return false
+}
+
+// cleared returns true once a firing rule's value is back past the threshold by the hysteresis
+func cleared(rule *models.AlertRule, value float64) bool {
+	switch rule.Operator {
+	case ">", ">=":
+		return !compare(rule.Operator, value, rule.Threshold-rule.Hysteresis)
+	default:
+		return !compare(rule.Operator, value, rule.Threshold+rule.Hysteresis)
+	}
+}
+
+// ActiveAlerts returns pending and firing alerts, newest first
+func (a *AlertService) ActiveAlerts() []models.Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	alerts := make([]models.Alert, 0, len(a.active))
+	for _, alert := range a.active {
+		alerts = append(alerts, *alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].StartedAt.After(alerts[j].StartedAt) })
+	return alerts
+}
+
+// History returns resolved alerts, newest first
+func (a *AlertService) History() []models.Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	alerts := make([]models.Alert, len(a.history))
+	for i, alert := range a.history {
+		alerts[len(a.history)-1-i] = alert
+	}
+	return alerts
+}
+
+// newID returns a random 16 character hex identifier
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
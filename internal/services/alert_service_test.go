@@ -0,0 +1,149 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// alertTestService returns an alert service with one rule on Air_Temp and no rules file
+func alertTestService(t *testing.T, rule models.AlertRule) *AlertService {
+	t.Helper()
+	registry := NewSensorRegistry(&config.SensorsConfig{Sensors: []config.SensorDefinition{
+		{Name: "Air_Temp", JSONKey: "Air_Temp", Unit: "°C", Type: config.SensorTypeFloat},
+	}})
+	a := NewAlertService(&config.AlertsConfig{HistorySize: 10}, registry)
+	rule.Sensor = "Air_Temp"
+	if _, err := a.CreateRule(rule); err != nil {
+		t.Fatalf("CreateRule: %v", err)
+	}
+	return a
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   models.AlertRule
+		values []float64 // Air_Temp mean of consecutive windows
+		want   []string  // State of the alert each window reports ("" = none)
+		active string    // State of the active alert after the last window ("" = none)
+	}{
+		{
+			name:   "fires on the first breach by default",
+			rule:   models.AlertRule{Operator: ">", Threshold: 30},
+			values: []float64{29, 31},
+			want:   []string{"", models.AlertFiring},
+			active: models.AlertFiring,
+		},
+		{
+			name:   "pending until for_windows consecutive breaches",
+			rule:   models.AlertRule{Operator: ">", Threshold: 30, ForWindows: 3},
+			values: []float64{31, 32, 33},
+			want:   []string{"", "", models.AlertFiring},
+			active: models.AlertFiring,
+		},
+		{
+			name:   "pending alert is dropped when the condition clears",
+			rule:   models.AlertRule{Operator: ">", Threshold: 30, ForWindows: 2},
+			values: []float64{31, 29, 31},
+			want:   []string{"", "", ""},
+			active: models.AlertPending,
+		},
+		{
+			name:   "firing alert is reported once while the condition holds",
+			rule:   models.AlertRule{Operator: ">", Threshold: 30},
+			values: []float64{31, 35, 32, 31},
+			want:   []string{models.AlertFiring, "", "", ""},
+			active: models.AlertFiring,
+		},
+		{
+			name:   "stays firing inside the hysteresis band",
+			rule:   models.AlertRule{Operator: ">", Threshold: 30, Hysteresis: 1},
+			values: []float64{31, 29.5, 30, 29.1},
+			want:   []string{models.AlertFiring, "", "", ""},
+			active: models.AlertFiring,
+		},
+		{
+			name:   "resolves past the hysteresis band",
+			rule:   models.AlertRule{Operator: ">", Threshold: 30, Hysteresis: 1},
+			values: []float64{31, 29.5, 28.9},
+			want:   []string{models.AlertFiring, "", models.AlertResolved},
+			active: "",
+		},
+		{
+			name:   "hysteresis on a lower bound",
+			rule:   models.AlertRule{Operator: "<", Threshold: 10, Hysteresis: 2},
+			values: []float64{9, 11, 12.5},
+			want:   []string{models.AlertFiring, "", models.AlertResolved},
+			active: "",
+		},
+		{
+			name:   "fires again after resolving",
+			rule:   models.AlertRule{Operator: ">=", Threshold: 30},
+			values: []float64{30, 29, 30},
+			want:   []string{models.AlertFiring, models.AlertResolved, models.AlertFiring},
+			active: models.AlertFiring,
+		},
+	}
+
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := alertTestService(t, tt.rule)
+			got := make([]string, len(tt.values))
+			for n, value := range tt.values {
+				changed := a.Evaluate(models.AverageResult{
+					GreenhouseID: "GH1",
+					NodeID:       "Node01",
+					WindowEnd:    start.Add(time.Duration(n) * time.Minute),
+					Stats:        map[string]models.SensorStats{"Air_Temp": {Mean: value, Count: 1}},
+				})
+				if len(changed) > 1 {
+					t.Fatalf("window %d reported %d alerts", n, len(changed))
+				}
+				if len(changed) == 1 {
+					got[n] = changed[0].State
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reported states = %q, want %q", got, tt.want)
+			}
+
+			active := a.ActiveAlerts()
+			state := ""
+			if len(active) == 1 {
+				state = active[0].State
+			}
+			if len(active) > 1 || state != tt.active {
+				t.Errorf("active alerts = %+v, want one %q alert", active, tt.active)
+			}
+		})
+	}
+}
+
+func TestEvaluateTracksNodesSeparately(t *testing.T) {
+	a := alertTestService(t, models.AlertRule{Operator: ">", Threshold: 30, ForWindows: 2})
+	end := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	window := func(node string, value float64) []models.Alert {
+		return a.Evaluate(models.AverageResult{
+			GreenhouseID: "GH1",
+			NodeID:       node,
+			WindowEnd:    end,
+			Stats:        map[string]models.SensorStats{"Air_Temp": {Mean: value, Count: 1}},
+		})
+	}
+
+	window("Node01", 31)
+	window("Node02", 31)
+	window("Node01", 31)
+	if changed := window("Node02", 25); len(changed) != 0 {
+		t.Errorf("clearing Node02 reported %+v", changed)
+	}
+	active := a.ActiveAlerts()
+	if len(active) != 1 || active[0].NodeID != "Node01" || active[0].State != models.AlertFiring {
+		t.Errorf("expected only Node01 firing, got %+v", active)
+	}
+}
@@ -2,92 +2,156 @@ package services
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"iot-agriculture-backend/internal/config"
 	"iot-agriculture-backend/internal/models"
 )
 
+// Device timestamps are interpreted by magnitude: epoch milliseconds,
+// epoch seconds, or milliseconds since the node booted
+const (
+	epochMillisThreshold  = 1_000_000_000_000 // 2001-09-09 in milliseconds
+	epochSecondsThreshold = 1_000_000_000     // 2001-09-09 in seconds
+	maxClockSkew          = 5 * time.Second   // Device clocks ahead of ours by more are ignored
+)
+
+// nodeClock tracks the learned boot time of a node reporting millis since boot
+type nodeClock struct {
+	bootTime  time.Time
+	lastTicks int64
+}
+
 // AveragingService handles sensor data averaging calculations
 type AveragingService struct {
-	mu      sync.Mutex
-	buffers map[string]*models.SensorAverages // key: greenhouse_id|node_id
+	mu       sync.Mutex
+	buffers  map[string]*models.SensorAverages // key: greenhouse_id|node_id|window start
+	clocks   map[string]*nodeClock             // key: greenhouse_id|node_id
+	registry *SensorRegistry
+	window   time.Duration
+	lateness time.Duration
 }
 
 // NewAveragingService creates a new averaging service
-func NewAveragingService() *AveragingService {
+func NewAveragingService(cfg *config.AveragingConfig, registry *SensorRegistry) *AveragingService {
 	return &AveragingService{
-		buffers: make(map[string]*models.SensorAverages),
+		buffers:  make(map[string]*models.SensorAverages),
+		clocks:   make(map[string]*nodeClock),
+		registry: registry,
+		window:   cfg.Window,
+		lateness: cfg.AllowedLateness,
 	}
 }
 
-// AddSensorData adds sensor data to the averaging system
-func (a *AveragingService) AddSensorData(data models.ESP32SensorData) {
+// AddSensorData adds sensor data received at arrival to the window given by its event time
+// Returns the resolved event time, and false if the reading's window was already closed
+func (a *AveragingService) AddSensorData(data models.ESP32SensorData, arrival time.Time) (time.Time, bool) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	key := data.GreenhouseID + "|" + data.NodeID
+
+	now := time.Now()
+	eventTime := a.eventTime(data, arrival)
+	windowStart := eventTime.Truncate(a.window)
+	windowEnd := windowStart.Add(a.window)
+	if !windowEnd.Add(a.lateness).After(now) {
+		return eventTime, false
+	}
+
+	key := fmt.Sprintf("%s|%s|%d", data.GreenhouseID, data.NodeID, windowStart.Unix())
 	buf, ok := a.buffers[key]
 	if !ok {
 		buf = &models.SensorAverages{
 			GreenhouseID: data.GreenhouseID,
 			NodeID:       data.NodeID,
-			StartTime:    time.Now(),
+			Values:       make(map[string][]float64),
+			StartTime:    windowStart,
+			EndTime:      windowEnd,
 		}
 		a.buffers[key] = buf
 	}
-	if data.BagTemp != nil {
-		buf.BagTemp = append(buf.BagTemp, *data.BagTemp)
-	}
-	if data.LightPar != nil {
-		buf.LightPar = append(buf.LightPar, *data.LightPar)
-	}
-	if data.AirTemp != nil {
-		buf.AirTemp = append(buf.AirTemp, *data.AirTemp)
-	}
-	if data.AirRh != nil {
-		buf.AirRh = append(buf.AirRh, *data.AirRh)
+	for name, value := range data.Values {
+		buf.Values[name] = append(buf.Values[name], value)
 	}
-	if data.LeafTemp != nil {
-		buf.LeafTemp = append(buf.LeafTemp, *data.LeafTemp)
+	if len(data.Values) > 0 {
+		buf.Messages++
 	}
-	if data.DripWeight != nil {
-		buf.DripWeight = append(buf.DripWeight, *data.DripWeight)
-	}
-	if data.BagRh1 != nil {
-		buf.BagRh1 = append(buf.BagRh1, *data.BagRh1)
-	}
-	if data.BagRh2 != nil {
-		buf.BagRh2 = append(buf.BagRh2, *data.BagRh2)
-	}
-	if data.BagRh3 != nil {
-		buf.BagRh3 = append(buf.BagRh3, *data.BagRh3)
+	return eventTime, true
+}
+
+// eventTime resolves the device timestamp of a reading, falling back to arrival time
+func (a *AveragingService) eventTime(data models.ESP32SensorData, arrival time.Time) time.Time {
+	if data.Timestamp == nil || *data.Timestamp <= 0 {
+		return arrival
 	}
-	if data.BagRh4 != nil {
-		buf.BagRh4 = append(buf.BagRh4, *data.BagRh4)
+	ts := *data.Timestamp
+
+	var t time.Time
+	switch {
+	case ts >= epochMillisThreshold:
+		t = time.UnixMilli(ts)
+	case ts >= epochSecondsThreshold:
+		t = time.Unix(ts, 0)
+	default:
+		t = a.sinceBootTime(data.GreenhouseID+"|"+data.NodeID, ts, arrival)
 	}
-	if data.Rain != nil {
-		buf.Rain = append(buf.Rain, *data.Rain)
+
+	if t.After(arrival.Add(maxClockSkew)) {
+		return arrival
 	}
+	return t
 }
 
-// CalculateAndDisplayAverages calculates and displays 60-second averages for all nodes
-func (a *AveragingService) CalculateAndDisplayAverages() {
-	a.CalculateAndDisplayAveragesWithLogging(nil, nil)
+// sinceBootTime converts millis-since-boot to wall-clock time using a per-node offset
+// The boot time estimate only moves earlier (less network delay), and is relearned
+// when the tick counter goes back by more than a window after a reboot. A smaller
+// step back is a message delivered out of order: it is placed with the learned boot
+// time and does not move the node's clock
+func (a *AveragingService) sinceBootTime(nodeKey string, ticks int64, arrival time.Time) time.Time {
+	estimate := arrival.Add(-time.Duration(ticks) * time.Millisecond)
+	clock, ok := a.clocks[nodeKey]
+	switch {
+	case !ok || clock.lastTicks-ticks > a.window.Milliseconds():
+		clock = &nodeClock{bootTime: estimate}
+		a.clocks[nodeKey] = clock
+	case ticks < clock.lastTicks:
+		return clock.bootTime.Add(time.Duration(ticks) * time.Millisecond)
+	case estimate.Before(clock.bootTime):
+		clock.bootTime = estimate
+	}
+	clock.lastTicks = ticks
+	return clock.bootTime.Add(time.Duration(ticks) * time.Millisecond)
 }
 
-// CalculateAndDisplayAveragesWithLogging calculates, displays, and logs 60-second averages for all nodes
-func (a *AveragingService) CalculateAndDisplayAveragesWithLogging(influxService *InfluxDBService, metricsService *MetricsService) {
+// CalculateAndDisplayAverages calculates and displays averages for all closed windows
+func (a *AveragingService) CalculateAndDisplayAverages() []models.AverageResult {
+	return a.CalculateAndDisplayAveragesWithLogging(nil, nil)
+}
+
+// CalculateAndDisplayAveragesWithLogging calculates, displays, and logs averages for every
+// window whose end plus the allowed lateness has passed, and returns the flushed results
+// ordered by window end, greenhouse and node, so consumers see each node's windows in order
+func (a *AveragingService) CalculateAndDisplayAveragesWithLogging(influxService *InfluxDBService, metricsService *MetricsService) []models.AverageResult {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	if len(a.buffers) == 0 {
-		fmt.Printf("No sensor data to average in this period.\n")
-		return
+
+	now := time.Now()
+	results := make([]models.AverageResult, 0)
+	for key, buf := range a.buffers {
+		if buf.EndTime.Add(a.lateness).After(now) {
+			continue
+		}
+		delete(a.buffers, key)
+		results = append(results, a.calculateAveragesForBuffer(buf))
 	}
-	for _, buf := range a.buffers {
-		result := calculateAveragesForBuffer(buf)
-		displayAveragesForResult(result)
-		if influxService != nil && influxService.IsConnected() && result.Readings > 0 {
+	sortAverageResults(results)
+
+	for _, result := range results {
+		a.displayAveragesForResult(result)
+		if influxService != nil && influxService.IsWritable() && result.Readings > 0 {
 			if err := influxService.LogAverages(result); err != nil {
 				fmt.Printf("Warning: Failed to log to InfluxDB: %v\n", err)
 				if metricsService != nil {
@@ -99,165 +163,99 @@ func (a *AveragingService) CalculateAndDisplayAveragesWithLogging(influxService
 				}
 			}
 		} else if result.Readings == 0 {
-			fmt.Printf("Skipping InfluxDB log - no sensor readings for %s/%s in this period\n", buf.GreenhouseID, buf.NodeID)
+			fmt.Printf("Skipping InfluxDB log - no sensor readings for %s/%s in this period\n", result.GreenhouseID, result.NodeID)
 		}
 	}
-	// Clear all buffers for next period
-	a.buffers = make(map[string]*models.SensorAverages)
+	return results
 }
 
-// GetAverages returns the current averages for all nodes
+// GetAverages returns the averages of all windows that are still open,
+// ordered by window end, greenhouse and node
 func (a *AveragingService) GetAverages() []models.AverageResult {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 	results := make([]models.AverageResult, 0, len(a.buffers))
 	for _, buf := range a.buffers {
-		results = append(results, calculateAveragesForBuffer(buf))
+		results = append(results, a.calculateAveragesForBuffer(buf))
 	}
+	sortAverageResults(results)
 	return results
 }
 
-// calculateAveragesForBuffer calculates the averages for a single node buffer
-func calculateAveragesForBuffer(buf *models.SensorAverages) models.AverageResult {
-	duration := time.Since(buf.StartTime)
+// sortAverageResults orders results by window end, then greenhouse, then node
+func sortAverageResults(results []models.AverageResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].WindowEnd.Equal(results[j].WindowEnd) {
+			return results[i].WindowEnd.Before(results[j].WindowEnd)
+		}
+		if results[i].GreenhouseID != results[j].GreenhouseID {
+			return results[i].GreenhouseID < results[j].GreenhouseID
+		}
+		return results[i].NodeID < results[j].NodeID
+	})
+}
+
+// calculateAveragesForBuffer calculates the averages for a single node window
+func (a *AveragingService) calculateAveragesForBuffer(buf *models.SensorAverages) models.AverageResult {
 	result := models.AverageResult{
 		GreenhouseID: buf.GreenhouseID,
 		NodeID:       buf.NodeID,
-		Duration:     duration.Seconds(),
-		Readings:     0,
-	}
-	if len(buf.BagTemp) > 0 {
-		avg := calculateAverage(buf.BagTemp)
-		result.BagTemp = &avg
-		result.Readings = len(buf.BagTemp)
-	}
-	if len(buf.LightPar) > 0 {
-		avg := calculateAverage(buf.LightPar)
-		result.LightPar = &avg
-		if result.Readings == 0 {
-			result.Readings = len(buf.LightPar)
-		}
-	}
-	if len(buf.AirTemp) > 0 {
-		avg := calculateAverage(buf.AirTemp)
-		result.AirTemp = &avg
-		if result.Readings == 0 {
-			result.Readings = len(buf.AirTemp)
-		}
-	}
-	if len(buf.AirRh) > 0 {
-		avg := calculateAverage(buf.AirRh)
-		result.AirRh = &avg
-		if result.Readings == 0 {
-			result.Readings = len(buf.AirRh)
-		}
-	}
-	if len(buf.LeafTemp) > 0 {
-		avg := calculateAverage(buf.LeafTemp)
-		result.LeafTemp = &avg
-		if result.Readings == 0 {
-			result.Readings = len(buf.LeafTemp)
-		}
-	}
-	if len(buf.DripWeight) > 0 {
-		avg := calculateAverage(buf.DripWeight)
-		result.DripWeight = &avg
-		if result.Readings == 0 {
-			result.Readings = len(buf.DripWeight)
-		}
-	}
-	if len(buf.BagRh1) > 0 {
-		avg := calculateAverage(buf.BagRh1)
-		result.BagRh1 = &avg
-		if result.Readings == 0 {
-			result.Readings = len(buf.BagRh1)
-		}
-	}
-	if len(buf.BagRh2) > 0 {
-		avg := calculateAverage(buf.BagRh2)
-		result.BagRh2 = &avg
-		if result.Readings == 0 {
-			result.Readings = len(buf.BagRh2)
-		}
-	}
-	if len(buf.BagRh3) > 0 {
-		avg := calculateAverage(buf.BagRh3)
-		result.BagRh3 = &avg
-		if result.Readings == 0 {
-			result.Readings = len(buf.BagRh3)
-		}
-	}
-	if len(buf.BagRh4) > 0 {
-		avg := calculateAverage(buf.BagRh4)
-		result.BagRh4 = &avg
-		if result.Readings == 0 {
-			result.Readings = len(buf.BagRh4)
-		}
-	}
-	if len(buf.Rain) > 0 {
-		avg := calculateAverage(buf.Rain)
-		result.Rain = &avg
-		if result.Readings == 0 {
-			result.Readings = len(buf.Rain)
+		WindowStart:  buf.StartTime,
+		WindowEnd:    buf.EndTime,
+		Duration:     buf.EndTime.Sub(buf.StartTime).Seconds(),
+		Readings:     buf.Messages,
+		Stats:        make(map[string]models.SensorStats),
+	}
+	for _, sensor := range a.registry.Sensors() {
+		values := buf.Values[sensor.Name]
+		if len(values) == 0 || !a.registry.IsAggregated(sensor.Name) {
+			continue
 		}
+		result.Stats[sensor.Name] = roundStats(calculateStats(values), sensor.Precision)
 	}
+	result.Derived = deriveMetrics(result.Stats)
 	return result
 }
 
 // displayAveragesForResult displays the calculated averages for a single node
-func displayAveragesForResult(result models.AverageResult) {
+func (a *AveragingService) displayAveragesForResult(result models.AverageResult) {
 	fmt.Println("\n" + strings.Repeat("=", 60) + "\n")
-	fmt.Println("🕐 60-SECOND SENSOR AVERAGES")
+	fmt.Printf("🕐 %s SENSOR AVERAGES\n", windowLabel(a.window))
 	fmt.Println(strings.Repeat("=", 60))
+	fmt.Printf("🪟  Window: %s - %s\n", result.WindowStart.Format(time.TimeOnly), result.WindowEnd.Format(time.TimeOnly))
 	fmt.Printf("⏱️  Duration: %.1f seconds\n", result.Duration)
 	fmt.Printf("🏠  Greenhouse: %s\n", result.GreenhouseID)
 	fmt.Printf("📡  Node: %s\n", result.NodeID)
 	fmt.Printf("📊  Total Readings: %d\n", result.Readings)
 	fmt.Println(strings.Repeat("-", 60))
-	if result.BagTemp != nil {
-		fmt.Printf("🌡️  Bag_Temp: %.2f\n", *result.BagTemp)
-	}
-	if result.LightPar != nil {
-		fmt.Printf("💡 Light_Par: %.2f\n", *result.LightPar)
-	}
-	if result.AirTemp != nil {
-		fmt.Printf("🌡️  Air_Temp: %.2f\n", *result.AirTemp)
-	}
-	if result.AirRh != nil {
-		fmt.Printf("💧 Air_Rh: %.2f\n", *result.AirRh)
-	}
-	if result.LeafTemp != nil {
-		fmt.Printf("🌿 Leaf_temp: %.2f\n", *result.LeafTemp)
-	}
-	if result.DripWeight != nil {
-		fmt.Printf("⚖️  drip_weight: %.2f\n", *result.DripWeight)
-	}
-	if result.BagRh1 != nil {
-		fmt.Printf("💧 Bag_Rh1: %.2f\n", *result.BagRh1)
-	}
-	if result.BagRh2 != nil {
-		fmt.Printf("💧 Bag_Rh2: %.2f\n", *result.BagRh2)
-	}
-	if result.BagRh3 != nil {
-		fmt.Printf("💧 Bag_Rh3: %.2f\n", *result.BagRh3)
-	}
-	if result.BagRh4 != nil {
-		fmt.Printf("💧 Bag_Rh4: %.2f\n", *result.BagRh4)
+	for _, sensor := range a.registry.Sensors() {
+		if stats, ok := result.Stats[sensor.Name]; ok {
+			fmt.Printf("📈 %s: %.2f %s (min %.2f, max %.2f, σ %.2f, median %.2f, n=%d)\n",
+				sensor.Name, stats.Mean, sensor.Unit, stats.Min, stats.Max, stats.StdDev, stats.Median, stats.Count)
+		}
 	}
-	if result.Rain != nil {
-		fmt.Printf("🌧️  Rain: %.2f\n", *result.Rain)
+	for _, m := range derivedMetrics {
+		if value, ok := result.Derived[m.name]; ok {
+			fmt.Printf("🌿 %s: %.*f %s\n", m.name, m.precision, value, m.unit)
+		}
 	}
 	fmt.Println(strings.Repeat("=", 60) + "\n")
 
 	if result.Readings == 0 {
-		fmt.Println("⚠️  WARNING: No sensor readings received in the last 60 seconds for this node!")
+		fmt.Println("⚠️  WARNING: No sensor readings received in this window for this node!")
 		fmt.Println("   Check if ESP32 is sending data to topic for this node")
 		fmt.Println("   Check MQTT broker connectivity")
 		fmt.Println("   This period will NOT be logged to InfluxDB")
 	}
 }
 
-// (resetAverages is no longer needed; buffers are cleared in CalculateAndDisplayAveragesWithLogging)
+// windowLabel formats a window length for display, e.g. "60-SECOND" or "5-MINUTE"
+func windowLabel(window time.Duration) string {
+	if window >= 2*time.Minute && window%time.Minute == 0 {
+		return fmt.Sprintf("%d-MINUTE", int(window/time.Minute))
+	}
+	return fmt.Sprintf("%d-SECOND", int(window/time.Second))
+}
 
 // GetReadingCount returns the current number of readings
 func (a *AveragingService) GetReadingCount() int {
@@ -265,61 +263,65 @@ func (a *AveragingService) GetReadingCount() int {
 	defer a.mu.Unlock()
 	count := 0
 	for _, buf := range a.buffers {
-		if len(buf.BagTemp) > 0 {
-			count += len(buf.BagTemp)
-		}
-		if len(buf.LightPar) > 0 {
-			count += len(buf.LightPar)
-		}
-		if len(buf.AirTemp) > 0 {
-			count += len(buf.AirTemp)
-		}
-		if len(buf.AirRh) > 0 {
-			count += len(buf.AirRh)
-		}
-		if len(buf.LeafTemp) > 0 {
-			count += len(buf.LeafTemp)
-		}
-		if len(buf.DripWeight) > 0 {
-			count += len(buf.DripWeight)
-		}
-		if len(buf.BagRh1) > 0 {
-			count += len(buf.BagRh1)
-		}
-		if len(buf.BagRh2) > 0 {
-			count += len(buf.BagRh2)
-		}
-		if len(buf.BagRh3) > 0 {
-			count += len(buf.BagRh3)
-		}
-		if len(buf.BagRh4) > 0 {
-			count += len(buf.BagRh4)
-		}
-		if len(buf.Rain) > 0 {
-			count += len(buf.Rain)
+		for _, values := range buf.Values {
+			count += len(values)
 		}
 	}
 	return count
 }
 
-// GetDuration returns the current duration since last reset
+// GetDuration returns the configured window length
 func (a *AveragingService) GetDuration() time.Duration {
-	// Not meaningful for multi-node; return 0
-	return 0
+	return a.window
+}
+
+// roundStats rounds the aggregates of a sensor to its declared precision (nil = unrounded)
+func roundStats(stats models.SensorStats, precision *int) models.SensorStats {
+	stats.Mean = roundTo(stats.Mean, precision)
+	stats.Min = roundTo(stats.Min, precision)
+	stats.Max = roundTo(stats.Max, precision)
+	stats.StdDev = roundTo(stats.StdDev, precision)
+	stats.Median = roundTo(stats.Median, precision)
+	return stats
 }
 
-// calculateAverage calculates the average of a slice of integers
-func calculateAverage(values []int) float64 {
+// calculateStats calculates mean, min, max, population standard deviation,
+// median and count of a slice of readings
+func calculateStats(values []float64) models.SensorStats {
 	if len(values) == 0 {
-		return 0.0
+		return models.SensorStats{}
 	}
 
-	sum := 0
-	for _, v := range values {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
 		sum += v
 	}
+	n := float64(len(sorted))
+	mean := sum / n
 
-	result := float64(sum) / float64(len(values))
+	variance := 0.0
+	for _, v := range sorted {
+		d := v - mean
+		variance += d * d
+	}
+	variance /= n
 
-	return result
+	mid := len(sorted) / 2
+	median := sorted[mid]
+	if len(sorted)%2 == 0 {
+		median = (sorted[mid-1] + sorted[mid]) / 2
+	}
+
+	return models.SensorStats{
+		Mean:   mean,
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		StdDev: math.Sqrt(variance),
+		Median: median,
+		Count:  len(sorted),
+	}
 }
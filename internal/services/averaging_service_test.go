@@ -0,0 +1,110 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+func TestAveragingSkipsStateSensors(t *testing.T) {
+	registry := NewSensorRegistry(&config.SensorsConfig{Sensors: []config.SensorDefinition{
+		{Name: "Air_Temp", JSONKey: "Air_Temp", Unit: "°C", Type: config.SensorTypeFloat, Kind: config.SensorKindMeasurement},
+		{Name: "Rain", JSONKey: "Rain", Type: config.SensorTypeInt, Kind: config.SensorKindState},
+	}})
+	averaging := NewAveragingService(&config.AveragingConfig{Window: time.Minute, AllowedLateness: time.Minute}, registry)
+
+	now := time.Now()
+	for _, rain := range []float64{0, 1, 1} {
+		data := models.ESP32SensorData{
+			GreenhouseID: "GH1",
+			NodeID:       "Node05",
+			Values:       map[string]float64{"Air_Temp": 21.5, "Rain": rain},
+		}
+		if _, ok := averaging.AddSensorData(data, now); !ok {
+			t.Fatalf("reading for the current window was rejected")
+		}
+	}
+
+	results := averaging.GetAverages()
+	if len(results) != 1 {
+		t.Fatalf("expected 1 window, got %d", len(results))
+	}
+	if _, ok := results[0].Stats["Rain"]; ok {
+		t.Errorf("state sensor Rain has stats: %+v", results[0].Stats["Rain"])
+	}
+	if stats, ok := results[0].Stats["Air_Temp"]; !ok || stats.Count != 3 {
+		t.Errorf("expected Air_Temp stats over 3 readings, got %+v", results[0].Stats)
+	}
+}
+
+func TestFlushedAveragesAreOrdered(t *testing.T) {
+	registry := NewSensorRegistry(&config.SensorsConfig{Sensors: []config.SensorDefinition{
+		{Name: "Air_Temp", JSONKey: "Air_Temp", Unit: "°C", Type: config.SensorTypeFloat},
+	}})
+	averaging := NewAveragingService(&config.AveragingConfig{Window: time.Minute}, registry)
+
+	// Three closed windows of four nodes each, stored newest window and last node first
+	end := time.Now().Truncate(time.Minute)
+	nodes := [][2]string{{"GH2", "Node02"}, {"GH2", "Node01"}, {"GH1", "Node02"}, {"GH1", "Node01"}}
+	for w := 0; w < 3; w++ {
+		windowEnd := end.Add(-time.Duration(w) * time.Minute)
+		for _, node := range nodes {
+			averaging.buffers[fmt.Sprintf("%s|%s|%d", node[0], node[1], windowEnd.Unix())] = &models.SensorAverages{
+				GreenhouseID: node[0],
+				NodeID:       node[1],
+				Values:       map[string][]float64{"Air_Temp": {21}},
+				Messages:     1,
+				StartTime:    windowEnd.Add(-time.Minute),
+				EndTime:      windowEnd,
+			}
+		}
+	}
+
+	results := averaging.CalculateAndDisplayAveragesWithLogging(nil, nil)
+	if len(results) != 12 {
+		t.Fatalf("expected 12 flushed windows, got %d", len(results))
+	}
+	for i := 1; i < len(results); i++ {
+		prev, cur := results[i-1], results[i]
+		ordered := prev.WindowEnd.Before(cur.WindowEnd) ||
+			prev.WindowEnd.Equal(cur.WindowEnd) && (prev.GreenhouseID < cur.GreenhouseID ||
+				prev.GreenhouseID == cur.GreenhouseID && prev.NodeID < cur.NodeID)
+		if !ordered {
+			t.Errorf("result %d (%s %s/%s) is not after result %d (%s %s/%s)", i,
+				cur.WindowEnd.Format(time.TimeOnly), cur.GreenhouseID, cur.NodeID, i-1,
+				prev.WindowEnd.Format(time.TimeOnly), prev.GreenhouseID, prev.NodeID)
+		}
+	}
+}
+
+func TestSinceBootClock(t *testing.T) {
+	averaging := NewAveragingService(&config.AveragingConfig{Window: time.Minute}, NewSensorRegistry(&config.SensorsConfig{}))
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		ticks   int64         // Milliseconds since boot reported by the node
+		arrival time.Duration // Arrival after start
+		want    time.Duration // Resolved event time after start
+	}{
+		{"first message learns the boot time", 100_000, 0, 0},
+		{"in order", 110_000, 10 * time.Second, 10 * time.Second},
+		{"network delay keeps the learned boot time", 115_000, 17 * time.Second, 15 * time.Second},
+		{"faster delivery moves the boot time earlier", 120_000, 19 * time.Second, 19 * time.Second},
+		{"late message is placed at its own time", 112_000, 21 * time.Second, 11 * time.Second},
+		{"late message does not reset the clock", 125_000, 24 * time.Second, 24 * time.Second},
+		{"step back by more than a window is a reboot", 3_000, 90 * time.Second, 90 * time.Second},
+		{"the new boot time is kept", 13_000, 101 * time.Second, 100 * time.Second},
+	}
+	for _, tt := range tests {
+		ticks := tt.ticks
+		data := models.ESP32SensorData{GreenhouseID: "GH1", NodeID: "Node01", Timestamp: &ticks}
+		got := averaging.eventTime(data, start.Add(tt.arrival))
+		if want := start.Add(tt.want); !got.Equal(want) {
+			t.Errorf("%s: event time %s, want %s", tt.name, got.Format(time.TimeOnly), want.Format(time.TimeOnly))
+		}
+	}
+}
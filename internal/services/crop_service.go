@@ -0,0 +1,316 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// Errors returned by the crop planting methods
+var (
+	ErrInvalidPlanting  = errors.New("invalid crop planting")
+	ErrPlantingNotFound = errors.New("no crop planted in greenhouse")
+)
+
+// gddPrecision is the number of decimals GDD and crop temperatures are reported with
+const gddPrecision = 1
+
+// cropFile is the on-disk format of the persisted plantings
+type cropFile struct {
+	Plantings []models.CropPlanting `json:"plantings"`
+}
+
+// SensorMeansReader reads the stored means of a sensor (implemented by InfluxDBService)
+type SensorMeansReader interface {
+	GetSensorMeansFromDB(measurement, greenhouseID, sensor string, start, end time.Time) ([]SensorMean, error)
+}
+
+// CropService keeps the crop planted in each greenhouse and tracks its growth stage
+// from growing degree days (GDD) accumulated from the stored hourly temperature means
+type CropService struct {
+	mu        sync.Mutex
+	cfg       *config.CropConfig
+	plantings map[string]*models.CropPlanting // key: greenhouse_id
+	means     SensorMeansReader
+}
+
+// NewCropService creates a new crop service, loading persisted plantings if present
+func NewCropService(cfg *config.CropConfig, means SensorMeansReader) *CropService {
+	c := &CropService{
+		cfg:       cfg,
+		plantings: make(map[string]*models.CropPlanting),
+		means:     means,
+	}
+	if cfg.File == "" {
+		return c
+	}
+
+	data, err := os.ReadFile(cfg.File)
+	if os.IsNotExist(err) {
+		return c
+	}
+	if err != nil {
+		log.Fatalf("Failed to read crop plantings %s: %v", cfg.File, err)
+	}
+	var file cropFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Fatalf("Failed to parse crop plantings %s: %v", cfg.File, err)
+	}
+	for i := range file.Plantings {
+		planting := file.Plantings[i]
+		if err := validatePlanting(&planting); err != nil {
+			log.Printf("Warning: Skipping crop planting of %s from %s: %v", planting.GreenhouseID, cfg.File, err)
+			continue
+		}
+		c.plantings[planting.GreenhouseID] = &planting
+	}
+	log.Printf("Loaded %d crop plantings from %s", len(c.plantings), cfg.File)
+	return c
+}
+
+// validatePlanting checks a planting and orders its stages by GDD
+func validatePlanting(planting *models.CropPlanting) error {
+	if err := ValidateIdentifier("greenhouse_id", planting.GreenhouseID); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPlanting, err)
+	}
+	if planting.Crop == "" {
+		return fmt.Errorf("%w: crop is required", ErrInvalidPlanting)
+	}
+	if _, err := time.Parse(dayLayout, planting.PlantingDate); err != nil {
+		return fmt.Errorf("%w: planting_date must be a day such as 2024-03-01", ErrInvalidPlanting)
+	}
+	if planting.CapTemp <= planting.BaseTemp {
+		return fmt.Errorf("%w: cap_temp must be greater than base_temp", ErrInvalidPlanting)
+	}
+	if len(planting.Stages) == 0 {
+		return fmt.Errorf("%w: at least one stage is required", ErrInvalidPlanting)
+	}
+	sort.SliceStable(planting.Stages, func(i, j int) bool { return planting.Stages[i].GDD < planting.Stages[j].GDD })
+	names := make(map[string]bool)
+	for _, stage := range planting.Stages {
+		if stage.Name == "" {
+			return fmt.Errorf("%w: every stage needs a name", ErrInvalidPlanting)
+		}
+		if names[stage.Name] {
+			return fmt.Errorf("%w: duplicate stage %q", ErrInvalidPlanting, stage.Name)
+		}
+		if stage.GDD < 0 {
+			return fmt.Errorf("%w: stage %q has negative gdd", ErrInvalidPlanting, stage.Name)
+		}
+		names[stage.Name] = true
+	}
+	return nil
+}
+
+// GetPlanting returns the planting of a greenhouse
+func (c *CropService) GetPlanting(greenhouseID string) (models.CropPlanting, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	planting, ok := c.plantings[greenhouseID]
+	if !ok {
+		return models.CropPlanting{}, ErrPlantingNotFound
+	}
+	return *planting, nil
+}
+
+// SetPlanting validates, stores and persists the planting of a greenhouse, replacing any previous one
+func (c *CropService) SetPlanting(greenhouseID string, planting models.CropPlanting) (models.CropPlanting, error) {
+	planting.GreenhouseID = greenhouseID
+	if err := validatePlanting(&planting); err != nil {
+		return models.CropPlanting{}, err
+	}
+	now := time.Now().UTC()
+	planting.CreatedAt = now
+	planting.UpdatedAt = now
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old, ok := c.plantings[greenhouseID]
+	if ok {
+		planting.CreatedAt = old.CreatedAt
+	}
+	c.plantings[greenhouseID] = &planting
+	if err := c.save(); err != nil {
+		if ok {
+			c.plantings[greenhouseID] = old
+		} else {
+			delete(c.plantings, greenhouseID)
+		}
+		return models.CropPlanting{}, err
+	}
+	return planting, nil
+}
+
+// DeletePlanting removes the planting of a greenhouse
+func (c *CropService) DeletePlanting(greenhouseID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old, ok := c.plantings[greenhouseID]
+	if !ok {
+		return ErrPlantingNotFound
+	}
+	delete(c.plantings, greenhouseID)
+	if err := c.save(); err != nil {
+		c.plantings[greenhouseID] = old
+		return err
+	}
+	return nil
+}
+
+// save writes the plantings to the plantings file atomically. Must be called with mu held
+func (c *CropService) save() error {
+	if c.cfg.File == "" {
+		return nil
+	}
+	file := cropFile{Plantings: make([]models.CropPlanting, 0, len(c.plantings))}
+	for _, planting := range c.plantings {
+		file.Plantings = append(file.Plantings, *planting)
+	}
+	sort.Slice(file.Plantings, func(i, j int) bool {
+		return file.Plantings[i].GreenhouseID < file.Plantings[j].GreenhouseID
+	})
+	if err := writeJSONFile(c.cfg.File, file); err != nil {
+		return fmt.Errorf("failed to save crop plantings: %w", err)
+	}
+	return nil
+}
+
+// Status accumulates the GDD of a greenhouse's planting from the hourly temperature
+// rollups up to now and reports the current stage with reached and projected stage dates
+func (c *CropService) Status(greenhouseID string, now time.Time) (models.CropStatus, error) {
+	planting, err := c.GetPlanting(greenhouseID)
+	if err != nil {
+		return models.CropStatus{}, err
+	}
+	location := c.cfg.Location
+	planted, _ := time.ParseInLocation(dayLayout, planting.PlantingDate, location)
+	local := now.In(location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+
+	var means []SensorMean
+	if planted.Before(now) {
+		measurement, _ := MeasurementForResolution(Resolution1h)
+		if means, err = c.means.GetSensorMeansFromDB(measurement, greenhouseID, c.cfg.Sensor, planted, now); err != nil {
+			return models.CropStatus{}, err
+		}
+	}
+	days := accumulateGDD(means, &planting, location)
+
+	status := models.CropStatus{
+		Planting: planting,
+		Sensor:   c.cfg.Sensor,
+		Date:     today.Format(dayLayout),
+		Day:      max(daysBetween(planted, today)+1, 0),
+		Days:     days,
+	}
+	if len(days) > 0 {
+		status.GDD = days[len(days)-1].Total
+	}
+	status.DailyRate = c.dailyRate(days, status.Date)
+
+	status.Stages = make([]models.CropStageStatus, 0, len(planting.Stages))
+	for _, stage := range planting.Stages {
+		s := models.CropStageStatus{Name: stage.Name, GDD: stage.GDD}
+		if stage.GDD == 0 && !planted.After(today) {
+			s.Reached, s.Date = true, planting.PlantingDate
+		}
+		for _, day := range days {
+			if !s.Reached && day.Total >= stage.GDD {
+				s.Reached, s.Date = true, day.Date
+			}
+		}
+		if s.Reached {
+			status.Stage = stage.Name
+		} else if status.DailyRate > 0 {
+			remaining := math.Ceil((stage.GDD - status.GDD) / status.DailyRate)
+			s.Date = today.AddDate(0, 0, int(remaining)).Format(dayLayout)
+			s.Projected = true
+		}
+		status.Stages = append(status.Stages, s)
+	}
+	return status, nil
+}
+
+// dailyRate returns the mean GDD of the most recent days before today with data,
+// up to the configured number of projection days
+func (c *CropService) dailyRate(days []models.CropDay, today string) float64 {
+	values := make([]float64, 0, c.cfg.ProjectionDays)
+	for i := len(days) - 1; i >= 0 && len(values) < c.cfg.ProjectionDays; i-- {
+		if days[i].Date < today {
+			values = append(values, days[i].GDD)
+		}
+	}
+	if len(values) == 0 {
+		return 0
+	}
+	precision := gddPrecision + 1
+	return roundTo(calculateStats(values).Mean, &precision)
+}
+
+// accumulateGDD sums the GDD of each local day from hourly temperature means, oldest day first
+// The nodes of the greenhouse are averaged per hour; each hour adds
+// (min(max(temperature, base), cap) - base) / 24, so hours without data add nothing
+func accumulateGDD(means []SensorMean, planting *models.CropPlanting, location *time.Location) []models.CropDay {
+	hours := make(map[time.Time][]float64)
+	for _, mean := range means {
+		hours[mean.Time] = append(hours[mean.Time], mean.Value)
+	}
+
+	type dayTotals struct {
+		hours   int
+		sumTemp float64
+		gdd     float64
+	}
+	totals := make(map[string]*dayTotals)
+	for end, values := range hours {
+		// Hourly rollups are stamped with the end of the hour
+		date := end.Add(-time.Hour).In(location).Format(dayLayout)
+		day, ok := totals[date]
+		if !ok {
+			day = &dayTotals{}
+			totals[date] = day
+		}
+		temperature := calculateStats(values).Mean
+		day.hours++
+		day.sumTemp += temperature
+		day.gdd += (math.Min(math.Max(temperature, planting.BaseTemp), planting.CapTemp) - planting.BaseTemp) / 24
+	}
+
+	dates := make([]string, 0, len(totals))
+	for date := range totals {
+		if date >= planting.PlantingDate {
+			dates = append(dates, date)
+		}
+	}
+	sort.Strings(dates)
+
+	precision := gddPrecision
+	days := make([]models.CropDay, 0, len(dates))
+	total := 0.0
+	for _, date := range dates {
+		day := totals[date]
+		total += day.gdd
+		days = append(days, models.CropDay{
+			Date:     date,
+			Hours:    day.hours,
+			MeanTemp: roundTo(day.sumTemp/float64(day.hours), &precision),
+			GDD:      roundTo(day.gdd, &precision),
+			Total:    roundTo(total, &precision),
+		})
+	}
+	return days
+}
+
+// daysBetween returns the number of calendar days from one local midnight to another
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// cropTestLocation is a non-UTC zone, so local days differ from UTC days
+var cropTestLocation = time.FixedZone("EST", -5*3600)
+
+// syntheticMeans serves hourly temperature means and records the query it was asked
+type syntheticMeans struct {
+	means                             []SensorMean
+	measurement, greenhouseID, sensor string
+	start, end                        time.Time
+}
+
+func (m *syntheticMeans) GetSensorMeansFromDB(measurement, greenhouseID, sensor string, start, end time.Time) ([]SensorMean, error) {
+	m.measurement, m.greenhouseID, m.sensor, m.start, m.end = measurement, greenhouseID, sensor, start, end
+	return m.means, nil
+}
+
+// hourlyMeans returns the means of two nodes for each hour from the local midnight
+// of from, stamped with the end of the hour. The nodes read 1 °C below and above
+// the temperature of the hour's local day
+func hourlyMeans(from time.Time, temperatures []float64, hours int) []SensorMean {
+	means := make([]SensorMean, 0, 2*hours)
+	for h := 0; h < hours; h++ {
+		end := from.Add(time.Duration(h+1) * time.Hour)
+		temperature := temperatures[h/24]
+		means = append(means,
+			SensorMean{NodeID: "Node01", Time: end.UTC(), Value: temperature - 1},
+			SensorMean{NodeID: "Node02", Time: end.UTC(), Value: temperature + 1})
+	}
+	return means
+}
+
+func TestCropStatus(t *testing.T) {
+	planted := time.Date(2024, 3, 1, 0, 0, 0, 0, cropTestLocation)
+	// Local days: 22 °C (12 GDD), 16 °C (6), 40 °C capped at 30 (20), 5 °C (0),
+	// then 12 hours of 22 °C today (6)
+	means := hourlyMeans(planted, []float64{22, 16, 40, 5, 22}, 4*24+12)
+	// The hour ending at local midnight of the planting day belongs to the day before
+	means = append(means, SensorMean{NodeID: "Node01", Time: planted.UTC(), Value: 30})
+	source := &syntheticMeans{means: means}
+
+	crops := NewCropService(&config.CropConfig{Sensor: "Air_Temp", Location: cropTestLocation, ProjectionDays: 3}, source)
+	if _, err := crops.SetPlanting("GH1", models.CropPlanting{
+		Crop:         "tomato",
+		PlantingDate: "2024-03-01",
+		BaseTemp:     10,
+		CapTemp:      30,
+		Stages: []models.CropStage{
+			{Name: "fruiting", GDD: 60},
+			{Name: "emergence", GDD: 0},
+			{Name: "flowering", GDD: 35},
+			{Name: "vegetative", GDD: 15},
+		},
+	}); err != nil {
+		t.Fatalf("SetPlanting: %v", err)
+	}
+
+	now := planted.AddDate(0, 0, 4).Add(12 * time.Hour) // Noon on 5 March, 17:00 UTC
+	status, err := crops.Status("GH1", now)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+
+	hourly, _ := MeasurementForResolution(Resolution1h)
+	if source.measurement != hourly || source.greenhouseID != "GH1" || source.sensor != "Air_Temp" ||
+		!source.start.Equal(planted) || !source.end.Equal(now) {
+		t.Errorf("queried %s %s %s from %s to %s", source.measurement, source.greenhouseID, source.sensor, source.start, source.end)
+	}
+
+	wantDays := []models.CropDay{
+		{Date: "2024-03-01", Hours: 24, MeanTemp: 22, GDD: 12, Total: 12},
+		{Date: "2024-03-02", Hours: 24, MeanTemp: 16, GDD: 6, Total: 18},
+		{Date: "2024-03-03", Hours: 24, MeanTemp: 40, GDD: 20, Total: 38},
+		{Date: "2024-03-04", Hours: 24, MeanTemp: 5, GDD: 0, Total: 38},
+		{Date: "2024-03-05", Hours: 12, MeanTemp: 22, GDD: 6, Total: 44},
+	}
+	if !reflect.DeepEqual(status.Days, wantDays) {
+		t.Errorf("days = %+v\nwant %+v", status.Days, wantDays)
+	}
+	if status.Date != "2024-03-05" || status.Day != 5 || status.GDD != 44 || status.Stage != "flowering" {
+		t.Errorf("status on %s (day %d): %v GDD, stage %q; want 2024-03-05 (day 5), 44 GDD, flowering",
+			status.Date, status.Day, status.GDD, status.Stage)
+	}
+	// The rate is the mean of the 3 complete days before today: (6 + 20 + 0) / 3
+	if status.DailyRate != 8.67 {
+		t.Errorf("daily rate = %v, want 8.67", status.DailyRate)
+	}
+
+	// 16 GDD to fruiting at 8.67 a day takes 2 more days
+	wantStages := []models.CropStageStatus{
+		{Name: "emergence", GDD: 0, Reached: true, Date: "2024-03-01"},
+		{Name: "vegetative", GDD: 15, Reached: true, Date: "2024-03-02"},
+		{Name: "flowering", GDD: 35, Reached: true, Date: "2024-03-03"},
+		{Name: "fruiting", GDD: 60, Date: "2024-03-07", Projected: true},
+	}
+	if !reflect.DeepEqual(status.Stages, wantStages) {
+		t.Errorf("stages = %+v\nwant %+v", status.Stages, wantStages)
+	}
+}
+
+func TestCropStatusBeforePlanting(t *testing.T) {
+	source := &syntheticMeans{}
+	crops := NewCropService(&config.CropConfig{Sensor: "Air_Temp", Location: cropTestLocation, ProjectionDays: 3}, source)
+	if _, err := crops.SetPlanting("GH1", models.CropPlanting{
+		Crop: "tomato", PlantingDate: "2024-03-01", BaseTemp: 10, CapTemp: 30,
+		Stages: []models.CropStage{{Name: "emergence", GDD: 0}, {Name: "flowering", GDD: 35}},
+	}); err != nil {
+		t.Fatalf("SetPlanting: %v", err)
+	}
+
+	// 23:00 local on the day before planting is already 1 March in UTC
+	status, err := crops.Status("GH1", time.Date(2024, 2, 29, 23, 0, 0, 0, cropTestLocation))
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if source.measurement != "" {
+		t.Errorf("means queried before the planting date")
+	}
+	if status.Date != "2024-02-29" || status.Day != 0 || status.Stage != "" || status.Stages[0].Reached || status.Stages[1].Date != "" {
+		t.Errorf("unexpected status before planting: %+v", status)
+	}
+	if _, err := crops.Status("GH2", time.Now()); !errors.Is(err, ErrPlantingNotFound) {
+		t.Errorf("Status of a greenhouse without a planting: %v", err)
+	}
+}
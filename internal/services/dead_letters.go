@@ -0,0 +1,363 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"iot-agriculture-backend/internal/models"
+)
+
+// DeadLetterQuery filters dead letters; empty fields match everything
+type DeadLetterQuery struct {
+	GreenhouseID string
+	NodeID       string
+	Reason       string
+	Limit        int
+}
+
+// DeadLetterStore keeps the most recent rejected data messages, oldest first.
+// With a file, entries are appended as JSON lines and survive restarts so they
+// can be replayed after the sensor registry is fixed; the file is rewritten when
+// entries are removed or it has grown to twice the store's capacity
+type DeadLetterStore struct {
+	mu      sync.Mutex
+	entries []models.DeadLetter
+	nextID  int64
+	size    int
+	path    string
+	lines   int // Entries written to the file since it was last rewritten
+	metrics *MetricsService
+}
+
+// NewDeadLetterStore creates a dead-letter store holding up to size entries,
+// loading the entries kept in path ("" = in memory only)
+func NewDeadLetterStore(path string, size int, metrics *MetricsService) *DeadLetterStore {
+	d := &DeadLetterStore{size: size, path: path, nextID: 1, metrics: metrics}
+	if size == 0 {
+		d.path = ""
+		return d
+	}
+	if path != "" {
+		if err := d.load(); err != nil {
+			log.Printf("Warning: Dead-letter file %s not loaded, keeping dead letters in memory: %v", path, err)
+			d.path = ""
+		}
+	}
+	d.metrics.SetDeadLetters(len(d.entries))
+	return d
+}
+
+// load reads the entries kept in the dead-letter file
+func (d *DeadLetterStore) load() error {
+	file, err := os.Open(d.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	torn := false
+	for scanner.Scan() {
+		var entry models.DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			torn = true // Skip a line torn by a crash mid-write
+			continue
+		}
+		d.entries = append(d.entries, entry)
+		if entry.ID >= d.nextID {
+			d.nextID = entry.ID + 1
+		}
+		d.lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	d.trim()
+	if torn {
+		// Drop the torn line, or the next entry would be appended to it
+		d.rewrite()
+	}
+	if len(d.entries) > 0 {
+		log.Printf("Loaded %d dead letters from %s", len(d.entries), d.path)
+	}
+	return nil
+}
+
+// Add stores a rejected message, evicting the oldest entry when the store is full
+func (d *DeadLetterStore) Add(entry models.DeadLetter) {
+	if d.size == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry.ID = d.nextID
+	d.nextID++
+	d.entries = append(d.entries, entry)
+	d.trim()
+	d.metrics.SetDeadLetters(len(d.entries))
+
+	if d.path == "" {
+		return
+	}
+	if d.lines >= 2*d.size {
+		d.rewrite()
+		return
+	}
+	if err := d.appendLine(entry); err != nil {
+		log.Printf("Warning: Failed to write dead letter: %v", err)
+		return
+	}
+	d.lines++
+}
+
+// List returns the entries matching the query, newest first
+func (d *DeadLetterStore) List(query DeadLetterQuery) []models.DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]models.DeadLetter, 0)
+	for i := len(d.entries) - 1; i >= 0; i-- {
+		if query.Limit > 0 && len(out) >= query.Limit {
+			break
+		}
+		entry := d.entries[i]
+		if (query.GreenhouseID != "" && entry.GreenhouseID != query.GreenhouseID) ||
+			(query.NodeID != "" && entry.NodeID != query.NodeID) ||
+			(query.Reason != "" && !hasReason(entry.Rejections, query.Reason)) {
+			continue
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// Get returns the entry with the given ID
+func (d *DeadLetterStore) Get(id int64) (models.DeadLetter, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if i := d.index(id); i >= 0 {
+		return d.entries[i], true
+	}
+	return models.DeadLetter{}, false
+}
+
+// IDs returns the IDs of all entries, oldest first
+func (d *DeadLetterStore) IDs() []int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ids := make([]int64, len(d.entries))
+	for i, entry := range d.entries {
+		ids[i] = entry.ID
+	}
+	return ids
+}
+
+// Update replaces the entries with the same IDs that are still stored
+func (d *DeadLetterStore) Update(entries ...models.DeadLetter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	updated := false
+	for _, entry := range entries {
+		if i := d.index(entry.ID); i >= 0 {
+			d.entries[i] = entry
+			updated = true
+		}
+	}
+	if updated {
+		d.rewrite()
+	}
+}
+
+// Delete removes the entries with the given IDs and returns how many were removed
+func (d *DeadLetterStore) Delete(ids ...int64) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	remove := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	kept := d.entries[:0]
+	for _, entry := range d.entries {
+		if !remove[entry.ID] {
+			kept = append(kept, entry)
+		}
+	}
+	removed := len(d.entries) - len(kept)
+	d.entries = kept
+	if removed > 0 {
+		d.metrics.SetDeadLetters(len(d.entries))
+		d.rewrite()
+	}
+	return removed
+}
+
+// trim evicts the oldest entries beyond the store's size. Must be called with mu held
+func (d *DeadLetterStore) trim() {
+	if excess := len(d.entries) - d.size; excess > 0 {
+		d.entries = append(d.entries[:0], d.entries[excess:]...)
+	}
+}
+
+// index returns the position of the entry with the given ID, or -1. Must be called with mu held
+func (d *DeadLetterStore) index(id int64) int {
+	for i, entry := range d.entries {
+		if entry.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// appendLine appends one entry to the dead-letter file. Must be called with mu held
+func (d *DeadLetterStore) appendLine(entry models.DeadLetter) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(d.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// rewrite replaces the dead-letter file with the current entries atomically
+// Must be called with mu held
+func (d *DeadLetterStore) rewrite() {
+	if d.path == "" {
+		return
+	}
+	if err := d.writeFile(); err != nil {
+		log.Printf("Warning: Failed to rewrite dead-letter file: %v", err)
+		return
+	}
+	d.lines = len(d.entries)
+}
+
+// writeFile writes all entries to a temporary file and renames it over the dead-letter file
+func (d *DeadLetterStore) writeFile() error {
+	if err := os.MkdirAll(filepath.Dir(d.path), 0o755); err != nil {
+		return err
+	}
+	tmp := d.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range d.entries {
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, d.path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", d.path, err)
+	}
+	return nil
+}
+
+// hasReason returns true if any rejection has the given reason
+func hasReason(rejections []models.Rejection, reason string) bool {
+	for _, r := range rejections {
+		if r.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// newDeadLetter builds the dead letter of a message rejected at receivedAt,
+// attributed to the parsed IDs or, if parsing failed, to the topic's
+func newDeadLetter(topic string, payload []byte, data models.ESP32SensorData, rejections []models.Rejection, receivedAt time.Time) models.DeadLetter {
+	entry := models.DeadLetter{
+		ReceivedAt:   receivedAt.UTC(),
+		Topic:        topic,
+		GreenhouseID: data.GreenhouseID,
+		NodeID:       data.NodeID,
+		Payload:      string(payload),
+		Rejections:   rejections,
+	}
+	if entry.GreenhouseID == "" && entry.NodeID == "" {
+		entry.GreenhouseID, entry.NodeID, _ = parseNodeTopic(topic)
+	}
+	return entry
+}
+
+// describeRejections joins the messages of a message's rejections
+func describeRejections(rejections []models.Rejection) string {
+	messages := make([]string, len(rejections))
+	for i, r := range rejections {
+		messages[i] = r.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ReplayDeadLetters runs dead letters through validation and averaging again with
+// their original receive time, e.g. after the sensor registry was corrected.
+// Accepted messages are removed from the store; messages rejected again are kept
+// with their new reasons. Messages whose window has closed are only stored raw.
+// An empty ids replays every dead letter, oldest first
+func (s *SensorService) ReplayDeadLetters(ids []int64) models.ReplayResult {
+	if len(ids) == 0 {
+		ids = s.deadLetters.IDs()
+	}
+	var result models.ReplayResult
+	var accepted []int64
+	var rejected []models.DeadLetter
+	for _, id := range ids {
+		entry, ok := s.deadLetters.Get(id)
+		if !ok {
+			result.Missing = append(result.Missing, id)
+			continue
+		}
+		result.Replayed++
+		data, late, rejections := s.ingest(entry.Topic, []byte(entry.Payload), entry.ReceivedAt, false)
+		if len(rejections) > 0 {
+			updated := newDeadLetter(entry.Topic, []byte(entry.Payload), data, rejections, entry.ReceivedAt)
+			updated.ID, updated.Replays = entry.ID, entry.Replays+1
+			rejected = append(rejected, updated)
+			result.Rejected++
+			continue
+		}
+		accepted = append(accepted, id)
+		result.Accepted++
+		if late {
+			result.Late++
+		}
+	}
+	s.deadLetters.Update(rejected...)
+	s.deadLetters.Delete(accepted...)
+	if result.Replayed > 0 {
+		log.Printf("Replayed %d dead letters: %d accepted (%d late), %d rejected again",
+			result.Replayed, result.Accepted, result.Late, result.Rejected)
+	}
+	return result
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// validationTestRegistry has a climate node type that must report Air_Temp and a
+// substrate-only sensor, Drip_Weight
+func validationTestRegistry() *SensorRegistry {
+	minTemp, maxTemp, minRh, maxRh, minWeight := -40.0, 80.0, 0.0, 100.0, 0.0
+	return NewSensorRegistry(&config.SensorsConfig{
+		Sensors: []config.SensorDefinition{
+			{Name: "Air_Temp", JSONKey: "Air_Temp", Unit: "°C", Type: config.SensorTypeFloat, Min: &minTemp, Max: &maxTemp},
+			{Name: "Air_Rh", JSONKey: "Air_Rh", Unit: "%", Type: config.SensorTypeFloat, Min: &minRh, Max: &maxRh},
+			{Name: "Rain", JSONKey: "Rain", Type: config.SensorTypeInt, Kind: config.SensorKindState},
+			{Name: "Drip_Weight", JSONKey: "drip_weight", Unit: "g", Type: config.SensorTypeFloat, Min: &minWeight, NodeTypes: []string{"substrate"}},
+		},
+		NodeTypes: []config.NodeTypeDefinition{
+			{Name: "climate", Nodes: []string{"Node01"}, Required: []string{"Air_Temp"}},
+			{Name: "substrate", Nodes: []string{"Node05"}},
+		},
+	})
+}
+
+// validatePayload parses and validates a payload the way ingest does
+func validatePayload(registry *SensorRegistry, payload string) []models.Rejection {
+	data, err := registry.ParseSensorData([]byte(payload), nil)
+	if err != nil {
+		return []models.Rejection{rejectionFor(err)}
+	}
+	return registry.Validate(data)
+}
+
+func TestPayloadValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    []string // Rejection reasons, in order
+		sensors []string // Sensor of each rejection
+	}{
+		{"valid", `{"greenhouse_id":"GH1","node_id":"Node01","Air_Temp":21.5,"Air_Rh":60}`, nil, nil},
+		{"at the range limits", `{"greenhouse_id":"GH1","node_id":"Node01","Air_Temp":80,"Air_Rh":0}`, nil, nil},
+		{"above the maximum", `{"greenhouse_id":"GH1","node_id":"Node01","Air_Temp":85}`, []string{models.RejectOutOfRange}, []string{"Air_Temp"}},
+		{"below the minimum", `{"greenhouse_id":"GH1","node_id":"Node01","Air_Temp":20,"Air_Rh":-1}`, []string{models.RejectOutOfRange}, []string{"Air_Rh"}},
+		{
+			"several values out of range",
+			`{"greenhouse_id":"GH1","node_id":"Node01","Air_Temp":-41,"Air_Rh":101}`,
+			[]string{models.RejectOutOfRange, models.RejectOutOfRange},
+			[]string{"Air_Temp", "Air_Rh"},
+		},
+		{"string value", `{"greenhouse_id":"GH1","node_id":"Node01","Air_Temp":"21.5"}`, []string{models.RejectInvalidValue}, []string{"Air_Temp"}},
+		{"boolean value", `{"greenhouse_id":"GH1","node_id":"Node01","Air_Temp":20,"Air_Rh":true}`, []string{models.RejectInvalidValue}, []string{"Air_Rh"}},
+		{"fraction for an int sensor", `{"greenhouse_id":"GH1","node_id":"Node01","Air_Temp":20,"Rain":0.5}`, []string{models.RejectInvalidValue}, []string{"Rain"}},
+		{"null value is absent", `{"greenhouse_id":"GH1","node_id":"Node01","Air_Temp":null}`, []string{models.RejectMissingField}, []string{"Air_Temp"}},
+		{"missing required sensor", `{"greenhouse_id":"GH1","node_id":"Node01","Air_Rh":60}`, []string{models.RejectMissingField}, []string{"Air_Temp"}},
+		{"required only for its node type", `{"greenhouse_id":"GH1","node_id":"Node05","drip_weight":250}`, nil, nil},
+		{"unknown sensors are ignored", `{"greenhouse_id":"GH1","node_id":"Node01","Air_Temp":20,"Soil_Ph":"acid","CO2":9999}`, nil, nil},
+		{"other node type's sensor is ignored", `{"greenhouse_id":"GH1","node_id":"Node01","Air_Temp":20,"drip_weight":-5}`, nil, nil},
+		{"not JSON", `Air_Temp=21.5`, []string{models.RejectInvalidJSON}, []string{""}},
+		{"not an object", `[21.5]`, []string{models.RejectInvalidJSON}, []string{""}},
+		{"wrong ID type", `{"greenhouse_id":1,"node_id":"Node01","Air_Temp":20}`, []string{models.RejectInvalidValue}, []string{""}},
+	}
+	registry := validationTestRegistry()
+	for _, tt := range tests {
+		rejections := validatePayload(registry, tt.payload)
+		var reasons, sensors []string
+		for _, r := range rejections {
+			reasons = append(reasons, r.Reason)
+			sensors = append(sensors, r.Sensor)
+			if r.Message == "" {
+				t.Errorf("%s: rejection without a message: %+v", tt.name, r)
+			}
+		}
+		if !reflect.DeepEqual(reasons, tt.want) || !reflect.DeepEqual(sensors, tt.sensors) {
+			t.Errorf("%s: got %+v, want reasons %v for %v", tt.name, rejections, tt.want, tt.sensors)
+		}
+	}
+}
+
+// testDeadLetter returns a dead letter of a node rejected for reason
+func testDeadLetter(greenhouseID, nodeID, reason string) models.DeadLetter {
+	return models.DeadLetter{
+		ReceivedAt:   time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		Topic:        "greenhouse/" + greenhouseID + "/node/" + nodeID + "/data",
+		GreenhouseID: greenhouseID,
+		NodeID:       nodeID,
+		Payload:      `{"Air_Temp":85}`,
+		Rejections:   []models.Rejection{{Reason: reason, Sensor: "Air_Temp", Message: reason}},
+	}
+}
+
+// deadLetterIDs returns the IDs of dead letters in order
+func deadLetterIDs(entries []models.DeadLetter) []int64 {
+	ids := make([]int64, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	return ids
+}
+
+func TestDeadLetterStoreList(t *testing.T) {
+	d := NewDeadLetterStore("", 10, sharedTestMetrics())
+	d.Add(testDeadLetter("GH1", "Node01", models.RejectOutOfRange))
+	d.Add(testDeadLetter("GH1", "Node02", models.RejectMissingField))
+	d.Add(testDeadLetter("GH2", "Node01", models.RejectOutOfRange))
+	d.Add(testDeadLetter("GH1", "Node01", models.RejectInvalidValue))
+
+	tests := []struct {
+		name  string
+		query DeadLetterQuery
+		want  []int64
+	}{
+		{"all, newest first", DeadLetterQuery{}, []int64{4, 3, 2, 1}},
+		{"limit", DeadLetterQuery{Limit: 2}, []int64{4, 3}},
+		{"greenhouse", DeadLetterQuery{GreenhouseID: "GH1"}, []int64{4, 2, 1}},
+		{"node", DeadLetterQuery{NodeID: "Node01"}, []int64{4, 3, 1}},
+		{"greenhouse and node", DeadLetterQuery{GreenhouseID: "GH1", NodeID: "Node01", Limit: 1}, []int64{4}},
+		{"reason", DeadLetterQuery{Reason: models.RejectOutOfRange}, []int64{3, 1}},
+		{"no match", DeadLetterQuery{Reason: models.RejectInvalidJSON}, []int64{}},
+	}
+	for _, tt := range tests {
+		if got := deadLetterIDs(d.List(tt.query)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got IDs %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if entry, ok := d.Get(2); !ok || entry.NodeID != "Node02" {
+		t.Errorf("Get(2) = %+v, %v", entry, ok)
+	}
+	if _, ok := d.Get(99); ok {
+		t.Errorf("Get(99) found an entry")
+	}
+}
+
+func TestDeadLetterStoreEvictsOldest(t *testing.T) {
+	d := NewDeadLetterStore("", 3, sharedTestMetrics())
+	for n := 0; n < 5; n++ {
+		d.Add(testDeadLetter("GH1", "Node01", models.RejectOutOfRange))
+	}
+	if got := d.IDs(); !reflect.DeepEqual(got, []int64{3, 4, 5}) {
+		t.Errorf("expected the 3 newest entries, got IDs %v", got)
+	}
+}
+
+func TestDeadLetterStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead_letters.jsonl")
+	d := NewDeadLetterStore(path, 10, sharedTestMetrics())
+	d.Add(testDeadLetter("GH1", "Node01", models.RejectOutOfRange))
+	d.Add(testDeadLetter("GH1", "Node02", models.RejectMissingField))
+	d.Add(testDeadLetter("GH2", "Node01", models.RejectInvalidValue))
+
+	reloaded := NewDeadLetterStore(path, 10, sharedTestMetrics())
+	if got, want := reloaded.List(DeadLetterQuery{}), d.List(DeadLetterQuery{}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("reloaded entries differ:\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Deletes and updates are persisted, and IDs keep increasing across restarts
+	reloaded.Delete(1)
+	updated, _ := reloaded.Get(3)
+	updated.Replays = 2
+	reloaded.Update(updated)
+	reloaded.Add(testDeadLetter("GH1", "Node03", models.RejectOutOfRange))
+
+	// A line torn by a crash mid-write is skipped
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("open dead-letter file: %v", err)
+	}
+	f.WriteString(`{"id":5,"topic":"greenhouse/GH1`)
+	f.Close()
+
+	again := NewDeadLetterStore(path, 10, sharedTestMetrics())
+	if got := again.IDs(); !reflect.DeepEqual(got, []int64{2, 3, 4}) {
+		t.Errorf("expected IDs [2 3 4] after reload, got %v", got)
+	}
+	if entry, _ := again.Get(3); entry.Replays != 2 {
+		t.Errorf("update not persisted: %+v", entry)
+	}
+	again.Add(testDeadLetter("GH1", "Node01", models.RejectOutOfRange))
+	if ids := again.IDs(); ids[len(ids)-1] != 5 {
+		t.Errorf("expected the next ID to be 5, got IDs %v", ids)
+	}
+
+	// A smaller store keeps the newest entries of the file
+	if got := NewDeadLetterStore(path, 2, sharedTestMetrics()).IDs(); !reflect.DeepEqual(got, []int64{4, 5}) {
+		t.Errorf("expected IDs [4 5] in a store of 2, got %v", got)
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"math"
+
+	"iot-agriculture-backend/internal/models"
+)
+
+// Derived metric names (InfluxDB fields of the averages measurements)
+const (
+	DerivedAirVPD           = "air_vpd"           // Air vapor pressure deficit
+	DerivedLeafVPD          = "leaf_vpd"          // Leaf-to-air vapor pressure deficit
+	DerivedDewPoint         = "dew_point"         // Dew point of the air
+	DerivedAbsoluteHumidity = "absolute_humidity" // Water vapor density of the air
+	DerivedLeafAirDelta     = "leaf_air_delta"    // Leaf temperature minus air temperature
+)
+
+// Sensors the derived metrics are computed from
+const (
+	airTempSensor  = "Air_Temp"
+	airRhSensor    = "Air_Rh"
+	leafTempSensor = "Leaf_temp"
+)
+
+// derivedMetric is an agronomic metric computed from the window means of other sensors
+type derivedMetric struct {
+	name       string
+	unit       string
+	precision  int
+	difference bool     // A temperature difference: unit conversions apply the factor only
+	inputs     []string // Sensors whose window means must all be present
+	compute    func(means map[string]float64) (float64, bool)
+}
+
+// derivedMetrics lists the derived metrics in display order
+var derivedMetrics = []derivedMetric{
+	{
+		name: DerivedAirVPD, unit: "kPa", precision: 3,
+		inputs: []string{airTempSensor, airRhSensor},
+		compute: func(m map[string]float64) (float64, bool) {
+			es := saturationVaporPressure(m[airTempSensor])
+			return es - actualVaporPressure(m[airTempSensor], m[airRhSensor]), true
+		},
+	},
+	{
+		name: DerivedLeafVPD, unit: "kPa", precision: 3,
+		inputs: []string{airTempSensor, airRhSensor, leafTempSensor},
+		compute: func(m map[string]float64) (float64, bool) {
+			es := saturationVaporPressure(m[leafTempSensor])
+			return es - actualVaporPressure(m[airTempSensor], m[airRhSensor]), true
+		},
+	},
+	{
+		name: DerivedDewPoint, unit: "°C", precision: 2,
+		inputs:  []string{airTempSensor, airRhSensor},
+		compute: func(m map[string]float64) (float64, bool) { return dewPoint(m[airTempSensor], m[airRhSensor]) },
+	},
+	{
+		name: DerivedAbsoluteHumidity, unit: "g/m³", precision: 2,
+		inputs: []string{airTempSensor, airRhSensor},
+		compute: func(m map[string]float64) (float64, bool) {
+			// Ideal gas law for water vapor: ρ = e / (Rv·T), with e in kPa giving 2165 g·K/m³/kPa
+			return 2165 * actualVaporPressure(m[airTempSensor], m[airRhSensor]) / (m[airTempSensor] + 273.15), true
+		},
+	},
+	{
+		name: DerivedLeafAirDelta, unit: "°C", precision: 2, difference: true,
+		inputs:  []string{airTempSensor, leafTempSensor},
+		compute: func(m map[string]float64) (float64, bool) { return m[leafTempSensor] - m[airTempSensor], true },
+	},
+}
+
+// Magnus coefficients over water (Tetens), valid for roughly -40 to 50 °C
+const (
+	magnusA = 0.61078 // kPa
+	magnusB = 17.27
+	magnusC = 237.3 // °C
+)
+
+// saturationVaporPressure returns the saturation vapor pressure in kPa at a temperature in °C
+func saturationVaporPressure(tempC float64) float64 {
+	return magnusA * math.Exp(magnusB*tempC/(tempC+magnusC))
+}
+
+// actualVaporPressure returns the vapor pressure in kPa of air at a temperature in °C and relative humidity in %
+func actualVaporPressure(tempC, rh float64) float64 {
+	return saturationVaporPressure(tempC) * rh / 100
+}
+
+// dewPoint returns the dew point in °C, or false for dry air (no dew point)
+func dewPoint(tempC, rh float64) (float64, bool) {
+	if rh <= 0 {
+		return 0, false
+	}
+	gamma := math.Log(rh/100) + magnusB*tempC/(tempC+magnusC)
+	return magnusC * gamma / (magnusB - gamma), true
+}
+
+// lookupDerivedMetric returns the derived metric with the given name
+func lookupDerivedMetric(name string) (derivedMetric, bool) {
+	for _, m := range derivedMetrics {
+		if m.name == name {
+			return m, true
+		}
+	}
+	return derivedMetric{}, false
+}
+
+// deriveMetrics computes every derived metric whose input sensors have a window mean
+func deriveMetrics(stats map[string]models.SensorStats) map[string]float64 {
+	means := make(map[string]float64, len(stats))
+	for name, s := range stats {
+		if s.Count > 0 {
+			means[name] = s.Mean
+		}
+	}
+	derived := make(map[string]float64)
+	for _, m := range derivedMetrics {
+		if !hasInputs(means, m.inputs) {
+			continue
+		}
+		if value, ok := m.compute(means); ok {
+			precision := m.precision
+			derived[m.name] = roundTo(value, &precision)
+		}
+	}
+	return derived
+}
+
+// hasInputs reports whether every input sensor has a mean
+func hasInputs(means map[string]float64, inputs []string) bool {
+	for _, name := range inputs {
+		if _, ok := means[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"iot-agriculture-backend/internal/models"
+)
+
+// windowStats returns window stats with the given sensor means
+func windowStats(means map[string]float64) map[string]models.SensorStats {
+	stats := make(map[string]models.SensorStats, len(means))
+	for name, mean := range means {
+		stats[name] = models.SensorStats{Mean: mean, Count: 10}
+	}
+	return stats
+}
+
+func TestDeriveMetricsReferenceValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		means  map[string]float64
+		metric string
+		want   float64
+		within float64
+	}{
+		{"VPD at 25 °C / 50 %", map[string]float64{"Air_Temp": 25, "Air_Rh": 50}, DerivedAirVPD, 1.58, 0.01},
+		{"VPD at 30 °C / 80 %", map[string]float64{"Air_Temp": 30, "Air_Rh": 80}, DerivedAirVPD, 0.85, 0.01},
+		{"VPD of saturated air", map[string]float64{"Air_Temp": 20, "Air_Rh": 100}, DerivedAirVPD, 0, 0},
+		{"dew point at 25 °C / 50 %", map[string]float64{"Air_Temp": 25, "Air_Rh": 50}, DerivedDewPoint, 13.9, 0.05},
+		{"dew point at 30 °C / 80 %", map[string]float64{"Air_Temp": 30, "Air_Rh": 80}, DerivedDewPoint, 26.2, 0.05},
+		{"dew point of saturated air", map[string]float64{"Air_Temp": 20, "Air_Rh": 100}, DerivedDewPoint, 20, 0},
+		{"dew point below freezing", map[string]float64{"Air_Temp": 5, "Air_Rh": 40}, DerivedDewPoint, -7.5, 0.1},
+		{"absolute humidity at 25 °C / 50 %", map[string]float64{"Air_Temp": 25, "Air_Rh": 50}, DerivedAbsoluteHumidity, 11.5, 0.05},
+		{"absolute humidity at 20 °C / 100 %", map[string]float64{"Air_Temp": 20, "Air_Rh": 100}, DerivedAbsoluteHumidity, 17.3, 0.05},
+		{"leaf VPD of a cooler leaf", map[string]float64{"Air_Temp": 25, "Air_Rh": 50, "Leaf_temp": 23}, DerivedLeafVPD, 1.23, 0.01},
+		{"leaf VPD at air temperature", map[string]float64{"Air_Temp": 25, "Air_Rh": 50, "Leaf_temp": 25}, DerivedLeafVPD, 1.58, 0.01},
+		{"leaf to air delta", map[string]float64{"Air_Temp": 25, "Leaf_temp": 23.5}, DerivedLeafAirDelta, -1.5, 0},
+	}
+	for _, tt := range tests {
+		got, ok := deriveMetrics(windowStats(tt.means))[tt.metric]
+		if !ok {
+			t.Errorf("%s: %s not derived", tt.name, tt.metric)
+			continue
+		}
+		if math.Abs(got-tt.want) > tt.within+1e-9 {
+			t.Errorf("%s: %s = %v, want %v ± %v", tt.name, tt.metric, got, tt.want, tt.within)
+		}
+	}
+}
+
+func TestDeriveMetricsMissingInputs(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats map[string]models.SensorStats
+		want  []string // Metrics that are derived; all others must be missing
+	}{
+		{"no sensors", windowStats(nil), nil},
+		{"air temperature only", windowStats(map[string]float64{"Air_Temp": 25}), nil},
+		{"humidity only", windowStats(map[string]float64{"Air_Rh": 50}), nil},
+		{
+			"air without leaf temperature",
+			windowStats(map[string]float64{"Air_Temp": 25, "Air_Rh": 50}),
+			[]string{DerivedAirVPD, DerivedDewPoint, DerivedAbsoluteHumidity},
+		},
+		{
+			"leaf without humidity",
+			windowStats(map[string]float64{"Air_Temp": 25, "Leaf_temp": 23}),
+			[]string{DerivedLeafAirDelta},
+		},
+		{
+			"dry air has no dew point",
+			windowStats(map[string]float64{"Air_Temp": 25, "Air_Rh": 0}),
+			[]string{DerivedAirVPD, DerivedAbsoluteHumidity},
+		},
+		{
+			"sensor without readings in the window",
+			map[string]models.SensorStats{"Air_Temp": {Mean: 25, Count: 10}, "Air_Rh": {}},
+			nil,
+		},
+		{
+			"all inputs",
+			windowStats(map[string]float64{"Air_Temp": 25, "Air_Rh": 50, "Leaf_temp": 23}),
+			[]string{DerivedAirVPD, DerivedLeafVPD, DerivedDewPoint, DerivedAbsoluteHumidity, DerivedLeafAirDelta},
+		},
+	}
+	for _, tt := range tests {
+		derived := deriveMetrics(tt.stats)
+		if len(derived) != len(tt.want) {
+			t.Errorf("%s: derived %v, want only %v", tt.name, derived, tt.want)
+			continue
+		}
+		for _, name := range tt.want {
+			if _, ok := derived[name]; !ok {
+				t.Errorf("%s: %s missing from %v", tt.name, name, derived)
+			}
+		}
+	}
+}
+
+func TestDeriveMetricsPrecision(t *testing.T) {
+	derived := deriveMetrics(windowStats(map[string]float64{"Air_Temp": 24.87, "Air_Rh": 61.3, "Leaf_temp": 23.41}))
+	for _, m := range derivedMetrics {
+		value := derived[m.name]
+		p := math.Pow(10, float64(m.precision))
+		if math.Abs(value*p-math.Round(value*p)) > 1e-6 {
+			t.Errorf("%s = %v has more than %d decimals", m.name, value, m.precision)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// walSegmentExt is the file extension of queue segments
+const walSegmentExt = ".wal"
+
+// walSegment is one append-only file of the disk queue
+// Each record is "<enqueue unix nanos> <line protocol>\n"
+type walSegment struct {
+	seq    uint64
+	path   string
+	size   int64
+	lines  int
+	oldest time.Time // Enqueue time of the first unread record
+}
+
+// WALStats describes the current contents of the disk queue
+type WALStats struct {
+	Points    int
+	Bytes     int64
+	OldestAge time.Duration
+}
+
+// DiskQueue is a durable FIFO of line protocol records stored in fsynced segment files
+// Records are appended to the newest segment and read from the oldest one; fully
+// replayed segments are deleted
+type DiskQueue struct {
+	mu           sync.Mutex
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+	segments     []*walSegment // oldest first; the last one is the active segment
+	active       *os.File
+	readOffset   int64 // Bytes of segments[0] already replayed
+	lines        int
+	bytes        int64
+}
+
+// NewDiskQueue opens the disk queue in dir, picking up segments left by a previous run
+func NewDiskQueue(dir string, maxBytes, segmentBytes int64) (*DiskQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create WAL directory: %w", err)
+	}
+	q := &DiskQueue{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		segmentBytes: segmentBytes,
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAL directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, walSegmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, walSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segment, err := scanSegment(seq, filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if segment.lines == 0 {
+			os.Remove(segment.path)
+			continue
+		}
+		q.segments = append(q.segments, segment)
+		q.lines += segment.lines
+		q.bytes += segment.size
+	}
+	sort.Slice(q.segments, func(a, b int) bool { return q.segments[a].seq < q.segments[b].seq })
+
+	if err := q.rotate(); err != nil {
+		return nil, err
+	}
+	if q.lines > 0 {
+		log.Printf("WAL: found %d queued points (%d bytes) in %s", q.lines, q.bytes, dir)
+	}
+	return q, nil
+}
+
+// scanSegment counts the complete records of a segment left on disk
+// A torn final record (crash during append) is truncated away
+func scanSegment(seq uint64, path string) (*walSegment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAL segment %s: %w", path, err)
+	}
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	if complete < len(data) {
+		if err := os.Truncate(path, int64(complete)); err != nil {
+			return nil, fmt.Errorf("failed to truncate WAL segment %s: %w", path, err)
+		}
+		data = data[:complete]
+	}
+	segment := &walSegment{seq: seq, path: path, size: int64(len(data)), lines: bytes.Count(data, []byte{'\n'})}
+	if segment.lines > 0 {
+		segment.oldest = recordTime(data)
+	}
+	return segment, nil
+}
+
+// recordTime parses the enqueue time at the start of a record
+func recordTime(record []byte) time.Time {
+	end := bytes.IndexByte(record, ' ')
+	if end < 0 {
+		return time.Time{}
+	}
+	nanos, err := strconv.ParseInt(string(record[:end]), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// rotate closes the active segment and starts a new one
+// Must be called with mu held (or before the queue is shared)
+func (q *DiskQueue) rotate() error {
+	var seq uint64 = 1
+	if n := len(q.segments); n > 0 {
+		seq = q.segments[n-1].seq + 1
+	}
+	if q.active != nil {
+		q.active.Close()
+		q.active = nil
+	}
+	path := filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, walSegmentExt))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create WAL segment: %w", err)
+	}
+	q.active = f
+	q.segments = append(q.segments, &walSegment{seq: seq, path: path})
+	return nil
+}
+
+// Append durably stores line protocol records; it returns once they are fsynced
+func (q *DiskQueue) Append(lines ...string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	now := time.Now()
+	var buf bytes.Buffer
+	for _, line := range lines {
+		fmt.Fprintf(&buf, "%d %s\n", now.UnixNano(), line)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.active == nil {
+		return fmt.Errorf("WAL is closed")
+	}
+	segment := q.segments[len(q.segments)-1]
+	if segment.size > 0 && segment.size+int64(buf.Len()) > q.segmentBytes {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+		segment = q.segments[len(q.segments)-1]
+	}
+	if _, err := q.active.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to append to WAL: %w", err)
+	}
+	if err := q.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync WAL: %w", err)
+	}
+	if segment.lines == 0 {
+		segment.oldest = now
+	}
+	segment.size += int64(buf.Len())
+	segment.lines += len(lines)
+	q.lines += len(lines)
+	q.bytes += int64(buf.Len())
+
+	q.enforceLimit()
+	return nil
+}
+
+// enforceLimit drops the oldest segments while the queue is larger than maxBytes
+// The active segment is never dropped. Must be called with mu held
+func (q *DiskQueue) enforceLimit() {
+	for q.bytes > q.maxBytes && len(q.segments) > 1 {
+		oldest := q.segments[0]
+		lost := oldest.lines
+		if q.readOffset > 0 {
+			lost = q.unreadLines(oldest)
+		}
+		log.Printf("Warning: WAL exceeds %d bytes - dropping %d queued points from %s", q.maxBytes, lost, oldest.path)
+		q.removeOldest(lost)
+	}
+}
+
+// unreadLines counts the records of a segment after readOffset
+func (q *DiskQueue) unreadLines(segment *walSegment) int {
+	data, err := os.ReadFile(segment.path)
+	if err != nil || q.readOffset > int64(len(data)) {
+		return 0
+	}
+	return bytes.Count(data[q.readOffset:], []byte{'\n'})
+}
+
+// removeOldest deletes the oldest segment, which still held unread records
+// Must be called with mu held
+func (q *DiskQueue) removeOldest(unread int) {
+	oldest := q.segments[0]
+	if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: failed to remove WAL segment %s: %v", oldest.path, err)
+	}
+	q.lines -= unread
+	q.bytes -= oldest.size - q.readOffset
+	q.segments = q.segments[1:]
+	q.readOffset = 0
+}
+
+// WALBatch is a run of the oldest records returned by Peek
+// It identifies the segment and offset it was read from, so Commit can tell
+// whether the records are still at the head of the queue
+type WALBatch struct {
+	Lines    []string
+	segment  uint64 // Sequence number of the segment the records were read from
+	offset   int64  // Read offset of the segment the records start at
+	consumed int64  // Bytes of the segment the records take up
+}
+
+// Peek returns up to max of the oldest records without removing them
+// Pass the returned batch to Commit once the records have been written
+func (q *DiskQueue) Peek(max int) (WALBatch, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.lines == 0 || q.active == nil {
+		return WALBatch{}, nil
+	}
+	oldest := q.segments[0]
+	if len(q.segments) == 1 {
+		// Never read the segment being appended to; seal it first
+		if err := q.rotate(); err != nil {
+			return WALBatch{}, err
+		}
+	}
+
+	f, err := os.Open(oldest.path)
+	if err != nil {
+		return WALBatch{}, fmt.Errorf("failed to open WAL segment: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(q.readOffset, io.SeekStart); err != nil {
+		return WALBatch{}, fmt.Errorf("failed to seek WAL segment: %w", err)
+	}
+
+	reader := bufio.NewReader(f)
+	batch := WALBatch{Lines: make([]string, 0, max), segment: oldest.seq, offset: q.readOffset}
+	for len(batch.Lines) < max {
+		record, err := reader.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return WALBatch{}, fmt.Errorf("failed to read WAL segment: %w", err)
+		}
+		batch.consumed += int64(len(record))
+		_, line, _ := strings.Cut(strings.TrimSuffix(record, "\n"), " ")
+		batch.Lines = append(batch.Lines, line)
+	}
+	return batch, nil
+}
+
+// Commit removes the records of a batch returned by Peek from the queue
+// It does nothing if the records are no longer at the head of the queue, e.g.
+// because their segment was dropped by the size limit in the meantime
+func (q *DiskQueue) Commit(batch WALBatch) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.segments) < 2 || batch.consumed == 0 {
+		return
+	}
+	oldest := q.segments[0]
+	if oldest.seq != batch.segment || q.readOffset != batch.offset {
+		return
+	}
+	if q.readOffset+batch.consumed >= oldest.size {
+		q.removeOldest(len(batch.Lines))
+		return
+	}
+	q.readOffset += batch.consumed
+	q.lines -= len(batch.Lines)
+	q.bytes -= batch.consumed
+	oldest.oldest = q.timeAt(oldest, q.readOffset)
+}
+
+// timeAt returns the enqueue time of the record starting at offset
+func (q *DiskQueue) timeAt(segment *walSegment, offset int64) time.Time {
+	f, err := os.Open(segment.path)
+	if err != nil {
+		return segment.oldest
+	}
+	defer f.Close()
+	buf := make([]byte, 32)
+	n, _ := f.ReadAt(buf, offset)
+	if t := recordTime(buf[:n]); !t.IsZero() {
+		return t
+	}
+	return segment.oldest
+}
+
+// Stats returns the number of queued points, their size on disk and the age of the oldest one
+func (q *DiskQueue) Stats() WALStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := WALStats{Points: q.lines, Bytes: q.bytes}
+	for _, segment := range q.segments {
+		if segment.lines > 0 && !segment.oldest.IsZero() {
+			stats.OldestAge = time.Since(segment.oldest)
+			break
+		}
+	}
+	return stats
+}
+
+// Close closes the active segment; queued records stay on disk for the next run
+func (q *DiskQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.active != nil {
+		q.active.Close()
+		q.active = nil
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// drainQueue peeks and commits batches of max records until the queue is empty
+func drainQueue(t *testing.T, q *DiskQueue, max int) []string {
+	t.Helper()
+	var out []string
+	for {
+		batch, err := q.Peek(max)
+		if err != nil {
+			t.Fatalf("Peek: %v", err)
+		}
+		if len(batch.Lines) == 0 {
+			return out
+		}
+		out = append(out, batch.Lines...)
+		q.Commit(batch)
+	}
+}
+
+func TestDiskQueueAppendPeekCommit(t *testing.T) {
+	q, err := NewDiskQueue(t.TempDir(), 1<<20, 1<<16)
+	if err != nil {
+		t.Fatalf("NewDiskQueue: %v", err)
+	}
+	defer q.Close()
+
+	if err := q.Append("m v=1", "m v=2", "m v=3"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	batch, err := q.Peek(2)
+	if err != nil {
+		t.Fatalf("Peek: %v", err)
+	}
+	if want := []string{"m v=1", "m v=2"}; !reflect.DeepEqual(batch.Lines, want) {
+		t.Fatalf("Peek(2) = %q, want %q", batch.Lines, want)
+	}
+	// Peeking again without a commit returns the same records
+	if again, _ := q.Peek(2); !reflect.DeepEqual(again.Lines, batch.Lines) {
+		t.Errorf("second Peek(2) = %q, want %q", again.Lines, batch.Lines)
+	}
+	q.Commit(batch)
+	if points := q.Stats().Points; points != 1 {
+		t.Errorf("expected 1 queued point after commit, got %d", points)
+	}
+	if rest := drainQueue(t, q, 10); !reflect.DeepEqual(rest, []string{"m v=3"}) {
+		t.Errorf("remaining records = %q", rest)
+	}
+	if stats := q.Stats(); stats.Points != 0 || stats.Bytes != 0 {
+		t.Errorf("expected an empty queue, got %+v", stats)
+	}
+}
+
+func TestDiskQueueRecoversTornTail(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewDiskQueue(dir, 1<<20, 1<<16)
+	if err != nil {
+		t.Fatalf("NewDiskQueue: %v", err)
+	}
+	if err := q.Append("m v=1", "m v=2"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	q.Close()
+
+	// Simulate a crash in the middle of an append
+	segment := filepath.Join(dir, fmt.Sprintf("%020d%s", 1, walSegmentExt))
+	f, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("open segment: %v", err)
+	}
+	f.WriteString("1717200000000000000 m v=")
+	f.Close()
+
+	q, err = NewDiskQueue(dir, 1<<20, 1<<16)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer q.Close()
+	if points := q.Stats().Points; points != 2 {
+		t.Errorf("expected 2 complete points after reopen, got %d", points)
+	}
+	if err := q.Append("m v=3"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if got, want := drainQueue(t, q, 10), []string{"m v=1", "m v=2", "m v=3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %q, want %q", got, want)
+	}
+}
+
+func TestDiskQueueRotatesSegments(t *testing.T) {
+	dir := t.TempDir()
+	// Each record is about 30 bytes, so a 64 byte segment holds two
+	q, err := NewDiskQueue(dir, 1<<20, 64)
+	if err != nil {
+		t.Fatalf("NewDiskQueue: %v", err)
+	}
+	defer q.Close()
+
+	var want []string
+	for n := 0; n < 7; n++ {
+		line := fmt.Sprintf("m v=%d", n)
+		if err := q.Append(line); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		want = append(want, line)
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+walSegmentExt))
+	if len(segments) < 4 {
+		t.Errorf("expected at least 4 segments, got %d", len(segments))
+	}
+	if got := drainQueue(t, q, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %q, want %q", got, want)
+	}
+	// Replayed segments are deleted; only the active one is left
+	if segments, _ := filepath.Glob(filepath.Join(dir, "*"+walSegmentExt)); len(segments) != 1 {
+		t.Errorf("expected only the active segment after replay, got %v", segments)
+	}
+}
+
+func TestDiskQueueDropsOldestOverLimit(t *testing.T) {
+	// One record per segment and room for about three
+	q, err := NewDiskQueue(t.TempDir(), 100, 1)
+	if err != nil {
+		t.Fatalf("NewDiskQueue: %v", err)
+	}
+	defer q.Close()
+
+	for n := 0; n < 6; n++ {
+		if err := q.Append(fmt.Sprintf("m v=%d", n)); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	stats := q.Stats()
+	if stats.Bytes > 100 {
+		t.Errorf("queue holds %d bytes, limit is 100", stats.Bytes)
+	}
+	got := drainQueue(t, q, 10)
+	if len(got) != stats.Points || got[len(got)-1] != "m v=5" || got[0] == "m v=0" {
+		t.Errorf("expected the newest records to survive, got %q (%d points)", got, stats.Points)
+	}
+}
+
+func TestDiskQueueCommitAfterSegmentDropped(t *testing.T) {
+	q, err := NewDiskQueue(t.TempDir(), 100, 1)
+	if err != nil {
+		t.Fatalf("NewDiskQueue: %v", err)
+	}
+	defer q.Close()
+
+	if err := q.Append("m v=0"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	batch, err := q.Peek(10)
+	if err != nil || len(batch.Lines) != 1 {
+		t.Fatalf("Peek = %q, %v", batch.Lines, err)
+	}
+
+	// While the batch is being written, appends push its segment over the limit
+	for n := 1; n < 6; n++ {
+		if err := q.Append(fmt.Sprintf("m v=%d", n)); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	before := q.Stats()
+	q.Commit(batch)
+	if after := q.Stats(); after.Points != before.Points || after.Bytes != before.Bytes {
+		t.Errorf("commit of a dropped segment changed the queue: %+v -> %+v", before, after)
+	}
+	got := drainQueue(t, q, 10)
+	if len(got) != before.Points || got[len(got)-1] != "m v=5" {
+		t.Errorf("expected %d records ending in m v=5, got %q", before.Points, got)
+	}
+}
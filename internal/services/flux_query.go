@@ -0,0 +1,311 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuery is returned when a query parameter cannot be used safely in Flux
+var ErrInvalidQuery = errors.New("invalid query parameter")
+
+// identifierPattern matches the tag values, measurements and field names we accept
+var identifierPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// columnPattern matches Flux column names usable in r.<column> member expressions
+var columnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// aggregateFunctions are the functions allowed in aggregateWindow
+var aggregateFunctions = map[string]bool{
+	"mean": true, "median": true, "min": true, "max": true,
+	"sum": true, "count": true, "first": true, "last": true,
+}
+
+// ValidateIdentifier checks that a value is a plain identifier such as a
+// greenhouse_id, node_id, measurement or field name
+func ValidateIdentifier(kind, value string) error {
+	if !identifierPattern.MatchString(value) {
+		return fmt.Errorf("%w: %s must be 1-64 letters, digits, '_', '.' or '-'", ErrInvalidQuery, kind)
+	}
+	return nil
+}
+
+// FluxString returns value as a quoted Flux string literal with all special
+// characters escaped, including the ${ interpolation sequence
+func FluxString(value string) string {
+	var b strings.Builder
+	b.Grow(len(value) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '$':
+			if i+1 < len(value) && value[i+1] == '{' {
+				b.WriteString(`\$`)
+			} else {
+				b.WriteByte(c)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// fluxTime formats a time as a Flux date-time literal
+func fluxTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// fluxDuration formats a duration as a Flux duration literal in whole seconds
+func fluxDuration(d time.Duration) string {
+	return fmt.Sprintf("%ds", int64(d/time.Second))
+}
+
+// fluxStringList formats values as a Flux array of string literals
+func fluxStringList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = FluxString(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// FluxQuery builds a Flux pipeline from validated identifiers and escaped literals
+// Stage methods record the first validation error, which Build returns
+type FluxQuery struct {
+	imports []string
+	stages  []string
+	err     error
+}
+
+// NewFluxQuery starts a query reading from the given bucket
+func NewFluxQuery(bucket string) *FluxQuery {
+	return &FluxQuery{stages: []string{"from(bucket: " + FluxString(bucket) + ")"}}
+}
+
+// fail records the first error encountered while building the query
+func (q *FluxQuery) fail(err error) *FluxQuery {
+	if q.err == nil {
+		q.err = err
+	}
+	return q
+}
+
+// add appends a stage to the pipeline
+func (q *FluxQuery) add(stage string) *FluxQuery {
+	q.stages = append(q.stages, stage)
+	return q
+}
+
+// checkColumns validates column names used in the pipeline
+func (q *FluxQuery) checkColumns(columns []string) bool {
+	for _, c := range columns {
+		if !columnPattern.MatchString(c) {
+			q.fail(fmt.Errorf("%w: column %q", ErrInvalidQuery, c))
+			return false
+		}
+	}
+	return true
+}
+
+// Range limits the query to [start, stop); a zero stop leaves the range open-ended
+func (q *FluxQuery) Range(start, stop time.Time) *FluxQuery {
+	if start.IsZero() {
+		return q.fail(fmt.Errorf("%w: range start is required", ErrInvalidQuery))
+	}
+	if stop.IsZero() {
+		return q.add("range(start: " + fluxTime(start) + ")")
+	}
+	if !start.Before(stop) {
+		return q.fail(fmt.Errorf("%w: range start must be before stop", ErrInvalidQuery))
+	}
+	return q.add("range(start: " + fluxTime(start) + ", stop: " + fluxTime(stop) + ")")
+}
+
+// FilterMeasurement keeps rows of the given measurement
+func (q *FluxQuery) FilterMeasurement(measurement string) *FluxQuery {
+	if err := ValidateIdentifier("measurement", measurement); err != nil {
+		return q.fail(err)
+	}
+	return q.add("filter(fn: (r) => r._measurement == " + FluxString(measurement) + ")")
+}
+
+// FilterTag keeps rows whose tag equals value; an empty value adds no filter
+func (q *FluxQuery) FilterTag(tag, value string) *FluxQuery {
+	if value == "" {
+		return q
+	}
+	if !q.checkColumns([]string{tag}) {
+		return q
+	}
+	if err := ValidateIdentifier(tag, value); err != nil {
+		return q.fail(err)
+	}
+	return q.add("filter(fn: (r) => r." + tag + " == " + FluxString(value) + ")")
+}
+
+// FilterFields keeps rows of the given fields; no fields adds no filter
+func (q *FluxQuery) FilterFields(fields ...string) *FluxQuery {
+	if len(fields) == 0 {
+		return q
+	}
+	conditions := make([]string, len(fields))
+	for i, f := range fields {
+		if err := ValidateIdentifier("field", f); err != nil {
+			return q.fail(err)
+		}
+		conditions[i] = "r._field == " + FluxString(f)
+	}
+	return q.add("filter(fn: (r) => " + strings.Join(conditions, " or ") + ")")
+}
+
+// AggregateWindow downsamples each series with fn over windows of length every
+func (q *FluxQuery) AggregateWindow(every time.Duration, fn string) *FluxQuery {
+	if every < time.Second {
+		return q.fail(fmt.Errorf("%w: aggregate window must be at least 1s", ErrInvalidQuery))
+	}
+	if !aggregateFunctions[fn] {
+		return q.fail(fmt.Errorf("%w: aggregate function %q", ErrInvalidQuery, fn))
+	}
+	return q.add("aggregateWindow(every: " + fluxDuration(every) + ", fn: " + fn + ", createEmpty: false)")
+}
+
+// DownsampleStats downsamples the statistics of an averages measurement over windows
+// of length every, combining each statistic with a matching function: the minimum of
+// the minimums, the maximum of the maximums, the sum of counts, readings and durations,
+// and the mean of the derived metrics. Sensor means and standard deviations are combined
+// weighted by count: <sensor>_average holds the sum of mean*count and <sensor>_stddev the
+// sum of count*(stddev²+mean²), which combineDownsampledStats turns into the pooled mean
+// and standard deviation. Medians cannot be combined and are dropped. The output has one
+// table per measurement, greenhouse, node and field, ready for PivotFields
+func (q *FluxQuery) DownsampleStats(every time.Duration) *FluxQuery {
+	if every < time.Second {
+		return q.fail(fmt.Errorf("%w: aggregate window must be at least 1s", ErrInvalidQuery))
+	}
+	aggregate := "aggregateWindow(every: " + fluxDuration(every) + ", fn: %s, createEmpty: false)"
+	paired := []string{
+		"paired = data",
+		`filter(fn: (r) => r._field =~ /_(average|count|stddev)$/)`,
+		`map(fn: (r) => ({r with _value: float(v: r._value), sensor: regexp.replaceAllString(r: /_(average|count|stddev)$/, v: r._field, t: ""), stat: regexp.findString(r: /(average|count|stddev)$/, v: r._field)}))`,
+		`group(columns: ["_start", "_stop", "_measurement", "greenhouse_id", "node_id"])`,
+		`pivot(rowKey: ["_time", "sensor"], columnKey: ["stat"], valueColumn: "_value")`,
+		`filter(fn: (r) => exists r.average and exists r.count and exists r.stddev and r.count > 0.0)`,
+	}
+	weighted := func(field, value string) string {
+		return "paired |> map(fn: (r) => ({_start: r._start, _stop: r._stop, _time: r._time, _measurement: r._measurement, greenhouse_id: r.greenhouse_id, node_id: r.node_id, " +
+			`_field: r.sensor + "_` + field + `", _value: ` + value + "}))" +
+			` |> group(columns: ["_start", "_stop", "_measurement", "greenhouse_id", "node_id", "_field"]) |> ` + fmt.Sprintf(aggregate, "sum")
+	}
+	streams := []string{
+		`data |> filter(fn: (r) => r._field =~ /_min$/) |> ` + fmt.Sprintf(aggregate, "min"),
+		`data |> filter(fn: (r) => r._field =~ /_max$/) |> ` + fmt.Sprintf(aggregate, "max"),
+		`data |> filter(fn: (r) => r._field =~ /_count$/ or r._field == "readings" or r._field == "duration") |> ` + fmt.Sprintf(aggregate, "sum"),
+		`data |> filter(fn: (r) => r._field !~ /_(average|min|max|stddev|median|count)$/ and r._field != "readings" and r._field != "duration") |> ` + fmt.Sprintf(aggregate, "mean"),
+		weighted(StatAverage, "r.average * r.count"),
+		weighted(StatStdDev, "r.count * (r.stddev * r.stddev + r.average * r.average)"),
+	}
+
+	q.imports = append(q.imports, "regexp")
+	q.stages = []string{
+		"data = " + strings.Join(q.stages, "\n  |> ") + "\n\n" +
+			strings.Join(paired, "\n  |> ") + "\n\n" +
+			"union(tables: [\n    " + strings.Join(streams, ",\n    ") + "\n])",
+	}
+	return q.add(`group(columns: ["_measurement", "greenhouse_id", "node_id", "_field"])`)
+}
+
+// PivotFields turns each field into a column, one row per timestamp
+func (q *FluxQuery) PivotFields() *FluxQuery {
+	return q.add(`pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")`)
+}
+
+// Group regroups rows by the given columns; no columns merges all tables
+func (q *FluxQuery) Group(columns ...string) *FluxQuery {
+	if !q.checkColumns(columns) {
+		return q
+	}
+	if len(columns) == 0 {
+		return q.add("group()")
+	}
+	return q.add("group(columns: " + fluxStringList(columns) + ")")
+}
+
+// Sort orders rows by the given columns
+func (q *FluxQuery) Sort(desc bool, columns ...string) *FluxQuery {
+	if !q.checkColumns(columns) {
+		return q
+	}
+	return q.add(fmt.Sprintf("sort(columns: %s, desc: %t)", fluxStringList(columns), desc))
+}
+
+// After keeps rows strictly after a (time, greenhouse_id, node_id) cursor
+func (q *FluxQuery) After(c *AveragesCursor) *FluxQuery {
+	if c == nil {
+		return q
+	}
+	if c.GreenhouseID != "" {
+		if err := ValidateIdentifier("cursor greenhouse_id", c.GreenhouseID); err != nil {
+			return q.fail(err)
+		}
+	}
+	if c.NodeID != "" {
+		if err := ValidateIdentifier("cursor node_id", c.NodeID); err != nil {
+			return q.fail(err)
+		}
+	}
+	t := fluxTime(c.Time)
+	gh := FluxString(c.GreenhouseID)
+	node := FluxString(c.NodeID)
+	return q.add("filter(fn: (r) => r._time > " + t + " or (r._time == " + t +
+		" and (r.greenhouse_id > " + gh + " or (r.greenhouse_id == " + gh + " and r.node_id > " + node + "))))")
+}
+
+// Keep keeps only the given columns
+func (q *FluxQuery) Keep(columns ...string) *FluxQuery {
+	if !q.checkColumns(columns) {
+		return q
+	}
+	return q.add("keep(columns: " + fluxStringList(columns) + ")")
+}
+
+// First keeps the first row of each table
+func (q *FluxQuery) First() *FluxQuery {
+	return q.add("first()")
+}
+
+// Limit keeps at most n rows of each table
+func (q *FluxQuery) Limit(n int) *FluxQuery {
+	if n < 1 {
+		return q.fail(fmt.Errorf("%w: limit must be positive", ErrInvalidQuery))
+	}
+	return q.add(fmt.Sprintf("limit(n: %d)", n))
+}
+
+// Build returns the Flux source, or the first validation error
+func (q *FluxQuery) Build() (string, error) {
+	if q.err != nil {
+		return "", q.err
+	}
+	var header strings.Builder
+	for _, pkg := range q.imports {
+		header.WriteString("import " + FluxString(pkg) + "\n")
+	}
+	if header.Len() > 0 {
+		header.WriteString("\n")
+	}
+	return header.String() + strings.Join(q.stages, "\n  |> "), nil
+}
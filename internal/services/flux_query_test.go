@@ -0,0 +1,218 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var hostileValues = []string{
+	`Node01"`,
+	`Node01") |> drop(columns: ["_value"]`,
+	`Node01" or r._measurement != "`,
+	`Node01\`,
+	`Node01\" or true or \"`,
+	`${secrets.get(key: "token")}`,
+	"Node01\n|> yield()",
+	"Node01\r\n",
+	"Node01\t",
+	"",
+	strings.Repeat("a", 65),
+	"Node 01",
+	"Node01;",
+	"Node01|>",
+	"Nöde01",
+}
+
+func TestFilterTagRejectsHostileValues(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	for _, v := range hostileValues {
+		if v == "" {
+			continue // empty values mean "no filter"
+		}
+		q, err := NewFluxQuery("bucket").Range(start, time.Time{}).FilterTag("node_id", v).Build()
+		if !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("FilterTag(%q): expected ErrInvalidQuery, got query %q, err %v", v, q, err)
+		}
+	}
+}
+
+func TestValidateIdentifier(t *testing.T) {
+	for _, v := range hostileValues {
+		if err := ValidateIdentifier("node_id", v); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("ValidateIdentifier(%q) = %v, want ErrInvalidQuery", v, err)
+		}
+	}
+	for _, v := range []string{"GH1", "Node01", "greenhouse-2", "node_05.a", strings.Repeat("a", 64)} {
+		if err := ValidateIdentifier("node_id", v); err != nil {
+			t.Errorf("ValidateIdentifier(%q) = %v, want nil", v, err)
+		}
+	}
+}
+
+func TestFluxString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`plain`, `"plain"`},
+		{`a"b`, `"a\"b"`},
+		{`a\b`, `"a\\b"`},
+		{`a\"b`, `"a\\\"b"`},
+		{"a\nb", `"a\nb"`},
+		{"a\rb\tc", `"a\rb\tc"`},
+		{`${x}`, `"\${x}"`},
+		{`$x`, `"$x"`},
+		{`a$`, `"a$"`},
+		{`") |> drop()`, `"\") |> drop()"`},
+	}
+	for _, tt := range tests {
+		if got := FluxString(tt.in); got != tt.want {
+			t.Errorf("FluxString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFluxStringNeverTerminatesEarly(t *testing.T) {
+	for _, v := range hostileValues {
+		lit := FluxString(v)
+		body := lit[1 : len(lit)-1]
+		for i := 0; i < len(body); i++ {
+			switch body[i] {
+			case '\\':
+				i++ // skip the escaped character
+			case '"', '\n', '\r':
+				t.Errorf("FluxString(%q) = %s leaves an unescaped %q", v, lit, body[i])
+			case '$':
+				if i+1 < len(body) && body[i+1] == '{' {
+					t.Errorf("FluxString(%q) = %s leaves an unescaped ${", v, lit)
+				}
+			}
+		}
+	}
+}
+
+func TestBucketNameIsEscaped(t *testing.T) {
+	q, err := NewFluxQuery(`bucket") |> drop() //`).Range(time.Now().Add(-time.Hour), time.Time{}).Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if !strings.HasPrefix(q, `from(bucket: "bucket\") |> drop() //")`) {
+		t.Errorf("bucket not escaped: %s", q)
+	}
+}
+
+func TestColumnsAndFunctionsAreValidated(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	tests := map[string]*FluxQuery{
+		"tag column":         NewFluxQuery("b").Range(start, time.Time{}).FilterTag(`node_id == "x" or r.a`, "Node01"),
+		"group column":       NewFluxQuery("b").Range(start, time.Time{}).Group(`_field"]) |> drop(columns: ["`),
+		"sort column":        NewFluxQuery("b").Range(start, time.Time{}).Sort(false, "_time", "node id"),
+		"keep column":        NewFluxQuery("b").Range(start, time.Time{}).Keep(""),
+		"field":              NewFluxQuery("b").Range(start, time.Time{}).FilterFields("Air_Temp_average", `x" or true or "`),
+		"measurement":        NewFluxQuery("b").Range(start, time.Time{}).FilterMeasurement(`sensor_averages" or true or "`),
+		"aggregate function": NewFluxQuery("b").Range(start, time.Time{}).AggregateWindow(time.Minute, "mean, offset: 1s"),
+		"aggregate window":   NewFluxQuery("b").Range(start, time.Time{}).AggregateWindow(time.Millisecond, "mean"),
+		"downsample window":  NewFluxQuery("b").Range(start, time.Time{}).DownsampleStats(time.Millisecond),
+		"limit":              NewFluxQuery("b").Range(start, time.Time{}).Limit(0),
+		"missing start":      NewFluxQuery("b").Range(time.Time{}, time.Time{}),
+		"inverted range":     NewFluxQuery("b").Range(start, start.Add(-time.Minute)),
+		"cursor greenhouse":  NewFluxQuery("b").Range(start, time.Time{}).After(&AveragesCursor{Time: start, GreenhouseID: `GH1" or true or "`, NodeID: "Node01"}),
+		"cursor node":        NewFluxQuery("b").Range(start, time.Time{}).After(&AveragesCursor{Time: start, GreenhouseID: "GH1", NodeID: "${x}"}),
+	}
+	for name, q := range tests {
+		if src, err := q.Build(); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%s: expected ErrInvalidQuery, got query %q, err %v", name, src, err)
+		}
+	}
+}
+
+func TestFirstErrorIsKept(t *testing.T) {
+	_, err := NewFluxQuery("b").
+		Range(time.Now().Add(-time.Hour), time.Time{}).
+		FilterTag("greenhouse_id", `GH1"`).
+		Limit(0).
+		Build()
+	if err == nil || !strings.Contains(err.Error(), "greenhouse_id") {
+		t.Errorf("expected greenhouse_id error, got %v", err)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stop := start.Add(time.Hour)
+	q, err := NewFluxQuery("sensors").
+		Range(start, stop).
+		FilterMeasurement("sensor_averages").
+		FilterTag("greenhouse_id", "GH1").
+		FilterTag("node_id", "").
+		AggregateWindow(5*time.Minute, "mean").
+		PivotFields().
+		Group().
+		After(&AveragesCursor{Time: start, GreenhouseID: "GH1", NodeID: "Node01"}).
+		Sort(false, "_time", "greenhouse_id", "node_id").
+		Limit(11).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	want := `from(bucket: "sensors")
+  |> range(start: 2024-01-01T00:00:00Z, stop: 2024-01-01T01:00:00Z)
+  |> filter(fn: (r) => r._measurement == "sensor_averages")
+  |> filter(fn: (r) => r.greenhouse_id == "GH1")
+  |> aggregateWindow(every: 300s, fn: mean, createEmpty: false)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()
+  |> filter(fn: (r) => r._time > 2024-01-01T00:00:00Z or (r._time == 2024-01-01T00:00:00Z and (r.greenhouse_id > "GH1" or (r.greenhouse_id == "GH1" and r.node_id > "Node01"))))
+  |> sort(columns: ["_time", "greenhouse_id", "node_id"], desc: false)
+  |> limit(n: 11)`
+	if q != want {
+		t.Errorf("Build() =\n%s\nwant\n%s", q, want)
+	}
+}
+
+func TestBuildDownsampleStats(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q, err := NewFluxQuery("sensors").
+		Range(start, start.Add(24*time.Hour)).
+		FilterMeasurement("sensor_averages").
+		FilterTag("greenhouse_id", "GH1").
+		DownsampleStats(time.Hour).
+		PivotFields().
+		Group().
+		Limit(11).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	want := `import "regexp"
+
+data = from(bucket: "sensors")
+  |> range(start: 2024-01-01T00:00:00Z, stop: 2024-01-02T00:00:00Z)
+  |> filter(fn: (r) => r._measurement == "sensor_averages")
+  |> filter(fn: (r) => r.greenhouse_id == "GH1")
+
+paired = data
+  |> filter(fn: (r) => r._field =~ /_(average|count|stddev)$/)
+  |> map(fn: (r) => ({r with _value: float(v: r._value), sensor: regexp.replaceAllString(r: /_(average|count|stddev)$/, v: r._field, t: ""), stat: regexp.findString(r: /(average|count|stddev)$/, v: r._field)}))
+  |> group(columns: ["_start", "_stop", "_measurement", "greenhouse_id", "node_id"])
+  |> pivot(rowKey: ["_time", "sensor"], columnKey: ["stat"], valueColumn: "_value")
+  |> filter(fn: (r) => exists r.average and exists r.count and exists r.stddev and r.count > 0.0)
+
+union(tables: [
+    data |> filter(fn: (r) => r._field =~ /_min$/) |> aggregateWindow(every: 3600s, fn: min, createEmpty: false),
+    data |> filter(fn: (r) => r._field =~ /_max$/) |> aggregateWindow(every: 3600s, fn: max, createEmpty: false),
+    data |> filter(fn: (r) => r._field =~ /_count$/ or r._field == "readings" or r._field == "duration") |> aggregateWindow(every: 3600s, fn: sum, createEmpty: false),
+    data |> filter(fn: (r) => r._field !~ /_(average|min|max|stddev|median|count)$/ and r._field != "readings" and r._field != "duration") |> aggregateWindow(every: 3600s, fn: mean, createEmpty: false),
+    paired |> map(fn: (r) => ({_start: r._start, _stop: r._stop, _time: r._time, _measurement: r._measurement, greenhouse_id: r.greenhouse_id, node_id: r.node_id, _field: r.sensor + "_average", _value: r.average * r.count})) |> group(columns: ["_start", "_stop", "_measurement", "greenhouse_id", "node_id", "_field"]) |> aggregateWindow(every: 3600s, fn: sum, createEmpty: false),
+    paired |> map(fn: (r) => ({_start: r._start, _stop: r._stop, _time: r._time, _measurement: r._measurement, greenhouse_id: r.greenhouse_id, node_id: r.node_id, _field: r.sensor + "_stddev", _value: r.count * (r.stddev * r.stddev + r.average * r.average)})) |> group(columns: ["_start", "_stop", "_measurement", "greenhouse_id", "node_id", "_field"]) |> aggregateWindow(every: 3600s, fn: sum, createEmpty: false)
+])
+  |> group(columns: ["_measurement", "greenhouse_id", "node_id", "_field"])
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()
+  |> limit(n: 11)`
+	if q != want {
+		t.Errorf("Build() =\n%s\nwant\n%s", q, want)
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// ErrIdentityMismatch is returned when a payload's IDs differ from its topic's
+// and the identity policy is reject
+var ErrIdentityMismatch = errors.New("topic and payload identity mismatch")
+
+// quarantineMaxBytes is the size at which the quarantine log is rotated to <file>.1
+const quarantineMaxBytes = 10 << 20
+
+// Actions recorded for identity mismatches
+const (
+	identityActionTopic    = "attributed_to_topic"
+	identityActionPayload  = "attributed_to_payload"
+	identityActionRejected = "rejected"
+)
+
+// QuarantineEntry is one line of the quarantine log
+type QuarantineEntry struct {
+	Time                time.Time `json:"time"`
+	Topic               string    `json:"topic"`
+	Policy              string    `json:"policy"`
+	Action              string    `json:"action"`
+	TopicGreenhouseID   string    `json:"topic_greenhouse_id"`
+	TopicNodeID         string    `json:"topic_node_id"`
+	PayloadGreenhouseID string    `json:"payload_greenhouse_id"`
+	PayloadNodeID       string    `json:"payload_node_id"`
+	Payload             string    `json:"payload"`
+}
+
+// QuarantineLog appends identity mismatches to a JSON lines file
+type QuarantineLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	size int64
+}
+
+// NewQuarantineLog opens (or creates) the quarantine log; an empty path disables it
+func NewQuarantineLog(path string) *QuarantineLog {
+	q := &QuarantineLog{path: path}
+	if path == "" {
+		return q
+	}
+	if err := q.open(); err != nil {
+		log.Printf("Warning: Quarantine log disabled: %v", err)
+		q.path = ""
+	}
+	return q
+}
+
+// open opens the log file for appending. Must be called with mu held (or before use)
+func (q *QuarantineLog) open() error {
+	if err := os.MkdirAll(filepath.Dir(q.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(q.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	q.file, q.size = file, info.Size()
+	return nil
+}
+
+// Record appends an entry, rotating the file once it exceeds quarantineMaxBytes
+func (q *QuarantineLog) Record(entry QuarantineEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	if q.size+int64(len(line)) > quarantineMaxBytes {
+		q.file.Close()
+		q.file = nil
+		if err := os.Rename(q.path, q.path+".1"); err != nil {
+			log.Printf("Warning: Failed to rotate quarantine log: %v", err)
+		}
+		if err := q.open(); err != nil {
+			log.Printf("Warning: Quarantine log disabled: %v", err)
+			return
+		}
+	}
+	n, err := q.file.Write(line)
+	q.size += int64(n)
+	if err != nil {
+		log.Printf("Warning: Failed to write quarantine log: %v", err)
+	}
+}
+
+// Close closes the log file
+func (q *QuarantineLog) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file != nil {
+		q.file.Close()
+		q.file = nil
+	}
+}
+
+// identityResolver returns the resolver applying the identity policy to a data
+// message received on topic. Topics that do not carry IDs leave the payload's IDs
+// unchanged; missing payload IDs are taken from the topic. Payload IDs that are not
+// valid identifiers are rejected as invalid_value under every policy
+func (s *SensorService) identityResolver(topic string, payload []byte) IdentityResolver {
+	topicGH, topicNode, ok := parseNodeTopic(topic)
+	return func(payloadGH, payloadNode string) (string, string, error) {
+		if err := validatePayloadIdentity(payloadGH, payloadNode); err != nil {
+			return "", "", err
+		}
+		if !ok {
+			if payloadGH == "" || payloadNode == "" {
+				return "", "", reject(models.RejectMissingIdentity, "", "payload has no greenhouse_id/node_id and topic %s carries none", topic)
+			}
+			return payloadGH, payloadNode, nil
+		}
+		if payloadGH == "" {
+			payloadGH = topicGH
+		}
+		if payloadNode == "" {
+			payloadNode = topicNode
+		}
+		if payloadGH == topicGH && payloadNode == topicNode {
+			return topicGH, topicNode, nil
+		}
+
+		policy := s.config.MQTT.IdentityPolicy
+		entry := QuarantineEntry{
+			Time:                time.Now().UTC(),
+			Topic:               topic,
+			Policy:              policy,
+			TopicGreenhouseID:   topicGH,
+			TopicNodeID:         topicNode,
+			PayloadGreenhouseID: payloadGH,
+			PayloadNodeID:       payloadNode,
+			Payload:             string(payload),
+		}
+		var err error
+		switch policy {
+		case config.IdentityTrustTopic:
+			entry.Action = identityActionTopic
+		case config.IdentityTrustPayload:
+			entry.Action = identityActionPayload
+		default:
+			entry.Action = identityActionRejected
+			err = fmt.Errorf("%w: topic %s/%s, payload %s/%s", ErrIdentityMismatch, topicGH, topicNode, payloadGH, payloadNode)
+		}
+		s.quarantine.Record(entry)
+		s.metricsService.IncrementIdentityMismatches(topicGH, topicNode, entry.Action)
+
+		if entry.Action == identityActionPayload {
+			return payloadGH, payloadNode, err
+		}
+		return topicGH, topicNode, err
+	}
+}
+
+// validatePayloadIdentity checks the greenhouse_id and node_id found in a payload (empty if absent)
+func validatePayloadIdentity(greenhouseID, nodeID string) error {
+	for _, id := range [][2]string{{"greenhouse_id", greenhouseID}, {"node_id", nodeID}} {
+		if id[1] != "" && ValidateIdentifier(id[0], id[1]) != nil {
+			return reject(models.RejectInvalidValue, "", "field %s: %q must be 1-64 letters, digits, '_', '.' or '-'", id[0], id[1])
+		}
+	}
+	return nil
+}
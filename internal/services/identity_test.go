@@ -0,0 +1,75 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+var (
+	testMetricsOnce sync.Once
+	testMetrics     *MetricsService
+)
+
+// sharedTestMetrics returns one metrics service for all tests, since the collectors
+// are registered with the global Prometheus registry
+func sharedTestMetrics() *MetricsService {
+	testMetricsOnce.Do(func() { testMetrics = NewMetricsService() })
+	return testMetrics
+}
+
+// identityTestService returns a sensor service with the identity policy and no quarantine log
+func identityTestService(policy string) *SensorService {
+	return &SensorService{
+		config:         &config.Config{MQTT: config.MQTTConfig{IdentityPolicy: policy}},
+		quarantine:     NewQuarantineLog(""),
+		metricsService: sharedTestMetrics(),
+	}
+}
+
+func TestIdentityResolverRejectsInvalidPayloadIDs(t *testing.T) {
+	registry := NewSensorRegistry(&config.SensorsConfig{Sensors: []config.SensorDefinition{
+		{Name: "Air_Temp", JSONKey: "Air_Temp", Unit: "°C", Type: config.SensorTypeFloat},
+	}})
+	topics := []string{"greenhouse/GH1/node/Node01/data", "esp32/data"}
+
+	for _, policy := range []string{config.IdentityTrustTopic, config.IdentityTrustPayload, config.IdentityReject} {
+		s := identityTestService(policy)
+		for _, topic := range topics {
+			for _, v := range hostileValues {
+				if v == "" {
+					continue // empty IDs are filled in from the topic
+				}
+				for _, field := range []string{"greenhouse_id", "node_id"} {
+					msg := map[string]interface{}{"greenhouse_id": "GH1", "node_id": "Node01", "Air_Temp": 21.5}
+					msg[field] = v
+					payload, _ := json.Marshal(msg)
+
+					_, err := registry.ParseSensorData(payload, s.identityResolver(topic, payload))
+					var rejection *RejectionError
+					if !errors.As(err, &rejection) || rejection.Rejection.Reason != models.RejectInvalidValue {
+						t.Errorf("policy %s, topic %s, %s %q: expected %s rejection, got %v",
+							policy, topic, field, v, models.RejectInvalidValue, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestIdentityResolverAcceptsValidPayloadIDs(t *testing.T) {
+	s := identityTestService(config.IdentityReject)
+
+	gh, node, err := s.identityResolver("esp32/data", nil)("GH-1", "Node_01.a")
+	if err != nil || gh != "GH-1" || node != "Node_01.a" {
+		t.Errorf("got %s/%s, %v", gh, node, err)
+	}
+	gh, node, err = s.identityResolver("greenhouse/GH1/node/Node01/data", nil)("", "")
+	if err != nil || gh != "GH1" || node != "Node01" {
+		t.Errorf("missing payload IDs: got %s/%s, %v", gh, node, err)
+	}
+}
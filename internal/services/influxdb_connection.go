@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+)
+
+// InfluxDB connection states reported by ConnectionStatus
+const (
+	ConnectionDisabled   = "disabled"   // No token configured
+	ConnectionConnecting = "connecting" // Not reachable yet (or lost); retrying in the background
+	ConnectionConnected  = "connected"
+)
+
+// Connection manager timing
+const (
+	reconnectMinBackoff = time.Second
+	reconnectMaxBackoff = time.Minute
+	healthCheckInterval = 30 * time.Second
+	pingTimeout         = 5 * time.Second
+)
+
+// conn returns the live client and blocking write API, or nils while not connected
+func (i *InfluxDBService) conn() (influxdb2.Client, api.WriteAPIBlocking) {
+	i.connMu.RLock()
+	defer i.connMu.RUnlock()
+	return i.client, i.writeAPI
+}
+
+// ConnectionStatus returns "disabled", "connecting" or "connected"
+func (i *InfluxDBService) ConnectionStatus() string {
+	i.connMu.RLock()
+	defer i.connMu.RUnlock()
+	return i.status
+}
+
+// startConnectionManager starts the goroutine that connects to InfluxDB and
+// keeps checking the connection
+func (i *InfluxDBService) startConnectionManager() {
+	i.status = ConnectionConnecting
+	i.metrics.SetInfluxDBConnectionStatus(false)
+	i.connStop = make(chan struct{})
+	i.connDone = make(chan struct{})
+	go i.manageConnection()
+}
+
+// stopConnectionManager stops the connection manager goroutine
+func (i *InfluxDBService) stopConnectionManager() {
+	if i.connStop == nil {
+		return
+	}
+	close(i.connStop)
+	<-i.connDone
+}
+
+// manageConnection pings InfluxDB, retrying with exponential backoff until it
+// answers, then keeps checking it every healthCheckInterval. While unreachable
+// the client is withheld, so writes go to the disk queue instead of timing out
+func (i *InfluxDBService) manageConnection() {
+	defer close(i.connDone)
+
+	backoff := reconnectMinBackoff
+	for {
+		wait := healthCheckInterval
+		if err := i.ping(); err == nil {
+			if i.ConnectionStatus() != ConnectionConnected {
+				i.setConnected()
+			}
+			backoff = reconnectMinBackoff
+		} else {
+			if i.ConnectionStatus() == ConnectionConnected {
+				log.Printf("Warning: Lost connection to InfluxDB: %v", err)
+				i.setConnecting()
+			} else {
+				log.Printf("Warning: Could not connect to InfluxDB: %v (retrying in %v)", err, backoff)
+			}
+			wait = backoff
+			backoff *= 2
+			if backoff > reconnectMaxBackoff {
+				backoff = reconnectMaxBackoff
+			}
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-i.connStop:
+			return
+		}
+	}
+}
+
+// ping checks that InfluxDB is reachable and ready
+func (i *InfluxDBService) ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	_, err := i.candidate.Ping(ctx)
+	return err
+}
+
+// setConnected makes the client available for writes and queries
+func (i *InfluxDBService) setConnected() {
+	if len(i.config.Raw.Nodes) > 0 {
+		ensureBucket(i.candidate, i.org, i.config.Raw.Bucket, i.config.Raw.Retention)
+	}
+
+	i.connMu.Lock()
+	i.client = i.candidate
+	i.writeAPI = i.candidate.WriteAPIBlocking(i.org, i.bucket)
+	i.status = ConnectionConnected
+	i.connMu.Unlock()
+	i.metrics.SetInfluxDBConnectionStatus(true)
+
+	log.Printf("Successfully connected to InfluxDB at %s", i.config.URL)
+	log.Printf("Using organization: %s, bucket: %s", i.org, i.bucket)
+	log.Printf("Blocking writes enabled for reliability")
+	if len(i.config.Raw.Nodes) > 0 {
+		log.Printf("Raw reading persistence enabled for %v in bucket %s", i.config.Raw.Nodes, i.config.Raw.Bucket)
+	}
+}
+
+// setConnecting withholds the client until InfluxDB answers again
+func (i *InfluxDBService) setConnecting() {
+	i.connMu.Lock()
+	i.client = nil
+	i.writeAPI = nil
+	i.status = ConnectionConnecting
+	i.connMu.Unlock()
+	i.metrics.SetInfluxDBConnectionStatus(false)
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"iot-agriculture-backend/internal/models"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+)
+
+// IrrigationMeasurement holds one point per detected irrigation, stamped with its start
+const IrrigationMeasurement = "irrigation_event"
+
+// LogIrrigationEvent writes a detected irrigation to the irrigation_event measurement
+func (i *InfluxDBService) LogIrrigationEvent(event models.IrrigationEvent) error {
+	if i.ConnectionStatus() == ConnectionDisabled {
+		return nil
+	}
+	point := influxdb2.NewPoint(
+		IrrigationMeasurement,
+		map[string]string{
+			"greenhouse_id": event.GreenhouseID,
+			"node_id":       event.NodeID,
+		},
+		map[string]interface{}{
+			"stop":           event.Stop.UnixNano(),
+			"end":            event.End.UnixNano(),
+			"duration":       event.Duration,
+			"start_weight":   event.StartWeight,
+			"peak_weight":    event.PeakWeight,
+			"settled_weight": event.SettledWeight,
+			"supply":         event.Supply,
+			"drain":          event.Drain,
+			"drain_ratio":    event.DrainRatio,
+		},
+		event.Start,
+	)
+	if err := i.writePoint(point); err != nil {
+		return err
+	}
+	log.Printf("Logged irrigation to InfluxDB: %s", describeIrrigation(event))
+	return nil
+}
+
+// IrrigationQuery describes a set of irrigation events, newest first
+type IrrigationQuery struct {
+	GreenhouseID string
+	NodeID       string
+	Start        time.Time
+	End          time.Time
+	Limit        int
+}
+
+// GetIrrigationEventsFromDB fetches irrigation events, newest first
+func (i *InfluxDBService) GetIrrigationEventsFromDB(query IrrigationQuery) ([]models.IrrigationEvent, error) {
+	client, _ := i.conn()
+	if client == nil {
+		return nil, fmt.Errorf("InfluxDB not connected")
+	}
+	q, err := NewFluxQuery(i.bucket).
+		Range(query.Start, query.End).
+		FilterMeasurement(IrrigationMeasurement).
+		FilterTag("greenhouse_id", query.GreenhouseID).
+		FilterTag("node_id", query.NodeID).
+		PivotFields().
+		Group().
+		Sort(true, "_time", "greenhouse_id", "node_id").
+		Limit(query.Limit).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	queryAPI := client.QueryAPI(i.org)
+	result, err := queryAPI.Query(context.Background(), q)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]models.IrrigationEvent, 0, query.Limit)
+	for result.Next() {
+		record := result.Record()
+		fields := make(map[string]float64)
+		stop, _ := record.ValueByKey("stop").(int64)
+		end, _ := record.ValueByKey("end").(int64)
+		for _, name := range []string{"duration", "start_weight", "peak_weight", "settled_weight", "supply", "drain", "drain_ratio"} {
+			fields[name], _ = toFloat(record.ValueByKey(name))
+		}
+		events = append(events, models.IrrigationEvent{
+			GreenhouseID:  tagValue(record.ValueByKey("greenhouse_id")),
+			NodeID:        tagValue(record.ValueByKey("node_id")),
+			Start:         record.Time(),
+			Stop:          time.Unix(0, stop).UTC(),
+			End:           time.Unix(0, end).UTC(),
+			Duration:      fields["duration"],
+			StartWeight:   fields["start_weight"],
+			PeakWeight:    fields["peak_weight"],
+			SettledWeight: fields["settled_weight"],
+			Supply:        fields["supply"],
+			Drain:         fields["drain"],
+			DrainRatio:    fields["drain_ratio"],
+		})
+	}
+	if result.Err() != nil {
+		return nil, result.Err()
+	}
+	return events, nil
+}
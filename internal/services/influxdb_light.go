@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"iot-agriculture-backend/internal/models"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+)
+
+// DLIMeasurement holds one point per greenhouse/node and completed local day
+const DLIMeasurement = "sensor_dli"
+
+// LogDailyLightIntegral writes a completed day to the sensor_dli measurement,
+// stamped with the start of the local day
+func (i *InfluxDBService) LogDailyLightIntegral(day models.DailyLightIntegral, location *time.Location) error {
+	if i.ConnectionStatus() == ConnectionDisabled {
+		return nil
+	}
+	start, err := time.ParseInLocation(dayLayout, day.Date, location)
+	if err != nil {
+		return fmt.Errorf("invalid DLI date %q: %w", day.Date, err)
+	}
+	point := influxdb2.NewPoint(
+		DLIMeasurement,
+		map[string]string{
+			"greenhouse_id": day.GreenhouseID,
+			"node_id":       day.NodeID,
+		},
+		map[string]interface{}{
+			"date":         day.Date,
+			"dli":          day.DLI,
+			"readings":     day.Readings,
+			"target":       day.Target,
+			"below_target": day.BelowTarget,
+		},
+		start,
+	)
+	if err := i.writePoint(point); err != nil {
+		return err
+	}
+	log.Printf("Logged DLI to InfluxDB: %s", describeDLI(day))
+	return nil
+}
+
+// DLIQuery describes a set of completed days, newest first
+type DLIQuery struct {
+	GreenhouseID string
+	NodeID       string
+	Start        time.Time
+	End          time.Time
+	Limit        int
+}
+
+// GetDailyLightIntegralsFromDB fetches completed days from the sensor_dli measurement, newest first
+func (i *InfluxDBService) GetDailyLightIntegralsFromDB(query DLIQuery) ([]models.DailyLightIntegral, error) {
+	client, _ := i.conn()
+	if client == nil {
+		return nil, fmt.Errorf("InfluxDB not connected")
+	}
+	q, err := NewFluxQuery(i.bucket).
+		Range(query.Start, query.End).
+		FilterMeasurement(DLIMeasurement).
+		FilterTag("greenhouse_id", query.GreenhouseID).
+		FilterTag("node_id", query.NodeID).
+		PivotFields().
+		Group().
+		Sort(true, "_time", "greenhouse_id", "node_id").
+		Limit(query.Limit).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	queryAPI := client.QueryAPI(i.org)
+	result, err := queryAPI.Query(context.Background(), q)
+	if err != nil {
+		return nil, err
+	}
+	days := make([]models.DailyLightIntegral, 0, query.Limit)
+	for result.Next() {
+		record := result.Record()
+		dli, _ := toFloat(record.ValueByKey("dli"))
+		readings, _ := toFloat(record.ValueByKey("readings"))
+		target, _ := toFloat(record.ValueByKey("target"))
+		below, _ := record.ValueByKey("below_target").(bool)
+		days = append(days, models.DailyLightIntegral{
+			GreenhouseID: tagValue(record.ValueByKey("greenhouse_id")),
+			NodeID:       tagValue(record.ValueByKey("node_id")),
+			Date:         tagValue(record.ValueByKey("date")),
+			DLI:          dli,
+			Readings:     int(readings),
+			Complete:     true,
+			Target:       target,
+			BelowTarget:  below,
+		})
+	}
+	if result.Err() != nil {
+		return nil, result.Err()
+	}
+	return days, nil
+}
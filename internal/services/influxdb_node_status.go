@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"iot-agriculture-backend/internal/models"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+)
+
+// Measurements written from non-data topics
+const (
+	NodeStatusMeasurement    = "node_status"    // online/offline transitions reported on status topics
+	NodeTelemetryMeasurement = "node_telemetry" // Node diagnostics from telemetry topics
+)
+
+// LogNodeStatus writes a status transition to the node_status measurement
+func (i *InfluxDBService) LogNodeStatus(event models.NodeStatusEvent) error {
+	if i.ConnectionStatus() == ConnectionDisabled {
+		return nil
+	}
+
+	tags := map[string]string{}
+	if event.Device != "" {
+		tags["device"] = event.Device
+	} else {
+		tags["greenhouse_id"] = event.GreenhouseID
+		tags["node_id"] = event.NodeID
+	}
+	online := 0
+	if event.Status == models.ConnectionOnline {
+		online = 1
+	}
+	point := influxdb2.NewPoint(
+		NodeStatusMeasurement,
+		tags,
+		map[string]interface{}{
+			"status": event.Status,
+			"online": online,
+		},
+		event.Timestamp,
+	)
+	if err := i.writePoint(point); err != nil {
+		return err
+	}
+	log.Printf("Logged node status to InfluxDB: %s", describeStatusEvent(event))
+	return nil
+}
+
+// LogNodeTelemetry writes a node's diagnostic values to the node_telemetry measurement
+func (i *InfluxDBService) LogNodeTelemetry(greenhouseID, nodeID string, values map[string]float64, at time.Time) error {
+	if i.ConnectionStatus() == ConnectionDisabled {
+		return nil
+	}
+	fields := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		fields[key] = value
+	}
+	point := influxdb2.NewPoint(
+		NodeTelemetryMeasurement,
+		map[string]string{
+			"greenhouse_id": greenhouseID,
+			"node_id":       nodeID,
+		},
+		fields,
+		at,
+	)
+	return i.writePoint(point)
+}
+
+// describeStatusEvent formats a status event, e.g. "GH1/Node01 offline"
+func describeStatusEvent(event models.NodeStatusEvent) string {
+	if event.Device != "" {
+		return fmt.Sprintf("device %s %s", event.Device, event.Status)
+	}
+	return fmt.Sprintf("%s/%s %s", event.GreenhouseID, event.NodeID, event.Status)
+}
+
+// NodeStatusQuery describes a set of status events, newest first
+type NodeStatusQuery struct {
+	GreenhouseID string
+	NodeID       string
+	Device       string
+	Start        time.Time
+	End          time.Time
+	Limit        int
+}
+
+// GetNodeStatusEventsFromDB fetches status transitions, newest first
+func (i *InfluxDBService) GetNodeStatusEventsFromDB(query NodeStatusQuery) ([]models.NodeStatusEvent, error) {
+	client, _ := i.conn()
+	if client == nil {
+		return nil, fmt.Errorf("InfluxDB not connected")
+	}
+	q, err := NewFluxQuery(i.bucket).
+		Range(query.Start, query.End).
+		FilterMeasurement(NodeStatusMeasurement).
+		FilterTag("greenhouse_id", query.GreenhouseID).
+		FilterTag("node_id", query.NodeID).
+		FilterTag("device", query.Device).
+		FilterFields("status").
+		Group().
+		Sort(true, "_time").
+		Limit(query.Limit).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	queryAPI := client.QueryAPI(i.org)
+	result, err := queryAPI.Query(context.Background(), q)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]models.NodeStatusEvent, 0, query.Limit)
+	for result.Next() {
+		record := result.Record()
+		events = append(events, models.NodeStatusEvent{
+			GreenhouseID: tagValue(record.ValueByKey("greenhouse_id")),
+			NodeID:       tagValue(record.ValueByKey("node_id")),
+			Device:       tagValue(record.ValueByKey("device")),
+			Status:       fmt.Sprint(record.Value()),
+			Timestamp:    record.Time(),
+		})
+	}
+	if result.Err() != nil {
+		return nil, result.Err()
+	}
+	return events, nil
+}
+
+// tagValue returns a tag column value, or "" for rows without the tag
+func tagValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"iot-agriculture-backend/internal/models"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+)
+
+// RainMeasurement holds one point per completed rain event, stamped with its start
+const RainMeasurement = "rain_event"
+
+// LogRainEvent writes a completed rain event to the rain_event measurement
+func (i *InfluxDBService) LogRainEvent(event models.RainEvent) error {
+	if i.ConnectionStatus() == ConnectionDisabled || event.End == nil {
+		return nil
+	}
+	point := influxdb2.NewPoint(
+		RainMeasurement,
+		map[string]string{
+			"greenhouse_id": event.GreenhouseID,
+			"node_id":       event.NodeID,
+		},
+		map[string]interface{}{
+			"end":      event.End.UnixNano(),
+			"duration": event.Duration,
+		},
+		event.Start,
+	)
+	if err := i.writePoint(point); err != nil {
+		return err
+	}
+	log.Printf("Logged rain event to InfluxDB: %s", describeRain(event))
+	return nil
+}
+
+// RainQuery describes a set of rain events, newest first
+type RainQuery struct {
+	GreenhouseID string
+	NodeID       string
+	Start        time.Time
+	End          time.Time
+	Limit        int
+}
+
+// GetRainEventsFromDB fetches completed rain events, newest first
+func (i *InfluxDBService) GetRainEventsFromDB(query RainQuery) ([]models.RainEvent, error) {
+	client, _ := i.conn()
+	if client == nil {
+		return nil, fmt.Errorf("InfluxDB not connected")
+	}
+	q, err := NewFluxQuery(i.bucket).
+		Range(query.Start, query.End).
+		FilterMeasurement(RainMeasurement).
+		FilterTag("greenhouse_id", query.GreenhouseID).
+		FilterTag("node_id", query.NodeID).
+		PivotFields().
+		Group().
+		Sort(true, "_time", "greenhouse_id", "node_id").
+		Limit(query.Limit).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	queryAPI := client.QueryAPI(i.org)
+	result, err := queryAPI.Query(context.Background(), q)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]models.RainEvent, 0, query.Limit)
+	for result.Next() {
+		record := result.Record()
+		endNanos, _ := record.ValueByKey("end").(int64)
+		duration, _ := toFloat(record.ValueByKey("duration"))
+		end := time.Unix(0, endNanos).UTC()
+		events = append(events, models.RainEvent{
+			GreenhouseID: tagValue(record.ValueByKey("greenhouse_id")),
+			NodeID:       tagValue(record.ValueByKey("node_id")),
+			Start:        record.Time(),
+			End:          &end,
+			Duration:     duration,
+		})
+	}
+	if result.Err() != nil {
+		return nil, result.Err()
+	}
+	return events, nil
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"iot-agriculture-backend/internal/models"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/domain"
+)
+
+// RawMeasurement is the measurement holding individual sensor readings
+const RawMeasurement = "sensor_raw"
+
+// RawPersistenceEnabled returns true if raw readings are persisted for any node
+func (i *InfluxDBService) RawPersistenceEnabled() bool {
+	return len(i.config.Raw.Nodes) > 0
+}
+
+// RawEnabled returns true if raw readings of the given node are persisted
+func (i *InfluxDBService) RawEnabled(greenhouseID, nodeID string) bool {
+	return i.config.Raw.Enabled(greenhouseID, nodeID)
+}
+
+// LogRawReading queues a single reading for the sensor_raw measurement
+// Writes are batched in the background; errors are logged asynchronously
+func (i *InfluxDBService) LogRawReading(data models.ESP32SensorData, eventTime time.Time) {
+	if len(data.Values) == 0 || !i.RawEnabled(data.GreenhouseID, data.NodeID) {
+		return
+	}
+
+	i.rawMu.Lock()
+	defer i.rawMu.Unlock()
+
+	writeAPI := i.getRawWriteAPI()
+	if writeAPI == nil {
+		return
+	}
+
+	fields := make(map[string]interface{}, len(data.Values))
+	for name, value := range data.Values {
+		fields[name] = i.registry.FieldValue(name, value)
+	}
+	writeAPI.WritePoint(influxdb2.NewPoint(
+		RawMeasurement,
+		map[string]string{
+			"greenhouse_id": data.GreenhouseID,
+			"node_id":       data.NodeID,
+		},
+		fields,
+		eventTime,
+	))
+}
+
+// getRawWriteAPI returns the raw write API, creating it on first use
+// Must be called with rawMu held
+func (i *InfluxDBService) getRawWriteAPI() api.WriteAPI {
+	i.shutdownMu.RLock()
+	shutdown := i.shutdown
+	i.shutdownMu.RUnlock()
+	client, _ := i.conn()
+	if shutdown || client == nil {
+		return nil
+	}
+
+	if i.rawWriteAPI == nil {
+		i.rawWriteAPI = client.WriteAPI(i.org, i.config.Raw.Bucket)
+		errorsCh := i.rawWriteAPI.Errors()
+		go func() {
+			for err := range errorsCh {
+				log.Printf("Warning: Failed to write raw readings to InfluxDB: %v", err)
+			}
+		}()
+	}
+	return i.rawWriteAPI
+}
+
+// ensureBucket creates a bucket with the given retention if it does not exist yet
+func ensureBucket(client influxdb2.Client, org, bucket string, retention time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := client.BucketsAPI().FindBucketByName(ctx, bucket); err == nil {
+		return
+	}
+	organization, err := client.OrganizationsAPI().FindOrganizationByName(ctx, org)
+	if err != nil {
+		log.Printf("Warning: Could not look up organization %s to create bucket %s: %v", org, bucket, err)
+		return
+	}
+	rule := domain.RetentionRule{EverySeconds: int64(retention / time.Second)}
+	if _, err := client.BucketsAPI().CreateBucketWithName(ctx, organization, bucket, rule); err != nil {
+		log.Printf("Warning: Could not create bucket %s: %v", bucket, err)
+		return
+	}
+	log.Printf("Created bucket %s with retention %v", bucket, retention)
+}
+
+// RawQuery describes a page of raw readings
+type RawQuery struct {
+	GreenhouseID string
+	NodeID       string
+	Sensors      []string // Sensor names to return (empty = all)
+	Start        time.Time
+	End          time.Time
+	Limit        int             // Maximum rows per page
+	After        *AveragesCursor // Return rows after this cursor (nil = first page)
+}
+
+// GetRawReadingsFromDB fetches a time-ordered page of raw readings
+// Rows are ordered by time, then greenhouse_id and node_id. The returned cursor
+// is non-nil when more rows are available.
+func (i *InfluxDBService) GetRawReadingsFromDB(query RawQuery) ([]models.RawReading, *AveragesCursor, error) {
+	client, _ := i.conn()
+	if client == nil {
+		return nil, nil, fmt.Errorf("InfluxDB not connected")
+	}
+	q, err := NewFluxQuery(i.config.Raw.Bucket).
+		Range(query.Start, query.End).
+		FilterMeasurement(RawMeasurement).
+		FilterTag("greenhouse_id", query.GreenhouseID).
+		FilterTag("node_id", query.NodeID).
+		FilterFields(query.Sensors...).
+		PivotFields().
+		Group().
+		After(query.After).
+		Sort(false, "_time", "greenhouse_id", "node_id").
+		Limit(query.Limit + 1).
+		Build()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	queryAPI := client.QueryAPI(i.org)
+	result, err := queryAPI.Query(context.Background(), q)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := make([]models.RawReading, 0, query.Limit)
+	for result.Next() {
+		record := result.Record()
+		values := make(map[string]float64)
+		for column, v := range record.Values() {
+			if strings.HasPrefix(column, "_") || column == "result" || column == "table" ||
+				column == "greenhouse_id" || column == "node_id" {
+				continue
+			}
+			if value, ok := toFloat(v); ok {
+				values[column] = value
+			}
+		}
+		out = append(out, models.RawReading{
+			GreenhouseID: fmt.Sprint(record.ValueByKey("greenhouse_id")),
+			NodeID:       fmt.Sprint(record.ValueByKey("node_id")),
+			Timestamp:    record.Time(),
+			Values:       values,
+		})
+	}
+	if result.Err() != nil {
+		return nil, nil, result.Err()
+	}
+
+	var next *AveragesCursor
+	if len(out) > query.Limit {
+		out = out[:query.Limit]
+		last := out[len(out)-1]
+		next = &AveragesCursor{Time: last.Timestamp, GreenhouseID: last.GreenhouseID, NodeID: last.NodeID}
+	}
+	return out, next, nil
+}
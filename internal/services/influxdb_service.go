@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
 )
 
 // CircuitBreaker states
@@ -23,11 +26,29 @@ const (
 
 // InfluxDBService handles InfluxDB operations
 type InfluxDBService struct {
-	client   influxdb2.Client
-	writeAPI api.WriteAPIBlocking // Reverted to blocking API for reliability
 	org      string
 	bucket   string
 	config   *config.InfluxDBConfig
+	registry *SensorRegistry
+	metrics  *MetricsService
+
+	// Connection manager; client and writeAPI are only set while InfluxDB is reachable
+	connMu    sync.RWMutex
+	client    influxdb2.Client
+	writeAPI  api.WriteAPIBlocking // Reverted to blocking API for reliability
+	status    string
+	candidate influxdb2.Client // Client being connected, closed on shutdown
+	connStop  chan struct{}
+	connDone  chan struct{}
+
+	// Points that could not be written are queued on disk and replayed later
+	wal     *DiskQueue
+	walStop chan struct{}
+	walDone chan struct{}
+
+	// Raw readings are written through a batched, non-blocking write API
+	rawMu       sync.Mutex
+	rawWriteAPI api.WriteAPI
 
 	// Circuit breaker
 	mu              sync.RWMutex
@@ -40,60 +61,53 @@ type InfluxDBService struct {
 	// Shutdown protection
 	shutdownMu sync.RWMutex
 	shutdown   bool
+	closeOnce  sync.Once
 }
 
 // NewInfluxDBService creates a new InfluxDB service
-func NewInfluxDBService(cfg *config.InfluxDBConfig) *InfluxDBService {
-	// Validate required configuration
-	if cfg.Token == "" {
-		log.Printf("Warning: INFLUXDB_TOKEN not set - InfluxDB logging will be disabled")
-		return &InfluxDBService{
-			client:   nil,
-			writeAPI: nil,
-			org:      cfg.Org,
-			bucket:   cfg.Bucket,
-			config:   cfg,
-		}
-	}
-
-	// Create client with optimized settings
-	client := influxdb2.NewClient(cfg.URL, cfg.Token)
-	defer client.Close()
-
-	// Create blocking write API for reliability
-	writeAPI := client.WriteAPIBlocking(cfg.Org, cfg.Bucket)
-
-	// Test connection
-	_, err := client.Ping(context.Background())
-	if err != nil {
-		log.Printf("Warning: Could not connect to InfluxDB: %v", err)
-		log.Printf("InfluxDB logging will be disabled")
-		return &InfluxDBService{
-			client:   nil,
-			writeAPI: nil,
-			org:      cfg.Org,
-			bucket:   cfg.Bucket,
-			config:   cfg,
-		}
-	}
-
-	log.Printf("Successfully connected to InfluxDB at %s", cfg.URL)
-	log.Printf("Using organization: %s, bucket: %s", cfg.Org, cfg.Bucket)
-	log.Printf("Blocking writes enabled for reliability")
-	return &InfluxDBService{
-		client:    client,
-		writeAPI:  writeAPI,
+// The connection is established in the background and retried with backoff,
+// so InfluxDB may come up after the backend
+func NewInfluxDBService(cfg *config.InfluxDBConfig, registry *SensorRegistry, metrics *MetricsService) *InfluxDBService {
+	svc := &InfluxDBService{
 		org:       cfg.Org,
 		bucket:    cfg.Bucket,
 		config:    cfg,
+		registry:  registry,
+		metrics:   metrics,
+		status:    ConnectionDisabled,
 		state:     StateClosed,
 		threshold: 5,                // Fail after 5 consecutive failures
 		timeout:   30 * time.Second, // Wait 30 seconds before trying again
 	}
+
+	// Validate required configuration
+	if cfg.Token == "" {
+		log.Printf("Warning: INFLUXDB_TOKEN not set - InfluxDB logging will be disabled")
+		metrics.SetInfluxDBConnectionStatus(false)
+		return svc
+	}
+
+	// Open the disk queue before connecting so an outage at startup loses nothing
+	svc.wal = openWAL(&cfg.WAL)
+
+	// Create client with optimized settings; batching only applies to the raw write API
+	options := influxdb2.DefaultOptions().
+		SetBatchSize(uint(cfg.Raw.BatchSize)).
+		SetFlushInterval(uint(cfg.Raw.FlushInterval / time.Millisecond))
+	svc.candidate = influxdb2.NewClientWithOptions(cfg.URL, cfg.Token, options)
+
+	svc.startConnectionManager()
+	svc.startReplayer()
+	return svc
 }
 
-// LogAverages logs sensor averages to InfluxDB with circuit breaker
+// LogAverages logs base window sensor averages to InfluxDB with circuit breaker
 func (i *InfluxDBService) LogAverages(averages models.AverageResult) error {
+	return i.LogAveragesTo(BaseMeasurement, averages)
+}
+
+// LogAveragesTo logs sensor averages to the given measurement with circuit breaker
+func (i *InfluxDBService) LogAveragesTo(measurement string, averages models.AverageResult) error {
 	// Check shutdown state first
 	i.shutdownMu.RLock()
 	if i.shutdown {
@@ -102,72 +116,60 @@ func (i *InfluxDBService) LogAverages(averages models.AverageResult) error {
 	}
 	i.shutdownMu.RUnlock()
 
-	if i.client == nil || i.writeAPI == nil {
-		return fmt.Errorf("InfluxDB not connected")
-	}
-
-	// Check circuit breaker state
-	if !i.canExecute() {
-		return fmt.Errorf("circuit breaker is open - InfluxDB writes are temporarily disabled")
-	}
-
 	fields := map[string]interface{}{
 		"readings": averages.Readings,
 		"duration": averages.Duration,
 	}
-	if averages.BagTemp != nil {
-		fields["Bag_Temp_average"] = *averages.BagTemp
-	}
-	if averages.LightPar != nil {
-		fields["Light_Par_average"] = *averages.LightPar
+	for name, stats := range averages.Stats {
+		fields[i.registry.FieldName(name, StatAverage)] = stats.Mean
+		fields[i.registry.FieldName(name, StatMin)] = stats.Min
+		fields[i.registry.FieldName(name, StatMax)] = stats.Max
+		fields[i.registry.FieldName(name, StatStdDev)] = stats.StdDev
+		fields[i.registry.FieldName(name, StatMedian)] = stats.Median
+		fields[i.registry.FieldName(name, StatCount)] = stats.Count
 	}
-	if averages.AirTemp != nil {
-		fields["Air_Temp_average"] = *averages.AirTemp
-	}
-	if averages.AirRh != nil {
-		fields["Air_Rh_average"] = *averages.AirRh
-	}
-	if averages.LeafTemp != nil {
-		fields["Leaf_temp_average"] = *averages.LeafTemp
-	}
-	if averages.DripWeight != nil {
-		fields["drip_weight_average"] = *averages.DripWeight
-	}
-	if averages.BagRh1 != nil {
-		fields["Bag_Rh1_average"] = *averages.BagRh1
-	}
-	if averages.BagRh2 != nil {
-		fields["Bag_Rh2_average"] = *averages.BagRh2
-	}
-	if averages.BagRh3 != nil {
-		fields["Bag_Rh3_average"] = *averages.BagRh3
-	}
-	if averages.BagRh4 != nil {
-		fields["Bag_Rh4_average"] = *averages.BagRh4
-	}
-	if averages.Rain != nil {
-		fields["Rain_average"] = *averages.Rain
+	for name, value := range averages.Derived {
+		fields[name] = value
 	}
 
 	point := influxdb2.NewPoint(
-		"sensor_averages",
+		measurement,
 		map[string]string{
 			"greenhouse_id": averages.GreenhouseID,
 			"node_id":       averages.NodeID,
 		},
 		fields,
-		time.Now(),
+		averages.WindowEnd,
 	)
 
-	err := i.writeAPI.WritePoint(context.Background(), point)
+	if err := i.writePoint(point); err != nil {
+		return err
+	}
+	log.Printf("Logged sensor averages to InfluxDB %s: %s/%s (%.1fs, %d readings)",
+		measurement, averages.GreenhouseID, averages.NodeID, averages.Duration, averages.Readings)
+	return nil
+}
+
+// writePoint writes a point with the blocking write API, queuing it on disk
+// when InfluxDB is not connected, the circuit breaker is open or the write fails
+func (i *InfluxDBService) writePoint(point *write.Point) error {
+	_, writeAPI := i.conn()
+	if writeAPI == nil {
+		return i.queuePoint(point, fmt.Errorf("InfluxDB not connected"))
+	}
+
+	// Check circuit breaker state
+	if !i.canExecute() {
+		return i.queuePoint(point, fmt.Errorf("circuit breaker is open - InfluxDB writes are temporarily disabled"))
+	}
+
+	err := writeAPI.WritePoint(context.Background(), point)
 	if err != nil {
 		i.recordFailure()
-		return fmt.Errorf("failed to write to InfluxDB: %w", err)
+		return i.queuePoint(point, fmt.Errorf("failed to write to InfluxDB: %w", err))
 	}
 
 	i.recordSuccess()
-	log.Printf("Logged sensor averages to InfluxDB: %s/%s (%.1fs, %d readings)",
-		averages.GreenhouseID, averages.NodeID, averages.Duration, averages.Readings)
 	return nil
 }
 
@@ -225,56 +227,83 @@ func (i *InfluxDBService) recordSuccess() {
 	}
 }
 
-// Note: Individual sensor logging removed - only averages are logged every 60 seconds
+// Note: Individual readings are only logged to the sensor_raw measurement (see influxdb_raw.go)
 
 // Close closes the InfluxDB connection
+// It is safe to call more than once; only the first call has an effect
 func (i *InfluxDBService) Close() {
+	i.closeOnce.Do(i.close)
+}
+
+// close stops the background goroutines and closes the client
+func (i *InfluxDBService) close() {
 	// Mark as shutting down first
 	i.shutdownMu.Lock()
 	i.shutdown = true
 	i.shutdownMu.Unlock()
 
+	// Wait for in-flight raw writes; closing the client flushes pending batches
+	i.rawMu.Lock()
+	i.rawWriteAPI = nil
+	i.rawMu.Unlock()
+
+	// Stop reconnecting and replaying; queued points stay on disk for the next run
+	i.stopConnectionManager()
+	i.stopReplayer()
+
 	// Close the client
-	if i.client != nil {
-		i.client.Close()
+	i.connMu.Lock()
+	i.client = nil
+	i.writeAPI = nil
+	i.connMu.Unlock()
+	if i.candidate != nil {
+		i.candidate.Close()
 		log.Println("InfluxDB connection closed")
 	}
 }
 
+// IsWritable returns true if points can be written now or queued on disk for replay
+func (i *InfluxDBService) IsWritable() bool {
+	return i.IsConnected() || i.wal != nil
+}
+
 // IsConnected returns true if InfluxDB is connected
 func (i *InfluxDBService) IsConnected() bool {
-	connected := i.client != nil && i.writeAPI != nil
-	// Update metrics if available (will be set by sensor service)
-	return connected
+	return i.ConnectionStatus() == ConnectionConnected
 }
 
 // GetConnectionInfo returns connection information
 func (i *InfluxDBService) GetConnectionInfo() string {
-	if i.IsConnected() {
+	switch i.ConnectionStatus() {
+	case ConnectionConnected:
 		return fmt.Sprintf("Connected to InfluxDB - Org: %s, Bucket: %s", i.org, i.bucket)
+	case ConnectionConnecting:
+		return fmt.Sprintf("Connecting to InfluxDB at %s - retrying in the background", i.config.URL)
+	default:
+		return "InfluxDB not connected"
 	}
-	return "InfluxDB not connected"
 }
 
 // GetLatestAveragesFromDB fetches the latest average for each node from InfluxDB
 func (i *InfluxDBService) GetLatestAveragesFromDB(greenhouseID, nodeID string) ([]models.AverageResult, error) {
-	if i.client == nil || i.writeAPI == nil {
+	client, _ := i.conn()
+	if client == nil {
 		return nil, fmt.Errorf("InfluxDB not connected")
 	}
-	q := `from(bucket: "` + i.bucket + `")
-	  |> range(start: -7d)
-	  |> filter(fn: (r) => r._measurement == "sensor_averages")`
-	if greenhouseID != "" {
-		q += ` |> filter(fn: (r) => r.greenhouse_id == "` + greenhouseID + `")`
-	}
-	if nodeID != "" {
-		q += ` |> filter(fn: (r) => r.node_id == "` + nodeID + `")`
+	q, err := NewFluxQuery(i.bucket).
+		Range(time.Now().Add(-7*24*time.Hour), time.Time{}).
+		FilterMeasurement(BaseMeasurement).
+		FilterTag("greenhouse_id", greenhouseID).
+		FilterTag("node_id", nodeID).
+		Sort(true, "_time").
+		Group("greenhouse_id", "node_id", "_field").
+		First().
+		Build()
+	if err != nil {
+		return nil, err
 	}
-	q += ` |> sort(columns: ["_time"], desc: true)`
-	q += ` |> group(columns: ["greenhouse_id", "node_id", "_field"])
-	  |> first()`
 
-	queryAPI := i.client.QueryAPI(i.org)
+	queryAPI := client.QueryAPI(i.org)
 	result, err := queryAPI.Query(context.Background(), q)
 	if err != nil {
 		return nil, err
@@ -288,7 +317,7 @@ func (i *InfluxDBService) GetLatestAveragesFromDB(greenhouseID, nodeID string) (
 		gID := result.Record().ValueByKey("greenhouse_id")
 		nID := result.Record().ValueByKey("node_id")
 		field := result.Record().Field()
-		value, ok := result.Record().Value().(float64)
+		value, ok := toFloat(result.Record().Value())
 		if !ok {
 			continue
 		}
@@ -311,93 +340,220 @@ func (i *InfluxDBService) GetLatestAveragesFromDB(greenhouseID, nodeID string) (
 		out = append(out, models.AverageResult{
 			GreenhouseID: key.GreenhouseID,
 			NodeID:       key.NodeID,
-			BagTemp:      getFieldPtr(fields, "Bag_Temp_average"),
-			LightPar:     getFieldPtr(fields, "Light_Par_average"),
-			AirTemp:      getFieldPtr(fields, "Air_Temp_average"),
-			AirRh:        getFieldPtr(fields, "Air_Rh_average"),
-			LeafTemp:     getFieldPtr(fields, "Leaf_temp_average"),
-			DripWeight:   getFieldPtr(fields, "drip_weight_average"),
-			BagRh1:       getFieldPtr(fields, "Bag_Rh1_average"),
-			BagRh2:       getFieldPtr(fields, "Bag_Rh2_average"),
-			BagRh3:       getFieldPtr(fields, "Bag_Rh3_average"),
-			BagRh4:       getFieldPtr(fields, "Bag_Rh4_average"),
-			Rain:         getFieldPtr(fields, "Rain_average"),
+			WindowEnd:    nodeTime[key],
+			Stats:        i.statsFromFields(fields),
+			Derived:      derivedFromFields(fields),
 		})
 	}
 	return out, nil
 }
 
-// GetAllAveragesFromDB fetches all average data for all nodes from InfluxDB
-func (i *InfluxDBService) GetAllAveragesFromDB(greenhouseID, nodeID string) ([]models.AverageResult, error) {
-	if i.client == nil || i.writeAPI == nil {
-		return nil, fmt.Errorf("InfluxDB not connected")
+// AveragesCursor identifies the last row of a page of historical averages
+type AveragesCursor struct {
+	Time         time.Time
+	GreenhouseID string
+	NodeID       string
+}
+
+// AveragesQuery describes a page of historical averages
+type AveragesQuery struct {
+	GreenhouseID string
+	NodeID       string
+	Measurement  string
+	Start        time.Time
+	End          time.Time
+	Every        time.Duration   // aggregateWindow interval (0 = no downsampling)
+	Limit        int             // Maximum rows per page
+	After        *AveragesCursor // Return rows after this cursor (nil = first page)
+}
+
+// GetAllAveragesFromDB fetches a time-ordered page of averages for all nodes
+// Rows are ordered by time, then greenhouse_id and node_id. The returned cursor
+// is non-nil when more rows are available.
+func (i *InfluxDBService) GetAllAveragesFromDB(query AveragesQuery) ([]models.AverageResult, *AveragesCursor, error) {
+	client, _ := i.conn()
+	if client == nil {
+		return nil, nil, fmt.Errorf("InfluxDB not connected")
+	}
+	fq := NewFluxQuery(i.bucket).
+		Range(query.Start, query.End).
+		FilterMeasurement(query.Measurement).
+		FilterTag("greenhouse_id", query.GreenhouseID).
+		FilterTag("node_id", query.NodeID)
+	if query.Every > 0 {
+		fq.DownsampleStats(query.Every)
+	}
+	q, err := fq.PivotFields().
+		Group().
+		After(query.After).
+		Sort(false, "_time", "greenhouse_id", "node_id").
+		Limit(query.Limit + 1).
+		Build()
+	if err != nil {
+		return nil, nil, err
 	}
-	q := `from(bucket: "` + i.bucket + `")
-	  |> range(start: -30d)
-	  |> filter(fn: (r) => r._measurement == "sensor_averages")`
-	if greenhouseID != "" {
-		q += ` |> filter(fn: (r) => r.greenhouse_id == "` + greenhouseID + `")`
+
+	queryAPI := client.QueryAPI(i.org)
+	result, err := queryAPI.Query(context.Background(), q)
+	if err != nil {
+		return nil, nil, err
 	}
-	if nodeID != "" {
-		q += ` |> filter(fn: (r) => r.node_id == "` + nodeID + `")`
+	out := make([]models.AverageResult, 0, query.Limit)
+	for result.Next() {
+		record := result.Record()
+		fields := make(map[string]float64)
+		for column, v := range record.Values() {
+			if strings.HasPrefix(column, "_") || column == "result" || column == "table" ||
+				column == "greenhouse_id" || column == "node_id" {
+				continue
+			}
+			if value, ok := toFloat(v); ok {
+				fields[column] = value
+			}
+		}
+		if query.Every > 0 {
+			i.combineDownsampledStats(fields)
+		}
+		readings, _ := toFloat(record.ValueByKey("readings"))
+		duration, _ := toFloat(record.ValueByKey("duration"))
+		out = append(out, models.AverageResult{
+			GreenhouseID: fmt.Sprint(record.ValueByKey("greenhouse_id")),
+			NodeID:       fmt.Sprint(record.ValueByKey("node_id")),
+			WindowEnd:    record.Time(),
+			Duration:     duration,
+			Readings:     int(readings),
+			Stats:        i.statsFromFields(fields),
+			Derived:      derivedFromFields(fields),
+		})
+	}
+	if result.Err() != nil {
+		return nil, nil, result.Err()
 	}
-	q += ` |> sort(columns: ["_time"], desc: false)`
-	q += ` |> group(columns: ["greenhouse_id", "node_id", "_field"])
-	  |> keep(columns: ["_time", "greenhouse_id", "node_id", "_field", "_value"])`
 
-	queryAPI := i.client.QueryAPI(i.org)
-	result, err := queryAPI.Query(context.Background(), q)
+	var next *AveragesCursor
+	if len(out) > query.Limit {
+		out = out[:query.Limit]
+		last := out[len(out)-1]
+		next = &AveragesCursor{Time: last.WindowEnd, GreenhouseID: last.GreenhouseID, NodeID: last.NodeID}
+	}
+	return out, next, nil
+}
+
+// SensorMean is the stored mean of one sensor for one node at a point in time
+type SensorMean struct {
+	NodeID string
+	Time   time.Time
+	Value  float64
+}
+
+// GetSensorMeansFromDB fetches the means of one sensor for every node of a greenhouse
+// from an averages measurement, oldest first
+func (i *InfluxDBService) GetSensorMeansFromDB(measurement, greenhouseID, sensor string, start, end time.Time) ([]SensorMean, error) {
+	client, _ := i.conn()
+	if client == nil {
+		return nil, fmt.Errorf("InfluxDB not connected")
+	}
+	q, err := NewFluxQuery(i.bucket).
+		Range(start, end).
+		FilterMeasurement(measurement).
+		FilterTag("greenhouse_id", greenhouseID).
+		FilterFields(i.registry.FieldName(sensor, StatAverage)).
+		Keep("_time", "_value", "node_id").
+		Group().
+		Sort(false, "_time", "node_id").
+		Build()
 	if err != nil {
 		return nil, err
 	}
-	type nodeKey struct {
-		GreenhouseID, NodeID string
-		Time                 time.Time
+
+	queryAPI := client.QueryAPI(i.org)
+	result, err := queryAPI.Query(context.Background(), q)
+	if err != nil {
+		return nil, err
 	}
-	// Map: nodeKey -> field -> value
-	allMap := make(map[nodeKey]map[string]float64)
+	means := make([]SensorMean, 0)
 	for result.Next() {
-		gID := result.Record().ValueByKey("greenhouse_id")
-		nID := result.Record().ValueByKey("node_id")
-		t := result.Record().Time()
-		field := result.Record().Field()
-		value, ok := result.Record().Value().(float64)
+		record := result.Record()
+		value, ok := toFloat(record.Value())
 		if !ok {
 			continue
 		}
-		key := nodeKey{fmt.Sprint(gID), fmt.Sprint(nID), t}
-		if _, ok := allMap[key]; !ok {
-			allMap[key] = make(map[string]float64)
-		}
-		allMap[key][field] = value
+		means = append(means, SensorMean{
+			NodeID: tagValue(record.ValueByKey("node_id")),
+			Time:   record.Time(),
+			Value:  value,
+		})
 	}
 	if result.Err() != nil {
 		return nil, result.Err()
 	}
-	var out []models.AverageResult
-	for key, fields := range allMap {
-		out = append(out, models.AverageResult{
-			GreenhouseID: key.GreenhouseID,
-			NodeID:       key.NodeID,
-			BagTemp:      getFieldPtr(fields, "Bag_Temp_average"),
-			LightPar:     getFieldPtr(fields, "Light_Par_average"),
-			AirTemp:      getFieldPtr(fields, "Air_Temp_average"),
-			AirRh:        getFieldPtr(fields, "Air_Rh_average"),
-			LeafTemp:     getFieldPtr(fields, "Leaf_temp_average"),
-			DripWeight:   getFieldPtr(fields, "drip_weight_average"),
-			BagRh1:       getFieldPtr(fields, "Bag_Rh1_average"),
-			BagRh2:       getFieldPtr(fields, "Bag_Rh2_average"),
-			BagRh3:       getFieldPtr(fields, "Bag_Rh3_average"),
-			BagRh4:       getFieldPtr(fields, "Bag_Rh4_average"),
-			Rain:         getFieldPtr(fields, "Rain_average"),
-		})
+	return means, nil
+}
+
+// statsFromFields maps InfluxDB aggregate fields back to per-sensor stats
+// Points written before stats were recorded only carry the average (Count is 0)
+// State sensors are skipped, including averages stored before they were marked as state
+func (i *InfluxDBService) statsFromFields(fields map[string]float64) map[string]models.SensorStats {
+	out := make(map[string]models.SensorStats)
+	for _, sensor := range i.registry.Sensors() {
+		mean, ok := fields[i.registry.FieldName(sensor.Name, StatAverage)]
+		if !ok || !i.registry.IsAggregated(sensor.Name) {
+			continue
+		}
+		out[sensor.Name] = models.SensorStats{
+			Mean:   mean,
+			Min:    fields[i.registry.FieldName(sensor.Name, StatMin)],
+			Max:    fields[i.registry.FieldName(sensor.Name, StatMax)],
+			StdDev: fields[i.registry.FieldName(sensor.Name, StatStdDev)],
+			Median: fields[i.registry.FieldName(sensor.Name, StatMedian)],
+			Count:  int(fields[i.registry.FieldName(sensor.Name, StatCount)]),
+		}
 	}
-	return out, nil
+	return out
 }
 
-func getFieldPtr(fields map[string]float64, key string) *float64 {
-	if v, ok := fields[key]; ok {
-		return &v
+// combineDownsampledStats turns the count-weighted sums written by DownsampleStats
+// back into the pooled mean and standard deviation of each sensor
+func (i *InfluxDBService) combineDownsampledStats(fields map[string]float64) {
+	for _, sensor := range i.registry.Sensors() {
+		averageField := i.registry.FieldName(sensor.Name, StatAverage)
+		stddevField := i.registry.FieldName(sensor.Name, StatStdDev)
+		sum, ok := fields[averageField]
+		count := fields[i.registry.FieldName(sensor.Name, StatCount)]
+		if !ok || count <= 0 {
+			delete(fields, averageField)
+			delete(fields, stddevField)
+			continue
+		}
+		mean := sum / count
+		fields[averageField] = roundTo(mean, sensor.Precision)
+		if squares, ok := fields[stddevField]; ok {
+			fields[stddevField] = roundTo(math.Sqrt(math.Max(squares/count-mean*mean, 0)), sensor.Precision)
+		}
+	}
+}
+
+// derivedFromFields picks the derived metric fields of a point
+func derivedFromFields(fields map[string]float64) map[string]float64 {
+	out := make(map[string]float64)
+	for _, m := range derivedMetrics {
+		if value, ok := fields[m.name]; ok {
+			out[m.name] = value
+		}
+	}
+	return out
+}
+
+// toFloat converts a numeric InfluxDB record value to float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	default:
+		return 0, false
 	}
-	return nil
 }
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"testing"
+
+	"iot-agriculture-backend/internal/config"
+)
+
+func TestCombineDownsampledStats(t *testing.T) {
+	precision := 2
+	i := &InfluxDBService{registry: NewSensorRegistry(&config.SensorsConfig{Sensors: []config.SensorDefinition{
+		{Name: "Air_Temp", JSONKey: "Air_Temp", Unit: "°C", Type: config.SensorTypeFloat, Precision: &precision},
+		{Name: "Air_Hum", JSONKey: "Air_Hum", Unit: "%", Type: config.SensorTypeFloat},
+	}})}
+
+	// Windows {1, 3} (mean 2, stddev 1) and {5, 7, 9} (mean 7, stddev √(8/3))
+	// summed by DownsampleStats: Σ mean·n = 25 and Σ n·(stddev² + mean²) = 165
+	fields := map[string]float64{
+		"Air_Temp_average": 25,
+		"Air_Temp_stddev":  165,
+		"Air_Temp_count":   5,
+		"Air_Temp_min":     1,
+		"Air_Temp_max":     9,
+		"Air_Hum_average":  61.5, // legacy point without a count
+	}
+	i.combineDownsampledStats(fields)
+
+	stats := i.statsFromFields(fields)
+	want := map[string]float64{"mean": 5, "stddev": 2.83, "min": 1, "max": 9, "count": 5}
+	got := stats["Air_Temp"]
+	for name, value := range map[string]float64{"mean": got.Mean, "stddev": got.StdDev, "min": got.Min, "max": got.Max, "count": float64(got.Count)} {
+		if value != want[name] {
+			t.Errorf("Air_Temp %s = %v, want %v", name, value, want[name])
+		}
+	}
+	if _, ok := stats["Air_Hum"]; ok {
+		t.Errorf("sensor without a count was not dropped: %+v", stats["Air_Hum"])
+	}
+}
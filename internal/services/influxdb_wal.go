@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// walReplayBatch is the number of queued points written per replay request
+const walReplayBatch = 500
+
+// openWAL opens the disk queue configured for InfluxDB writes, or returns nil if disabled
+func openWAL(cfg *config.WALConfig) *DiskQueue {
+	if cfg.Dir == "" {
+		return nil
+	}
+	wal, err := NewDiskQueue(cfg.Dir, int64(cfg.MaxBytes), int64(cfg.SegmentBytes))
+	if err != nil {
+		log.Printf("Warning: Could not open InfluxDB WAL in %s: %v", cfg.Dir, err)
+		log.Printf("Failed InfluxDB writes will not be retried")
+		return nil
+	}
+	log.Printf("InfluxDB WAL enabled in %s (max %d bytes)", cfg.Dir, cfg.MaxBytes)
+	return wal
+}
+
+// ErrPointQueued marks a write error whose point was queued on disk and will be replayed,
+// so callers must not retry the write themselves
+var ErrPointQueued = errors.New("point queued on disk for replay")
+
+// queuePoint stores a point that could not be written in the disk queue
+// The returned error still reports the write failure so callers can log and count it,
+// and wraps ErrPointQueued when the point was queued
+func (i *InfluxDBService) queuePoint(point *write.Point, cause error) error {
+	if i.wal == nil {
+		return cause
+	}
+	if err := i.wal.Append(write.PointToLineProtocol(point, time.Nanosecond)); err != nil {
+		return fmt.Errorf("%w (WAL append failed: %v)", cause, err)
+	}
+	return fmt.Errorf("%w - %w", cause, ErrPointQueued)
+}
+
+// WALStats returns the contents of the disk queue (zero if disabled)
+func (i *InfluxDBService) WALStats() WALStats {
+	if i.wal == nil {
+		return WALStats{}
+	}
+	return i.wal.Stats()
+}
+
+// startReplayer starts the background goroutine draining the disk queue
+func (i *InfluxDBService) startReplayer() {
+	if i.wal == nil {
+		return
+	}
+	i.walStop = make(chan struct{})
+	i.walDone = make(chan struct{})
+	go func() {
+		defer close(i.walDone)
+		ticker := time.NewTicker(i.config.WAL.ReplayInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				i.replayWAL()
+			case <-i.walStop:
+				return
+			}
+		}
+	}()
+}
+
+// stopReplayer stops the replayer and closes the disk queue
+func (i *InfluxDBService) stopReplayer() {
+	if i.wal == nil {
+		return
+	}
+	close(i.walStop)
+	<-i.walDone
+	i.wal.Close()
+}
+
+// replayWAL writes queued points in batches until the queue is empty, the
+// circuit breaker opens, or a write fails
+// Replaying the same point twice is harmless: InfluxDB keeps one point per series and timestamp
+func (i *InfluxDBService) replayWAL() {
+	replayed := 0
+	defer func() {
+		if replayed > 0 {
+			log.Printf("WAL: replayed %d queued points to InfluxDB (%d remaining)", replayed, i.wal.Stats().Points)
+		}
+	}()
+
+	for {
+		_, writeAPI := i.conn()
+		if writeAPI == nil || !i.canExecute() {
+			return
+		}
+		batch, err := i.wal.Peek(walReplayBatch)
+		if err != nil {
+			log.Printf("Warning: Failed to read InfluxDB WAL: %v", err)
+			return
+		}
+		lines := batch.Lines
+		if len(lines) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err = writeAPI.WriteRecord(ctx, lines...)
+		cancel()
+		if err != nil {
+			if isRejectedWrite(err) {
+				// Retrying a batch InfluxDB refuses would block the queue forever
+				log.Printf("Warning: InfluxDB rejected %d queued points, dropping them: %v", len(lines), err)
+				i.wal.Commit(batch)
+				continue
+			}
+			i.recordFailure()
+			log.Printf("Warning: WAL replay failed, will retry: %v", err)
+			return
+		}
+		i.recordSuccess()
+		i.wal.Commit(batch)
+		replayed += len(lines)
+	}
+}
+
+// isRejectedWrite returns true if InfluxDB refused the data itself (4xx other than
+// 401/403/404/429), as opposed to being unavailable
+func isRejectedWrite(err error) bool {
+	var httpErr *http2.Error
+	if !errors.As(err, &httpErr) {
+		return false
+	}
+	switch httpErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests:
+		return false
+	}
+	return httpErr.StatusCode >= 400 && httpErr.StatusCode < 500
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// Decimals irrigation weights and drain ratios are reported with
+const (
+	irrigationWeightPrecision = 1
+	irrigationRatioPrecision  = 3
+)
+
+// irrigationState tracks the substrate weight of one node and the irrigation in progress
+type irrigationState struct {
+	lastTime   time.Time
+	lastWeight float64
+	active     bool                   // An irrigation was detected and is not recorded yet
+	event      models.IrrigationEvent // The irrigation in progress
+}
+
+// IrrigationService detects irrigations in the raw substrate weight stream of each
+// greenhouse/node. A weight gain faster than the start rate starts an irrigation;
+// it stops at the peak weight once no new peak is reached for the stop time, and the
+// drainage is the weight lost from the peak until the settle time has passed
+type IrrigationService struct {
+	mu        sync.Mutex
+	cfg       *config.IrrigationConfig
+	states    map[string]*irrigationState // key: greenhouse_id|node_id
+	completed []models.IrrigationEvent
+	metrics   *MetricsService
+}
+
+// NewIrrigationService creates a new irrigation detection service
+func NewIrrigationService(cfg *config.IrrigationConfig, metrics *MetricsService) *IrrigationService {
+	return &IrrigationService{
+		cfg:     cfg,
+		states:  make(map[string]*irrigationState),
+		metrics: metrics,
+	}
+}
+
+// Enabled returns true if a weight sensor is configured
+func (s *IrrigationService) Enabled() bool {
+	return s.cfg.Sensor != ""
+}
+
+// Location returns the time zone of the daily totals
+func (s *IrrigationService) Location() *time.Location {
+	return s.cfg.Location
+}
+
+// Add feeds the weight reading of a message with the given event time into detection
+// Readings older than the node's last reading are ignored
+func (s *IrrigationService) Add(data models.ESP32SensorData, eventTime time.Time) {
+	if !s.Enabled() {
+		return
+	}
+	weight, ok := data.Values[s.cfg.Sensor]
+	if !ok {
+		return
+	}
+	key := data.GreenhouseID + "|" + data.NodeID
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[key]
+	if !ok {
+		s.states[key] = &irrigationState{lastTime: eventTime, lastWeight: weight}
+		return
+	}
+	if !eventTime.After(state.lastTime) {
+		return
+	}
+	rate := (weight - state.lastWeight) / eventTime.Sub(state.lastTime).Minutes()
+
+	if state.active {
+		sincePeak := eventTime.Sub(state.event.Stop)
+		switch {
+		case weight > state.event.PeakWeight && sincePeak < s.cfg.StopAfter:
+			// Still irrigating
+			state.event.PeakWeight = weight
+			state.event.Stop = eventTime
+			state.event.SettledWeight = weight
+			state.event.End = eventTime
+		case rate >= s.cfg.StartRate && sincePeak >= s.cfg.StopAfter:
+			// The next irrigation started before the previous one settled
+			s.finish(state)
+		case sincePeak > s.cfg.SettleTime:
+			s.finish(state)
+		case weight < state.event.SettledWeight:
+			state.event.SettledWeight = weight
+			state.event.End = eventTime
+		}
+	}
+	if !state.active && rate >= s.cfg.StartRate {
+		state.active = true
+		state.event = models.IrrigationEvent{
+			GreenhouseID:  data.GreenhouseID,
+			NodeID:        data.NodeID,
+			Start:         state.lastTime,
+			StartWeight:   state.lastWeight,
+			Stop:          eventTime,
+			PeakWeight:    weight,
+			End:           eventTime,
+			SettledWeight: weight,
+		}
+	}
+	state.lastTime = eventTime
+	state.lastWeight = weight
+}
+
+// Flush completes the irrigations whose settle time has passed by now and returns
+// every irrigation completed since the last call, oldest first
+func (s *IrrigationService) Flush(now time.Time) []models.IrrigationEvent {
+	if !s.Enabled() {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, state := range s.states {
+		if state.active && now.Sub(state.event.Stop) > s.cfg.SettleTime {
+			s.finish(state)
+		}
+	}
+	completed := s.completed
+	s.completed = nil
+	sort.Slice(completed, func(i, j int) bool { return completed[i].Start.Before(completed[j].Start) })
+	return completed
+}
+
+// finish records a node's irrigation in progress if it supplied enough water
+func (s *IrrigationService) finish(state *irrigationState) {
+	state.active = false
+	event := state.event
+	supply := event.PeakWeight - event.StartWeight
+	if supply < s.cfg.MinSupply || supply <= 0 {
+		return
+	}
+	weightPrecision, ratioPrecision := irrigationWeightPrecision, irrigationRatioPrecision
+	drain := max(event.PeakWeight-event.SettledWeight, 0)
+	event.Duration = event.Stop.Sub(event.Start).Seconds()
+	event.Supply = roundTo(supply, &weightPrecision)
+	event.Drain = roundTo(drain, &weightPrecision)
+	event.DrainRatio = roundTo(drain/supply, &ratioPrecision)
+	s.completed = append(s.completed, event)
+	s.metrics.IncrementIrrigationEvents(event.GreenhouseID, event.NodeID)
+}
+
+// DailyIrrigation sums events per greenhouse and local day, newest day first
+func DailyIrrigation(events []models.IrrigationEvent, location *time.Location) []models.IrrigationDaily {
+	totals := make(map[string]*models.IrrigationDaily)
+	for _, event := range events {
+		date := event.Start.In(location).Format(dayLayout)
+		key := event.GreenhouseID + "|" + date
+		day, ok := totals[key]
+		if !ok {
+			day = &models.IrrigationDaily{GreenhouseID: event.GreenhouseID, Date: date}
+			totals[key] = day
+		}
+		day.Events++
+		day.Supply += event.Supply
+		day.Drain += event.Drain
+	}
+
+	weightPrecision, ratioPrecision := irrigationWeightPrecision, irrigationRatioPrecision
+	out := make([]models.IrrigationDaily, 0, len(totals))
+	for _, day := range totals {
+		if day.Supply > 0 {
+			day.DrainRatio = roundTo(day.Drain/day.Supply, &ratioPrecision)
+		}
+		day.Supply = roundTo(day.Supply, &weightPrecision)
+		day.Drain = roundTo(day.Drain, &weightPrecision)
+		out = append(out, *day)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Date != out[j].Date {
+			return out[i].Date > out[j].Date
+		}
+		return out[i].GreenhouseID < out[j].GreenhouseID
+	})
+	return out
+}
+
+// describeIrrigation formats an event for the console, e.g. "GH1/Node01 at 06:00:02: 312.5 g supplied, 62.4 g drained (20%)"
+func describeIrrigation(event models.IrrigationEvent) string {
+	return fmt.Sprintf("%s/%s at %s: %s g supplied, %s g drained (%.0f%%)",
+		event.GreenhouseID, event.NodeID, event.Start.Format(time.TimeOnly),
+		formatNumber(event.Supply), formatNumber(event.Drain), event.DrainRatio*100)
+}
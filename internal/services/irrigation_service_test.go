@@ -0,0 +1,191 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// irrigationTestStart is the event time of the first weight reading
+var irrigationTestStart = time.Date(2024, 6, 1, 6, 0, 0, 0, time.UTC)
+
+func irrigationTestService() *IrrigationService {
+	return NewIrrigationService(&config.IrrigationConfig{
+		Sensor:     "Drip_Weight",
+		Location:   time.UTC,
+		StartRate:  50,
+		MinSupply:  20,
+		StopAfter:  time.Minute,
+		SettleTime: 10 * time.Minute,
+	}, sharedTestMetrics())
+}
+
+// addWeights feeds one weight reading of a node every 10 seconds from irrigationTestStart
+func addWeights(s *IrrigationService, nodeID string, weights ...float64) {
+	for n, weight := range weights {
+		s.Add(models.ESP32SensorData{
+			GreenhouseID: "GH1",
+			NodeID:       nodeID,
+			Values:       map[string]float64{"Drip_Weight": weight},
+		}, irrigationTestStart.Add(time.Duration(n)*10*time.Second))
+	}
+}
+
+// steady returns n readings of the same weight
+func steady(weight float64, n int) []float64 {
+	weights := make([]float64, n)
+	for i := range weights {
+		weights[i] = weight
+	}
+	return weights
+}
+
+func TestIrrigationDetection(t *testing.T) {
+	type event struct {
+		start  time.Duration // Offset of the start from irrigationTestStart
+		supply float64
+		drain  float64
+	}
+	tests := []struct {
+		name    string
+		weights []float64
+		want    []event
+	}{
+		{
+			name:    "single irrigation with drainage",
+			weights: append([]float64{1000, 1000, 1050, 1100, 1150, 1200, 1200, 1190, 1170, 1150}, steady(1150, 10)...),
+			want:    []event{{start: 10 * time.Second, supply: 200, drain: 50}},
+		},
+		{
+			name:    "gain slower than the start rate",
+			weights: []float64{1000, 1005, 1010, 1015, 1020, 1025, 1030, 1035, 1040},
+		},
+		{
+			name:    "rise below the minimum supply",
+			weights: append([]float64{1000, 1015}, steady(1015, 10)...),
+		},
+		{
+			name:    "pause shorter than the stop time is merged",
+			weights: append([]float64{1000, 1050, 1100, 1100, 1100, 1100, 1150, 1200}, steady(1180, 5)...),
+			want:    []event{{start: 0, supply: 200, drain: 20}},
+		},
+		{
+			name: "pause longer than the stop time starts a new irrigation",
+			weights: append(append([]float64{1000, 1050, 1100}, steady(1090, 12)...),
+				1140, 1190, 1190, 1180),
+			want: []event{{start: 0, supply: 100, drain: 10}, {start: 140 * time.Second, supply: 100, drain: 10}},
+		},
+		{
+			name:    "weight regained after the peak is not drainage",
+			weights: []float64{1000, 1100, 1200, 1150, 1160, 1140},
+			want:    []event{{start: 0, supply: 200, drain: 60}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := irrigationTestService()
+			addWeights(s, "Node02", tt.weights...)
+			events := s.Flush(irrigationTestStart.Add(24 * time.Hour))
+			if len(events) != len(tt.want) {
+				t.Fatalf("got %d events %+v, want %d", len(events), events, len(tt.want))
+			}
+			for n, want := range tt.want {
+				got := events[n]
+				if !got.Start.Equal(irrigationTestStart.Add(want.start)) || got.Supply != want.supply || got.Drain != want.drain {
+					t.Errorf("event %d: start %s, supply %v, drain %v; want start +%s, supply %v, drain %v",
+						n, got.Start.Format(time.TimeOnly), got.Supply, got.Drain, want.start, want.supply, want.drain)
+				}
+			}
+		})
+	}
+}
+
+func TestIrrigationEventFields(t *testing.T) {
+	s := irrigationTestService()
+	addWeights(s, "Node02", 1000, 1000, 1050, 1100, 1150, 1200, 1200, 1190, 1170, 1150)
+
+	events := s.Flush(irrigationTestStart.Add(time.Hour))
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %+v", events)
+	}
+	want := models.IrrigationEvent{
+		GreenhouseID:  "GH1",
+		NodeID:        "Node02",
+		Start:         irrigationTestStart.Add(10 * time.Second),
+		Stop:          irrigationTestStart.Add(50 * time.Second),
+		End:           irrigationTestStart.Add(90 * time.Second),
+		Duration:      40,
+		StartWeight:   1000,
+		PeakWeight:    1200,
+		SettledWeight: 1150,
+		Supply:        200,
+		Drain:         50,
+		DrainRatio:    0.25,
+	}
+	if events[0] != want {
+		t.Errorf("event = %+v\nwant %+v", events[0], want)
+	}
+}
+
+func TestIrrigationFlushWaitsForSettleTime(t *testing.T) {
+	s := irrigationTestService()
+	addWeights(s, "Node02", 1000, 1100, 1200, 1190)
+	stop := irrigationTestStart.Add(20 * time.Second)
+
+	if events := s.Flush(stop.Add(10 * time.Minute)); len(events) != 0 {
+		t.Errorf("event completed before the settle time passed: %+v", events)
+	}
+	if events := s.Flush(stop.Add(10*time.Minute + time.Second)); len(events) != 1 {
+		t.Errorf("expected the event once the settle time passed, got %+v", events)
+	}
+	if events := s.Flush(stop.Add(time.Hour)); len(events) != 0 {
+		t.Errorf("event returned twice: %+v", events)
+	}
+}
+
+func TestIrrigationStatePerNode(t *testing.T) {
+	s := irrigationTestService()
+	// Interleaved nodes: Node01 irrigates, Node02 stays flat
+	for n, weights := range [][2]float64{{1000, 500}, {1100, 500}, {1200, 500}, {1180, 500}} {
+		at := irrigationTestStart.Add(time.Duration(n) * 10 * time.Second)
+		s.Add(models.ESP32SensorData{GreenhouseID: "GH1", NodeID: "Node01", Values: map[string]float64{"Drip_Weight": weights[0]}}, at)
+		s.Add(models.ESP32SensorData{GreenhouseID: "GH1", NodeID: "Node02", Values: map[string]float64{"Drip_Weight": weights[1]}}, at)
+	}
+	// A late reading is ignored instead of looking like a weight jump
+	s.Add(models.ESP32SensorData{GreenhouseID: "GH1", NodeID: "Node02", Values: map[string]float64{"Drip_Weight": 100}}, irrigationTestStart)
+	// Messages without the weight sensor are ignored
+	s.Add(models.ESP32SensorData{GreenhouseID: "GH1", NodeID: "Node02", Values: map[string]float64{"Air_Temp": 21}}, irrigationTestStart.Add(time.Minute))
+	s.Add(models.ESP32SensorData{GreenhouseID: "GH1", NodeID: "Node02", Values: map[string]float64{"Drip_Weight": 600}}, irrigationTestStart.Add(40*time.Second))
+
+	events := s.Flush(irrigationTestStart.Add(time.Hour))
+	if len(events) != 2 || events[0].NodeID != "Node01" || events[0].Supply != 200 ||
+		events[1].NodeID != "Node02" || events[1].Supply != 100 {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestDailyIrrigation(t *testing.T) {
+	location := time.FixedZone("IST", 5*3600+1800)
+	events := []models.IrrigationEvent{
+		{GreenhouseID: "GH1", Start: time.Date(2024, 6, 1, 20, 0, 0, 0, time.UTC), Supply: 100, Drain: 20},    // 2 June local
+		{GreenhouseID: "GH1", Start: time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC), Supply: 200, Drain: 30},    // 1 June local
+		{GreenhouseID: "GH1", Start: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), Supply: 100.05, Drain: 10}, // 1 June local
+		{GreenhouseID: "GH2", Start: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), Supply: 50, Drain: 0},
+	}
+	want := []models.IrrigationDaily{
+		{GreenhouseID: "GH1", Date: "2024-06-02", Events: 1, Supply: 100, Drain: 20, DrainRatio: 0.2},
+		{GreenhouseID: "GH1", Date: "2024-06-01", Events: 2, Supply: 300.1, Drain: 40, DrainRatio: 0.133},
+		{GreenhouseID: "GH2", Date: "2024-06-01", Events: 1, Supply: 50, Drain: 0, DrainRatio: 0},
+	}
+	got := DailyIrrigation(events, location)
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for n := range want {
+		if got[n] != want[n] {
+			t.Errorf("day %d = %+v, want %+v", n, got[n], want[n])
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// dliPrecision is the number of decimals DLI values are reported with
+const dliPrecision = 3
+
+// dayLayout formats a local day
+const dayLayout = "2006-01-02"
+
+// lightState is the running light integral of one node for one local day
+type lightState struct {
+	GreenhouseID string    `json:"greenhouse_id"`
+	NodeID       string    `json:"node_id"`
+	Date         string    `json:"date"`
+	Total        float64   `json:"total"` // µmol/m² integrated so far
+	Readings     int       `json:"readings"`
+	LastTime     time.Time `json:"last_time"`
+	LastValue    float64   `json:"last_value"`
+}
+
+// lightStateFile is the JSON layout of the DLI state file
+type lightStateFile struct {
+	States    []lightState                `json:"states"`
+	Completed []models.DailyLightIntegral `json:"completed,omitempty"` // Finished days not yet written to InfluxDB
+}
+
+// LightService integrates PAR readings into a daily light integral (DLI) per
+// greenhouse/node. Readings are integrated with the trapezoidal rule between
+// consecutive event times, and each node's total restarts at local midnight.
+// A segment spanning midnight is split between the two days
+type LightService struct {
+	mu        sync.Mutex
+	cfg       *config.LightConfig
+	states    map[string]*lightState // key: greenhouse_id|node_id
+	completed []models.DailyLightIntegral
+	dirty     bool
+	metrics   *MetricsService
+}
+
+// NewLightService creates a new light service, restoring the running totals from the state file
+func NewLightService(cfg *config.LightConfig, metrics *MetricsService) *LightService {
+	l := &LightService{
+		cfg:     cfg,
+		states:  make(map[string]*lightState),
+		metrics: metrics,
+	}
+	if cfg.Sensor == "" || cfg.StateFile == "" {
+		return l
+	}
+
+	data, err := os.ReadFile(cfg.StateFile)
+	if os.IsNotExist(err) {
+		return l
+	}
+	var file lightStateFile
+	if err == nil {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		log.Printf("Warning: DLI state %s not loaded, starting from zero: %v", cfg.StateFile, err)
+		return l
+	}
+	for i := range file.States {
+		state := file.States[i]
+		l.states[state.GreenhouseID+"|"+state.NodeID] = &state
+		l.metrics.SetDLI(state.GreenhouseID, state.NodeID, l.dli(&state))
+	}
+	l.completed = file.Completed
+	log.Printf("Restored DLI totals of %d nodes from %s", len(l.states), cfg.StateFile)
+	return l
+}
+
+// Enabled returns true if a PAR sensor is configured
+func (l *LightService) Enabled() bool {
+	return l.cfg.Sensor != ""
+}
+
+// Target returns the DLI goal in mol/m²/day (0 = no target)
+func (l *LightService) Target() float64 {
+	return l.cfg.Target
+}
+
+// Add integrates the PAR reading of a message with the given event time
+// Readings older than the node's last reading are ignored
+func (l *LightService) Add(data models.ESP32SensorData, eventTime time.Time) {
+	if !l.Enabled() {
+		return
+	}
+	par, ok := data.Values[l.cfg.Sensor]
+	if !ok {
+		return
+	}
+	par = max(par, 0)
+	day := eventTime.In(l.cfg.Location).Format(dayLayout)
+	key := data.GreenhouseID + "|" + data.NodeID
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state, ok := l.states[key]
+	if ok && !eventTime.After(state.LastTime) {
+		return
+	}
+	carried := 0.0
+	if ok && state.Date != day {
+		carried = l.splitAtMidnight(state, day, eventTime, par)
+		l.complete(state)
+		ok = false
+	}
+	if !ok {
+		if !l.dayEnd(day).After(time.Now()) {
+			return // The day was already completed
+		}
+		state = &lightState{GreenhouseID: data.GreenhouseID, NodeID: data.NodeID, Date: day, Total: carried}
+		l.states[key] = state
+	} else if gap := eventTime.Sub(state.LastTime); gap <= l.cfg.MaxGap {
+		state.Total += (state.LastValue + par) / 2 * gap.Seconds()
+	}
+	state.Readings++
+	state.LastTime = eventTime
+	state.LastValue = par
+	l.dirty = true
+	l.metrics.SetDLI(state.GreenhouseID, state.NodeID, l.dli(state))
+}
+
+// Rollover completes every day that ended at least LIGHT_MAX_GAP ago, leaving time
+// for the first reading of the next day to split the segment across midnight, and
+// returns the completed days not yet acknowledged, including those completed by Add.
+// Completed days stay in the state file until they are passed to Ack, so a crash
+// before they are recorded does not lose them. The running totals are saved to
+// the state file when they changed
+func (l *LightService) Rollover(now time.Time) []models.DailyLightIntegral {
+	if !l.Enabled() {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, state := range l.states {
+		if !l.dayEnd(state.Date).Add(l.cfg.MaxGap).After(now) {
+			l.complete(state)
+		}
+	}
+	if l.dirty {
+		l.save()
+	}
+	return append([]models.DailyLightIntegral(nil), l.completed...)
+}
+
+// Ack removes completed days returned by Rollover once they have been recorded
+func (l *LightService) Ack(days []models.DailyLightIntegral) {
+	if len(days) == 0 {
+		return
+	}
+	recorded := make(map[string]bool, len(days))
+	for _, day := range days {
+		recorded[day.GreenhouseID+"|"+day.NodeID+"|"+day.Date] = true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pending := l.completed[:0]
+	for _, day := range l.completed {
+		if !recorded[day.GreenhouseID+"|"+day.NodeID+"|"+day.Date] {
+			pending = append(pending, day)
+		}
+	}
+	l.completed = pending
+	l.dirty = true
+	l.save()
+}
+
+// Today returns the running DLI of each node's current day, optionally filtered
+func (l *LightService) Today(greenhouseID, nodeID string) []models.DailyLightIntegral {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	today := time.Now().In(l.cfg.Location).Format(dayLayout)
+	out := make([]models.DailyLightIntegral, 0, len(l.states))
+	for _, state := range l.states {
+		if state.Date != today ||
+			(greenhouseID != "" && state.GreenhouseID != greenhouseID) ||
+			(nodeID != "" && state.NodeID != nodeID) {
+			continue
+		}
+		out = append(out, l.result(state, false))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].GreenhouseID != out[j].GreenhouseID {
+			return out[i].GreenhouseID < out[j].GreenhouseID
+		}
+		return out[i].NodeID < out[j].NodeID
+	})
+	return out
+}
+
+// Close saves the running totals
+func (l *LightService) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.dirty {
+		l.save()
+	}
+}
+
+// splitAtMidnight integrates the part of the segment from a node's last reading to
+// the reading at eventTime that falls before midnight into the node's day, and
+// returns the light of the part after midnight, which belongs to the next day.
+// The value at midnight is interpolated linearly. Gaps longer than LIGHT_MAX_GAP,
+// or spanning more than one midnight, add no light
+func (l *LightService) splitAtMidnight(state *lightState, day string, eventTime time.Time, par float64) float64 {
+	midnight := l.dayEnd(state.Date)
+	gap := eventTime.Sub(state.LastTime)
+	if gap > l.cfg.MaxGap || midnight.In(l.cfg.Location).Format(dayLayout) != day {
+		return 0
+	}
+	before := midnight.Sub(state.LastTime)
+	atMidnight := state.LastValue + (par-state.LastValue)*before.Seconds()/gap.Seconds()
+	state.Total += (state.LastValue + atMidnight) / 2 * before.Seconds()
+	return (atMidnight + par) / 2 * eventTime.Sub(midnight).Seconds()
+}
+
+// complete moves a node's day to the completed list and drops its state
+func (l *LightService) complete(state *lightState) {
+	l.completed = append(l.completed, l.result(state, true))
+	delete(l.states, state.GreenhouseID+"|"+state.NodeID)
+	l.metrics.SetDLI(state.GreenhouseID, state.NodeID, 0)
+	l.dirty = true
+}
+
+// result converts a node's state to a DLI
+func (l *LightService) result(state *lightState, complete bool) models.DailyLightIntegral {
+	dli := l.dli(state)
+	day := models.DailyLightIntegral{
+		GreenhouseID: state.GreenhouseID,
+		NodeID:       state.NodeID,
+		Date:         state.Date,
+		DLI:          dli,
+		Readings:     state.Readings,
+		Complete:     complete,
+		Target:       l.cfg.Target,
+		BelowTarget:  l.cfg.Target > 0 && dli < l.cfg.Target,
+	}
+	if !complete {
+		last := state.LastTime
+		day.LastReading = &last
+	}
+	return day
+}
+
+// dli converts a node's integrated µmol/m² to mol/m²
+func (l *LightService) dli(state *lightState) float64 {
+	precision := dliPrecision
+	return roundTo(state.Total/1e6, &precision)
+}
+
+// dayEnd returns the local midnight ending a day
+func (l *LightService) dayEnd(day string) time.Time {
+	start, err := time.ParseInLocation(dayLayout, day, l.cfg.Location)
+	if err != nil {
+		return time.Time{}
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// save writes the running totals and pending completed days to the state file
+func (l *LightService) save() {
+	if l.cfg.StateFile == "" {
+		l.dirty = false
+		return
+	}
+	file := lightStateFile{States: make([]lightState, 0, len(l.states)), Completed: l.completed}
+	for _, state := range l.states {
+		file.States = append(file.States, *state)
+	}
+	if err := writeJSONFile(l.cfg.StateFile, file); err != nil {
+		log.Printf("Warning: Failed to save DLI state: %v", err)
+		return
+	}
+	l.dirty = false
+}
+
+// describeDLI formats a completed day for the console, e.g. "GH1/Node01 2024-06-01: 14.2 mol/m² (below target 17)"
+func describeDLI(day models.DailyLightIntegral) string {
+	s := fmt.Sprintf("%s/%s %s: %s mol/m²", day.GreenhouseID, day.NodeID, day.Date, formatNumber(day.DLI))
+	if day.BelowTarget {
+		s += fmt.Sprintf(" (below target %s)", formatNumber(day.Target))
+	}
+	return s
+}
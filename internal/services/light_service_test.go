@@ -0,0 +1,118 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// lightTestLocation is a non-UTC zone whose midnight starts a DLI day
+var lightTestLocation = time.FixedZone("IST", 5*3600+1800)
+
+// lightTestDay is a future local day, so Add does not treat it as already completed
+var lightTestDay = time.Date(2100, 6, 1, 0, 0, 0, 0, lightTestLocation)
+
+func lightTestService(stateFile string) *LightService {
+	return NewLightService(&config.LightConfig{
+		Sensor:    "Light_Par",
+		Location:  lightTestLocation,
+		StateFile: stateFile,
+		MaxGap:    5 * time.Minute,
+		Target:    17,
+	}, sharedTestMetrics())
+}
+
+// addPar integrates one PAR reading of GH1/Node01
+func addPar(l *LightService, at time.Time, par float64) {
+	l.Add(models.ESP32SensorData{GreenhouseID: "GH1", NodeID: "Node01", Values: map[string]float64{"Light_Par": par}}, at)
+}
+
+func TestLightIntegratesReadings(t *testing.T) {
+	l := lightTestService("")
+
+	// 1000 µmol/m²/s for an hour with a reading every minute: 3.6 mol/m²
+	start := lightTestDay.Add(10 * time.Hour)
+	for m := 0; m <= 60; m++ {
+		addPar(l, start.Add(time.Duration(m)*time.Minute), 1000)
+	}
+	// A reading after a gap longer than LIGHT_MAX_GAP adds no light
+	addPar(l, start.Add(2*time.Hour), 1000)
+	// Older readings are ignored
+	addPar(l, start.Add(30*time.Minute), 5000)
+
+	days := l.Rollover(lightTestDay.AddDate(0, 0, 1).Add(5 * time.Minute))
+	if len(days) != 1 {
+		t.Fatalf("expected 1 completed day, got %+v", days)
+	}
+	day := days[0]
+	if day.Date != "2100-06-01" || day.DLI != 3.6 || day.Readings != 62 || !day.Complete || !day.BelowTarget {
+		t.Errorf("unexpected day %+v", day)
+	}
+}
+
+func TestLightSplitsSegmentAtMidnight(t *testing.T) {
+	l := lightTestService("")
+	midnight := lightTestDay.AddDate(0, 0, 1)
+
+	// PAR ramps from 0 to 1200 over the two minutes around midnight, so it is 600
+	// at midnight: 18000 µmol/m² belong to the first day and 54000 to the next
+	addPar(l, midnight.Add(-time.Minute), 0)
+	addPar(l, midnight.Add(time.Minute), 1200)
+
+	days := l.Rollover(midnight.Add(2 * time.Minute))
+	if len(days) != 1 || days[0].Date != "2100-06-01" || days[0].DLI != 0.018 {
+		t.Fatalf("expected the first day with 0.018 mol/m², got %+v", days)
+	}
+	l.Ack(days)
+
+	days = l.Rollover(midnight.AddDate(0, 0, 1).Add(5 * time.Minute))
+	if len(days) != 1 || days[0].Date != "2100-06-02" || days[0].DLI != 0.054 {
+		t.Errorf("expected the next day with 0.054 mol/m², got %+v", days)
+	}
+}
+
+func TestLightRolloverWaitsForMaxGap(t *testing.T) {
+	l := lightTestService("")
+	midnight := lightTestDay.AddDate(0, 0, 1)
+	addPar(l, midnight.Add(-time.Minute), 500)
+
+	if days := l.Rollover(midnight.Add(time.Minute)); len(days) != 0 {
+		t.Errorf("day completed before the next reading could arrive: %+v", days)
+	}
+	if days := l.Rollover(midnight.Add(5 * time.Minute)); len(days) != 1 {
+		t.Errorf("expected the day to complete LIGHT_MAX_GAP after midnight, got %+v", days)
+	}
+}
+
+func TestLightKeepsCompletedDaysUntilAck(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "dli_state.json")
+	l := lightTestService(stateFile)
+	start := lightTestDay.Add(12 * time.Hour)
+	addPar(l, start, 800)
+	addPar(l, start.Add(time.Minute), 800)
+
+	after := lightTestDay.AddDate(0, 0, 1).Add(time.Hour)
+	days := l.Rollover(after)
+	if len(days) != 1 || days[0].DLI != 0.048 {
+		t.Fatalf("expected one day of 0.048 mol/m², got %+v", days)
+	}
+	if again := l.Rollover(after); len(again) != 1 {
+		t.Errorf("unacknowledged day not returned again: %+v", again)
+	}
+
+	// A crash before the day is recorded keeps it in the state file
+	restarted := lightTestService(stateFile)
+	if pending := restarted.Rollover(after); len(pending) != 1 || pending[0] != days[0] {
+		t.Fatalf("completed day lost across a restart: %+v", pending)
+	}
+	restarted.Ack(days)
+	if pending := restarted.Rollover(after); len(pending) != 0 {
+		t.Errorf("acknowledged day returned again: %+v", pending)
+	}
+	if pending := lightTestService(stateFile).Rollover(after); len(pending) != 0 {
+		t.Errorf("acknowledged day still in the state file: %+v", pending)
+	}
+}
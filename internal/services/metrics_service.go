@@ -2,6 +2,7 @@ package services
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -14,16 +15,36 @@ type MetricsService struct {
 	mqttMessagesReceived  prometheus.Counter
 	mqttConnectionStatus  prometheus.Gauge
 	mqttReconnectionCount prometheus.Counter
+	mqttIdentityMismatch  *prometheus.CounterVec
 
 	// Sensor metrics
 	sensorReadingsProcessed  prometheus.Counter
 	sensorAveragesCalculated prometheus.Counter
 	sensorZeroValueCount     prometheus.Counter
+	sensorLateReadings       prometheus.Counter
+	sensorRejectedMessages   *prometheus.CounterVec
+	deadLetters              prometheus.Gauge
+	nodeLastSeen             *prometheus.GaugeVec
+	sensorDLI                *prometheus.GaugeVec
+	irrigationEvents         *prometheus.CounterVec
+	rainEvents               *prometheus.CounterVec
+	raining                  *prometheus.GaugeVec
+
+	// Ingest metrics
+	ingestQueueDepth *prometheus.GaugeVec
+	ingestDropped    *prometheus.CounterVec
+	ingestLatency    *prometheus.HistogramVec
 
 	// InfluxDB metrics
 	influxDBWritesTotal      prometheus.Counter
 	influxDBWriteErrors      prometheus.Counter
 	influxDBConnectionStatus prometheus.Gauge
+	influxDBWALPoints        prometheus.Gauge
+	influxDBWALBytes         prometheus.Gauge
+	influxDBWALOldestAge     prometheus.Gauge
+
+	// Alert metrics
+	alertNotificationsTotal *prometheus.CounterVec
 
 	// API metrics
 	apiRequestsTotal   *prometheus.CounterVec
@@ -56,6 +77,14 @@ func NewMetricsService() *MetricsService {
 		Help: "Total number of MQTT reconnections",
 	})
 
+	ms.mqttIdentityMismatch = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mqtt_identity_mismatches_total",
+			Help: "Total number of data messages whose payload IDs differ from their topic's, by topic IDs and action",
+		},
+		[]string{"greenhouse_id", "node_id", "action"},
+	)
+
 	// Initialize sensor metrics
 	ms.sensorReadingsProcessed = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "sensor_readings_processed_total",
@@ -72,6 +101,90 @@ func NewMetricsService() *MetricsService {
 		Help: "Total number of zero values received from sensors",
 	})
 
+	ms.sensorLateReadings = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sensor_late_readings_total",
+		Help: "Total number of sensor readings dropped because their window was already closed",
+	})
+
+	ms.sensorRejectedMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sensor_messages_rejected_total",
+			Help: "Total number of data messages rejected by validation, by reason",
+		},
+		[]string{"reason"},
+	)
+
+	ms.deadLetters = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ingest_dead_letters",
+		Help: "Number of rejected data messages held in the dead-letter store",
+	})
+
+	ms.nodeLastSeen = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "node_last_seen_seconds",
+			Help: "Unix time of the last message received from each node",
+		},
+		[]string{"greenhouse_id", "node_id"},
+	)
+
+	ms.sensorDLI = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sensor_dli_mol_m2",
+			Help: "Daily light integral accumulated by each node so far today (mol/m²)",
+		},
+		[]string{"greenhouse_id", "node_id"},
+	)
+
+	ms.irrigationEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "irrigation_events_total",
+			Help: "Total number of irrigations detected from substrate weight, by node",
+		},
+		[]string{"greenhouse_id", "node_id"},
+	)
+
+	ms.rainEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rain_events_total",
+			Help: "Total number of completed rain events, by node",
+		},
+		[]string{"greenhouse_id", "node_id"},
+	)
+
+	ms.raining = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rain_active",
+			Help: "Whether each node currently reports rain (1 = raining)",
+		},
+		[]string{"greenhouse_id", "node_id"},
+	)
+
+	// Initialize ingest metrics
+	ms.ingestQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ingest_queue_depth",
+			Help: "Number of data messages waiting in each ingest worker's queue",
+		},
+		[]string{"worker"},
+	)
+
+	ms.ingestDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ingest_dropped_messages_total",
+			Help: "Total number of data messages dropped before processing, by reason (queue_full, evicted, timeout, shutdown)",
+		},
+		[]string{"reason"},
+	)
+
+	ms.ingestLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ingest_message_latency_seconds",
+			Help:    "Data message latency in seconds, by stage (queue wait, processing)",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		},
+		[]string{"stage"},
+	)
+
 	// Initialize InfluxDB metrics
 	ms.influxDBWritesTotal = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "influxdb_writes_total",
@@ -88,6 +201,30 @@ func NewMetricsService() *MetricsService {
 		Help: "InfluxDB connection status (1 = connected, 0 = disconnected)",
 	})
 
+	ms.influxDBWALPoints = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "influxdb_wal_queue_points",
+		Help: "Number of points queued on disk waiting to be replayed to InfluxDB",
+	})
+
+	ms.influxDBWALBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "influxdb_wal_queue_bytes",
+		Help: "Size in bytes of the InfluxDB on-disk write queue",
+	})
+
+	ms.influxDBWALOldestAge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "influxdb_wal_oldest_point_age_seconds",
+		Help: "Age of the oldest point in the InfluxDB on-disk write queue (0 = empty)",
+	})
+
+	// Initialize alert metrics
+	ms.alertNotificationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alert_notifications_total",
+			Help: "Total number of alert notifications by channel and result (sent, failed, suppressed, dropped)",
+		},
+		[]string{"channel", "result"},
+	)
+
 	// Initialize API metrics
 	ms.apiRequestsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -117,12 +254,28 @@ func NewMetricsService() *MetricsService {
 		ms.mqttMessagesReceived,
 		ms.mqttConnectionStatus,
 		ms.mqttReconnectionCount,
+		ms.mqttIdentityMismatch,
 		ms.sensorReadingsProcessed,
 		ms.sensorAveragesCalculated,
 		ms.sensorZeroValueCount,
+		ms.sensorLateReadings,
+		ms.sensorRejectedMessages,
+		ms.deadLetters,
+		ms.nodeLastSeen,
+		ms.sensorDLI,
+		ms.irrigationEvents,
+		ms.rainEvents,
+		ms.raining,
+		ms.ingestQueueDepth,
+		ms.ingestDropped,
+		ms.ingestLatency,
 		ms.influxDBWritesTotal,
 		ms.influxDBWriteErrors,
 		ms.influxDBConnectionStatus,
+		ms.influxDBWALPoints,
+		ms.influxDBWALBytes,
+		ms.influxDBWALOldestAge,
+		ms.alertNotificationsTotal,
 		ms.apiRequestsTotal,
 		ms.apiRequestDuration,
 		ms.uptime,
@@ -161,6 +314,10 @@ func (ms *MetricsService) IncrementMQTTReconnections() {
 	ms.mqttReconnectionCount.Inc()
 }
 
+func (ms *MetricsService) IncrementIdentityMismatches(greenhouseID, nodeID, action string) {
+	ms.mqttIdentityMismatch.WithLabelValues(greenhouseID, nodeID, action).Inc()
+}
+
 // Sensor Metrics
 func (ms *MetricsService) IncrementSensorReadings() {
 	ms.sensorReadingsProcessed.Inc()
@@ -174,6 +331,55 @@ func (ms *MetricsService) IncrementSensorZeroValues(count int) {
 	ms.sensorZeroValueCount.Add(float64(count))
 }
 
+func (ms *MetricsService) IncrementSensorLateReadings() {
+	ms.sensorLateReadings.Inc()
+}
+
+func (ms *MetricsService) IncrementSensorRejections(reason string) {
+	ms.sensorRejectedMessages.WithLabelValues(reason).Inc()
+}
+
+func (ms *MetricsService) SetDeadLetters(count int) {
+	ms.deadLetters.Set(float64(count))
+}
+
+func (ms *MetricsService) SetNodeLastSeen(greenhouseID, nodeID string, at time.Time) {
+	ms.nodeLastSeen.WithLabelValues(greenhouseID, nodeID).Set(float64(at.UnixNano()) / 1e9)
+}
+
+func (ms *MetricsService) SetDLI(greenhouseID, nodeID string, dli float64) {
+	ms.sensorDLI.WithLabelValues(greenhouseID, nodeID).Set(dli)
+}
+
+func (ms *MetricsService) IncrementIrrigationEvents(greenhouseID, nodeID string) {
+	ms.irrigationEvents.WithLabelValues(greenhouseID, nodeID).Inc()
+}
+
+func (ms *MetricsService) IncrementRainEvents(greenhouseID, nodeID string) {
+	ms.rainEvents.WithLabelValues(greenhouseID, nodeID).Inc()
+}
+
+func (ms *MetricsService) SetRaining(greenhouseID, nodeID string, raining bool) {
+	value := 0.0
+	if raining {
+		value = 1
+	}
+	ms.raining.WithLabelValues(greenhouseID, nodeID).Set(value)
+}
+
+// Ingest Metrics
+func (ms *MetricsService) SetIngestQueueDepth(worker, depth int) {
+	ms.ingestQueueDepth.WithLabelValues(strconv.Itoa(worker)).Set(float64(depth))
+}
+
+func (ms *MetricsService) IncrementIngestDropped(reason string) {
+	ms.ingestDropped.WithLabelValues(reason).Inc()
+}
+
+func (ms *MetricsService) ObserveIngestLatency(stage string, d time.Duration) {
+	ms.ingestLatency.WithLabelValues(stage).Observe(d.Seconds())
+}
+
 // InfluxDB Metrics
 func (ms *MetricsService) IncrementInfluxDBWrites() {
 	ms.influxDBWritesTotal.Inc()
@@ -191,6 +397,17 @@ func (ms *MetricsService) SetInfluxDBConnectionStatus(connected bool) {
 	}
 }
 
+func (ms *MetricsService) SetInfluxDBWALStats(stats WALStats) {
+	ms.influxDBWALPoints.Set(float64(stats.Points))
+	ms.influxDBWALBytes.Set(float64(stats.Bytes))
+	ms.influxDBWALOldestAge.Set(stats.OldestAge.Seconds())
+}
+
+// Alert Metrics
+func (ms *MetricsService) IncrementAlertNotifications(channel, result string) {
+	ms.alertNotificationsTotal.WithLabelValues(channel, result).Inc()
+}
+
 // API Metrics
 func (ms *MetricsService) RecordAPIRequest(method, endpoint, status string, duration time.Duration) {
 	ms.apiRequestsTotal.WithLabelValues(method, endpoint, status).Inc()
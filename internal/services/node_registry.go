@@ -0,0 +1,251 @@
+package services
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// intervalSmoothing is the weight of the newest gap in the learned publish interval
+const intervalSmoothing = 0.2
+
+// nodeState holds what is known about one node's publishing
+type nodeState struct {
+	greenhouseID string
+	nodeID       string
+	firstSeen    time.Time
+	lastSeen     time.Time
+	messages     int64
+	interval     time.Duration // Smoothed gap between messages (0 until the second message)
+	status       string        // Last status reported by Check, for transition logging
+	connection   string        // Last online/offline message on the node's status topic
+	connectionAt time.Time
+}
+
+// NodeRegistry tracks when each greenhouse/node last published and flags nodes
+// that have gone quiet
+type NodeRegistry struct {
+	mu       sync.RWMutex
+	nodes    map[string]*nodeState // key: greenhouse_id|node_id
+	devices  map[string]*models.DeviceStatus
+	config   *config.NodesConfig
+	registry *SensorRegistry
+	metrics  *MetricsService
+}
+
+// NewNodeRegistry creates a new node registry
+func NewNodeRegistry(cfg *config.NodesConfig, registry *SensorRegistry, metrics *MetricsService) *NodeRegistry {
+	return &NodeRegistry{
+		nodes:    make(map[string]*nodeState),
+		devices:  make(map[string]*models.DeviceStatus),
+		config:   cfg,
+		registry: registry,
+		metrics:  metrics,
+	}
+}
+
+// Observe records a message from a node received at the given time
+func (n *NodeRegistry) Observe(greenhouseID, nodeID string, at time.Time) {
+	key := greenhouseID + "|" + nodeID
+	n.mu.Lock()
+	node, ok := n.nodes[key]
+	if !ok {
+		node = &nodeState{
+			greenhouseID: greenhouseID,
+			nodeID:       nodeID,
+			firstSeen:    at,
+			status:       models.NodeOnline,
+		}
+		n.nodes[key] = node
+	} else if gap := at.Sub(node.lastSeen); gap > 0 && node.messages > 0 {
+		if node.interval == 0 {
+			node.interval = gap
+		} else {
+			node.interval = time.Duration(intervalSmoothing*float64(gap) + (1-intervalSmoothing)*float64(node.interval))
+		}
+	}
+	node.lastSeen = at
+	node.messages++
+	recovered := node.status != models.NodeOnline
+	previous := node.status
+	node.status = models.NodeOnline
+	n.mu.Unlock()
+
+	if recovered {
+		log.Printf("Node %s/%s is back online (was %s)", greenhouseID, nodeID, previous)
+	}
+	n.metrics.SetNodeLastSeen(greenhouseID, nodeID, at)
+}
+
+// SetConnection records an online/offline message from a node's status topic.
+// An offline message marks the node offline until it publishes again; an online
+// message counts as a sign of life. Returns true if the connection state changed
+func (n *NodeRegistry) SetConnection(greenhouseID, nodeID, connection string, at time.Time) bool {
+	key := greenhouseID + "|" + nodeID
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	node, ok := n.nodes[key]
+	if !ok {
+		node = &nodeState{
+			greenhouseID: greenhouseID,
+			nodeID:       nodeID,
+			firstSeen:    at,
+			lastSeen:     at,
+			status:       models.NodeOnline,
+		}
+		n.nodes[key] = node
+	}
+	if connection == models.ConnectionOnline && at.After(node.lastSeen) {
+		node.lastSeen = at
+		n.metrics.SetNodeLastSeen(greenhouseID, nodeID, at)
+	}
+	changed := node.connection != connection
+	node.connection = connection
+	node.connectionAt = at
+
+	if status := n.status(node, at); status != node.status {
+		log.Printf("Node %s/%s reported %s on its status topic", greenhouseID, nodeID, connection)
+		node.status = status
+	}
+	return changed
+}
+
+// SetDeviceConnection records an online/offline message from a device status topic
+// such as simulator/status. Returns true if the device's state changed
+func (n *NodeRegistry) SetDeviceConnection(device, connection string, at time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	current, ok := n.devices[device]
+	if ok && current.Status == connection {
+		return false
+	}
+	n.devices[device] = &models.DeviceStatus{Device: device, Status: connection, ChangedAt: at}
+	log.Printf("Device %s is %s", device, connection)
+	return true
+}
+
+// Devices returns the last reported status of every device, ordered by name
+func (n *NodeRegistry) Devices() []models.DeviceStatus {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	devices := make([]models.DeviceStatus, 0, len(n.devices))
+	for _, device := range n.devices {
+		devices = append(devices, *device)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Device < devices[j].Device })
+	return devices
+}
+
+// Check re-evaluates every node and logs nodes that became stale or offline
+func (n *NodeRegistry) Check(now time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, node := range n.nodes {
+		status := n.status(node, now)
+		if status != node.status {
+			log.Printf("Warning: Node %s/%s is %s (last seen %v ago)",
+				node.greenhouseID, node.nodeID, status, now.Sub(node.lastSeen).Round(time.Second))
+			node.status = status
+		}
+	}
+}
+
+// Nodes returns the status of every node seen since startup, ordered by greenhouse and node
+func (n *NodeRegistry) Nodes(now time.Time) []models.NodeStatus {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	nodes := make([]models.NodeStatus, 0, len(n.nodes))
+	for _, node := range n.nodes {
+		nodes = append(nodes, n.snapshot(node, now))
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].GreenhouseID != nodes[j].GreenhouseID {
+			return nodes[i].GreenhouseID < nodes[j].GreenhouseID
+		}
+		return nodes[i].NodeID < nodes[j].NodeID
+	})
+	return nodes
+}
+
+// Node returns the status of one node, or false if it has not been seen
+func (n *NodeRegistry) Node(greenhouseID, nodeID string, now time.Time) (models.NodeStatus, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	node, ok := n.nodes[greenhouseID+"|"+nodeID]
+	if !ok {
+		return models.NodeStatus{}, false
+	}
+	return n.snapshot(node, now), true
+}
+
+// snapshot builds the API view of a node. Must be called with mu held
+func (n *NodeRegistry) snapshot(node *nodeState, now time.Time) models.NodeStatus {
+	expected := n.expectedInterval(node)
+	stale, offline := n.thresholds(expected)
+	status := models.NodeStatus{
+		GreenhouseID:     node.greenhouseID,
+		NodeID:           node.nodeID,
+		NodeType:         n.registry.NodeType(node.nodeID),
+		Status:           n.status(node, now),
+		FirstSeen:        node.firstSeen,
+		LastSeen:         node.lastSeen,
+		SecondsSinceSeen: now.Sub(node.lastSeen).Seconds(),
+		Messages:         node.messages,
+		ExpectedInterval: expected.Seconds(),
+		StaleAfter:       stale.Seconds(),
+		OfflineAfter:     offline.Seconds(),
+	}
+	if node.connection != "" {
+		connectionAt := node.connectionAt
+		status.Connection = node.connection
+		status.ConnectionAt = &connectionAt
+	}
+	if node.interval > 0 {
+		status.MessageRate = float64(time.Minute) / float64(node.interval)
+	}
+	return status
+}
+
+// status returns online, stale or offline from the time since the node was last seen.
+// A node whose status topic reported offline stays offline until it publishes again
+func (n *NodeRegistry) status(node *nodeState, now time.Time) string {
+	if node.connection == models.ConnectionOffline && !node.lastSeen.After(node.connectionAt) {
+		return models.NodeOffline
+	}
+	stale, offline := n.thresholds(n.expectedInterval(node))
+	silence := now.Sub(node.lastSeen)
+	switch {
+	case silence >= offline:
+		return models.NodeOffline
+	case silence >= stale:
+		return models.NodeStale
+	default:
+		return models.NodeOnline
+	}
+}
+
+// expectedInterval returns the configured publish interval, or the node's learned one
+func (n *NodeRegistry) expectedInterval(node *nodeState) time.Duration {
+	if n.config.ExpectedInterval > 0 {
+		return n.config.ExpectedInterval
+	}
+	return node.interval
+}
+
+// thresholds returns the stale and offline gaps, raised for nodes that publish
+// slowly so they are not flagged between two regular messages
+func (n *NodeRegistry) thresholds(expected time.Duration) (time.Duration, time.Duration) {
+	stale, offline := n.config.StaleAfter, n.config.OfflineAfter
+	if stale < 3*expected {
+		stale = 3 * expected
+	}
+	if offline < 10*expected {
+		offline = 10 * expected
+	}
+	return stale, offline
+}
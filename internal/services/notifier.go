@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// notificationQueueSize bounds the alerts waiting for delivery on each channel
+const notificationQueueSize = 100
+
+// Notifier delivers alert state changes to one destination
+type Notifier interface {
+	Notify(ctx context.Context, alert models.Alert) error
+}
+
+// AlertNotification is the JSON document sent to webhooks and published over MQTT
+type AlertNotification struct {
+	Event  string       `json:"event"` // alert.firing or alert.resolved
+	Alert  models.Alert `json:"alert"`
+	SentAt time.Time    `json:"sent_at"`
+}
+
+// newAlertNotification wraps an alert for delivery
+func newAlertNotification(alert models.Alert) AlertNotification {
+	return AlertNotification{
+		Event:  "alert." + alert.State,
+		Alert:  alert,
+		SentAt: time.Now().UTC(),
+	}
+}
+
+// alertSubject returns a one-line summary, e.g. "[CRITICAL] firing: Air_Temp > 35 on GH1/Node01"
+func alertSubject(alert models.Alert) string {
+	return fmt.Sprintf("[%s] %s: %s on %s/%s", strings.ToUpper(alert.Severity), alert.State,
+		alert.RuleName, alert.GreenhouseID, alert.NodeID)
+}
+
+// notificationChannel routes alerts to a notifier, applying the channel's
+// filters, dedup window and rate limit, and delivers them from its own goroutine
+// so a slow destination does not hold up the others
+type notificationChannel struct {
+	cfg      config.NotificationChannelConfig
+	notifier Notifier
+	queue    chan models.Alert
+	done     chan struct{}
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time // dedup key -> time the notification was accepted
+	sent     []time.Time          // Accepted notifications within the rate window
+}
+
+// NotificationService fans alert state changes out to the configured channels
+type NotificationService struct {
+	channels []*notificationChannel
+	mqtt     []*MQTTNotifier
+	metrics  *MetricsService
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewNotificationService creates a notification service with a delivery goroutine per channel
+func NewNotificationService(cfg *config.NotificationsConfig, metrics *MetricsService) *NotificationService {
+	n := &NotificationService{metrics: metrics}
+	for _, chCfg := range cfg.Channels {
+		var notifier Notifier
+		switch chCfg.Type {
+		case config.ChannelWebhook:
+			notifier = NewWebhookNotifier(chCfg.Webhook)
+		case config.ChannelSMTP:
+			notifier = NewSMTPNotifier(chCfg.SMTP)
+		case config.ChannelMQTT:
+			mqttNotifier := NewMQTTNotifier(chCfg.MQTT)
+			n.mqtt = append(n.mqtt, mqttNotifier)
+			notifier = mqttNotifier
+		}
+
+		ch := &notificationChannel{
+			cfg:      chCfg,
+			notifier: notifier,
+			queue:    make(chan models.Alert, notificationQueueSize),
+			done:     make(chan struct{}),
+			lastSent: make(map[string]time.Time),
+		}
+		n.channels = append(n.channels, ch)
+		go n.deliver(ch)
+		log.Printf("Alert notifications enabled: %s (%s)", chCfg.Name, chCfg.Type)
+	}
+	return n
+}
+
+// SetPublisher connects the MQTT notification channels to the broker
+func (n *NotificationService) SetPublisher(publisher Publisher) {
+	for _, notifier := range n.mqtt {
+		notifier.SetPublisher(publisher)
+	}
+}
+
+// Notify queues alert state changes for every channel that routes them
+func (n *NotificationService) Notify(alerts []models.Alert) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		return
+	}
+
+	now := time.Now()
+	for _, alert := range alerts {
+		for _, ch := range n.channels {
+			if !ch.routes(alert) {
+				continue
+			}
+			if !ch.allow(alert, now) {
+				n.metrics.IncrementAlertNotifications(ch.cfg.Name, "suppressed")
+				continue
+			}
+			select {
+			case ch.queue <- alert:
+			default:
+				log.Printf("Warning: Notification queue of channel %s full, dropping alert %s", ch.cfg.Name, alert.ID)
+				n.metrics.IncrementAlertNotifications(ch.cfg.Name, "dropped")
+			}
+		}
+	}
+}
+
+// deliver sends queued alerts of one channel until the queue is closed
+func (n *NotificationService) deliver(ch *notificationChannel) {
+	defer close(ch.done)
+	for alert := range ch.queue {
+		if err := ch.notifier.Notify(context.Background(), alert); err != nil {
+			log.Printf("Warning: Failed to send alert %s via %s: %v", alert.ID, ch.cfg.Name, err)
+			n.metrics.IncrementAlertNotifications(ch.cfg.Name, "failed")
+			continue
+		}
+		n.metrics.IncrementAlertNotifications(ch.cfg.Name, "sent")
+	}
+}
+
+// Close stops accepting alerts and waits for queued notifications to be delivered
+func (n *NotificationService) Close() {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	n.closed = true
+	n.mu.Unlock()
+
+	for _, ch := range n.channels {
+		close(ch.queue)
+	}
+	for _, ch := range n.channels {
+		<-ch.done
+	}
+}
+
+// routes returns true if the channel's severity and greenhouse filters match the alert
+func (ch *notificationChannel) routes(alert models.Alert) bool {
+	return matchesAny(ch.cfg.Severities, alert.Severity) && matchesAny(ch.cfg.Greenhouses, alert.GreenhouseID)
+}
+
+// matchesAny returns true if values is empty or contains value
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// allow applies the dedup window and rate limit, recording the notification if it may be sent.
+// The dedup key is the rule, node and state, so a flapping alert pages once per window
+func (ch *notificationChannel) allow(alert models.Alert, now time.Time) bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	key := alert.RuleID + "|" + alert.GreenhouseID + "|" + alert.NodeID + "|" + alert.State
+	if window := time.Duration(ch.cfg.DedupWindow); window > 0 {
+		for k, t := range ch.lastSent {
+			if now.Sub(t) >= window {
+				delete(ch.lastSent, k)
+			}
+		}
+		if _, ok := ch.lastSent[key]; ok {
+			return false
+		}
+	}
+
+	if ch.cfg.RateLimit > 0 {
+		window := time.Duration(ch.cfg.RateWindow)
+		kept := ch.sent[:0]
+		for _, t := range ch.sent {
+			if now.Sub(t) < window {
+				kept = append(kept, t)
+			}
+		}
+		ch.sent = kept
+		if len(ch.sent) >= ch.cfg.RateLimit {
+			return false
+		}
+		ch.sent = append(ch.sent, now)
+	}
+
+	if ch.cfg.DedupWindow > 0 {
+		ch.lastSent[key] = now
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// Publisher publishes a message to the MQTT broker (implemented by mqtt.Client)
+type Publisher interface {
+	Publish(topic string, qos byte, retained bool, payload []byte) error
+}
+
+// MQTTNotifier publishes alerts to a per-greenhouse topic such as greenhouse/GH1/alerts
+type MQTTNotifier struct {
+	cfg *config.MQTTChannelConfig
+
+	mu        sync.RWMutex
+	publisher Publisher
+}
+
+// NewMQTTNotifier creates an MQTT notifier; it fails until a publisher is set
+func NewMQTTNotifier(cfg *config.MQTTChannelConfig) *MQTTNotifier {
+	return &MQTTNotifier{cfg: cfg}
+}
+
+// SetPublisher sets the MQTT connection alerts are published on
+func (m *MQTTNotifier) SetPublisher(publisher Publisher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publisher = publisher
+}
+
+// Notify publishes the alert as JSON
+func (m *MQTTNotifier) Notify(ctx context.Context, alert models.Alert) error {
+	m.mu.RLock()
+	publisher := m.publisher
+	m.mu.RUnlock()
+	if publisher == nil {
+		return errors.New("MQTT publisher not connected")
+	}
+
+	payload, err := json.Marshal(newAlertNotification(alert))
+	if err != nil {
+		return err
+	}
+	topic := strings.NewReplacer(
+		"{greenhouse_id}", alert.GreenhouseID,
+		"{node_id}", alert.NodeID,
+	).Replace(m.cfg.Topic)
+	return publisher.Publish(topic, m.cfg.QoS, m.cfg.Retain, payload)
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"iot-agriculture-backend/internal/config"
+)
+
+// recordingPublisher records published messages
+type recordingPublisher struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  []byte
+}
+
+func (p *recordingPublisher) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	p.topic, p.qos, p.retained, p.payload = topic, qos, retained, payload
+	return nil
+}
+
+func TestMQTTNotifierTopicTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"greenhouse/{greenhouse_id}/alerts", "greenhouse/GH1/alerts"},
+		{"farm/{greenhouse_id}/{node_id}/alerts", "farm/GH1/Node01/alerts"},
+		{"alerts", "alerts"},
+	}
+	for _, tt := range tests {
+		publisher := &recordingPublisher{}
+		notifier := NewMQTTNotifier(&config.MQTTChannelConfig{Topic: tt.template, QoS: 1, Retain: true})
+		notifier.SetPublisher(publisher)
+
+		if err := notifier.Notify(context.Background(), testAlert()); err != nil {
+			t.Fatalf("Notify(%q): %v", tt.template, err)
+		}
+		if publisher.topic != tt.want {
+			t.Errorf("topic of %q = %q, want %q", tt.template, publisher.topic, tt.want)
+		}
+		if publisher.qos != 1 || !publisher.retained {
+			t.Errorf("qos %d retained %v, want 1 true", publisher.qos, publisher.retained)
+		}
+		var notification AlertNotification
+		if err := json.Unmarshal(publisher.payload, &notification); err != nil || notification.Alert.ID != "a1" {
+			t.Errorf("unexpected payload %s (%v)", publisher.payload, err)
+		}
+	}
+}
+
+func TestMQTTNotifierWithoutPublisher(t *testing.T) {
+	notifier := NewMQTTNotifier(&config.MQTTChannelConfig{Topic: "alerts"})
+	if err := notifier.Notify(context.Background(), testAlert()); err == nil {
+		t.Error("expected an error before a publisher is set")
+	}
+}
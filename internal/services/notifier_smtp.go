@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// SMTPNotifier emails alerts
+type SMTPNotifier struct {
+	cfg *config.SMTPChannelConfig
+}
+
+// NewSMTPNotifier creates an SMTP notifier
+func NewSMTPNotifier(cfg *config.SMTPChannelConfig) *SMTPNotifier {
+	return &SMTPNotifier{cfg: cfg}
+}
+
+// Notify emails the alert to every recipient. STARTTLS is used when the server
+// offers it; credentials are only sent over TLS (or to localhost)
+func (s *SMTPNotifier) Notify(ctx context.Context, alert models.Alert) error {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	deadline := time.Now().Add(time.Duration(s.cfg.Timeout))
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(s.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range s.cfg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.message(alert)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message builds a plain text email for the alert
+func (s *SMTPNotifier) message(alert models.Alert) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", sanitizeHeader(alertSubject(alert)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+
+	fmt.Fprintf(&b, "%s\r\n\r\n", alert.Message)
+	fmt.Fprintf(&b, "Rule:       %s (%s)\r\n", alert.RuleName, alert.RuleID)
+	fmt.Fprintf(&b, "Node:       %s/%s\r\n", alert.GreenhouseID, alert.NodeID)
+	fmt.Fprintf(&b, "Severity:   %s\r\n", alert.Severity)
+	fmt.Fprintf(&b, "State:      %s\r\n", alert.State)
+	fmt.Fprintf(&b, "Value:      %.2f (threshold %g)\r\n", alert.Value, alert.Threshold)
+	fmt.Fprintf(&b, "Started at: %s\r\n", alert.StartedAt.Format(time.RFC3339))
+	if alert.FiredAt != nil {
+		fmt.Fprintf(&b, "Fired at:   %s\r\n", alert.FiredAt.Format(time.RFC3339))
+	}
+	if alert.ResolvedAt != nil {
+		fmt.Fprintf(&b, "Resolved:   %s\r\n", alert.ResolvedAt.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "Alert ID:   %s\r\n", alert.ID)
+	return b.Bytes()
+}
+
+// sanitizeHeader strips line breaks so values cannot inject extra headers
+func sanitizeHeader(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+)
+
+// smtpSession is what the test server received in one session
+type smtpSession struct {
+	from string
+	to   []string
+	data string
+}
+
+// smtpServer runs a minimal in-process SMTP server for one session and returns
+// its port and a channel receiving the session once the client quits
+// Recipients listed in reject are refused with 550
+func smtpServer(t *testing.T, reject ...string) (int, <-chan smtpSession) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		tp := textproto.NewConn(conn)
+
+		var session smtpSession
+		tp.PrintfLine("220 localhost ESMTP test")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "MAIL":
+				session.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+				tp.PrintfLine("250 OK")
+			case "RCPT":
+				to := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+				if contains(reject, to) {
+					tp.PrintfLine("550 No such user")
+					continue
+				}
+				session.to = append(session.to, to)
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				session.data = string(data)
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 Bye")
+				sessions <- session
+				return
+			default:
+				tp.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, sessions
+}
+
+func TestSMTPNotifierDeliversAlert(t *testing.T) {
+	port, sessions := smtpServer(t)
+	notifier := NewSMTPNotifier(&config.SMTPChannelConfig{
+		Host:    "127.0.0.1",
+		Port:    port,
+		From:    "alerts@farm.example",
+		To:      []string{"grower@farm.example", "ops@farm.example"},
+		Timeout: config.Duration(5 * time.Second),
+	})
+
+	if err := notifier.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	var session smtpSession
+	select {
+	case session = <-sessions:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not receive a complete session")
+	}
+
+	if session.from != "alerts@farm.example" {
+		t.Errorf("MAIL FROM %q", session.from)
+	}
+	if strings.Join(session.to, ",") != "grower@farm.example,ops@farm.example" {
+		t.Errorf("RCPT TO %v", session.to)
+	}
+	for _, want := range []string{
+		"Subject: [CRITICAL] firing: Air_Temp > 35 on GH1/Node01\n",
+		"To: grower@farm.example, ops@farm.example\n",
+		"Air_Temp is 36.20 (> 35)\n",
+		"Alert ID:   a1\n",
+	} {
+		if !strings.Contains(session.data, want) {
+			t.Errorf("message is missing %q:\n%s", want, session.data)
+		}
+	}
+}
+
+func TestSMTPNotifierRejectedRecipient(t *testing.T) {
+	port, _ := smtpServer(t, "nobody@farm.example")
+	notifier := NewSMTPNotifier(&config.SMTPChannelConfig{
+		Host:    "127.0.0.1",
+		Port:    port,
+		From:    "alerts@farm.example",
+		To:      []string{"nobody@farm.example"},
+		Timeout: config.Duration(5 * time.Second),
+	})
+
+	err := notifier.Notify(context.Background(), testAlert())
+	if err == nil || !strings.Contains(err.Error(), "nobody@farm.example") {
+		t.Errorf("expected the rejected recipient in the error, got %v", err)
+	}
+}
+
+func TestSMTPNotifierUnreachableServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	notifier := NewSMTPNotifier(&config.SMTPChannelConfig{
+		Host:    "127.0.0.1",
+		Port:    port,
+		From:    "alerts@farm.example",
+		To:      []string{"grower@farm.example"},
+		Timeout: config.Duration(time.Second),
+	})
+	if err := notifier.Notify(context.Background(), testAlert()); err == nil || !strings.Contains(err.Error(), strconv.Itoa(port)) {
+		t.Errorf("expected a connection error, got %v", err)
+	}
+}
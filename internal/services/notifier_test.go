@@ -0,0 +1,120 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// testAlert returns a firing alert of rule r1 on GH1/Node01
+func testAlert() models.Alert {
+	return models.Alert{
+		ID:           "a1",
+		RuleID:       "r1",
+		RuleName:     "Air_Temp > 35",
+		GreenhouseID: "GH1",
+		NodeID:       "Node01",
+		Severity:     "critical",
+		State:        models.AlertFiring,
+		Value:        36.2,
+		Threshold:    35,
+		Message:      "Air_Temp is 36.20 (> 35)",
+		StartedAt:    time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func newTestChannel(cfg config.NotificationChannelConfig) *notificationChannel {
+	return &notificationChannel{cfg: cfg, lastSent: make(map[string]time.Time)}
+}
+
+func TestChannelAllowDedupsRepeatsWithinWindow(t *testing.T) {
+	ch := newTestChannel(config.NotificationChannelConfig{DedupWindow: config.Duration(10 * time.Minute)})
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	alert := testAlert()
+
+	if !ch.allow(alert, now) {
+		t.Fatal("first notification was suppressed")
+	}
+	if ch.allow(alert, now.Add(5*time.Minute)) {
+		t.Error("repeat within the dedup window was allowed")
+	}
+
+	resolved := alert
+	resolved.State = models.AlertResolved
+	if !ch.allow(resolved, now.Add(5*time.Minute)) {
+		t.Error("state change was deduped")
+	}
+	other := alert
+	other.NodeID = "Node02"
+	if !ch.allow(other, now.Add(5*time.Minute)) {
+		t.Error("same rule on another node was deduped")
+	}
+
+	if !ch.allow(alert, now.Add(10*time.Minute)) {
+		t.Error("repeat after the dedup window was suppressed")
+	}
+}
+
+func TestChannelAllowRateLimits(t *testing.T) {
+	ch := newTestChannel(config.NotificationChannelConfig{RateLimit: 2, RateWindow: config.Duration(time.Hour)})
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	alert := testAlert()
+
+	for i := 0; i < 2; i++ {
+		if !ch.allow(alert, now.Add(time.Duration(i)*time.Minute)) {
+			t.Fatalf("notification %d within the limit was suppressed", i+1)
+		}
+	}
+	if ch.allow(alert, now.Add(30*time.Minute)) {
+		t.Error("notification over the rate limit was allowed")
+	}
+	// The first notification has left the window, freeing one slot
+	if !ch.allow(alert, now.Add(time.Hour)) {
+		t.Error("notification after the rate window was suppressed")
+	}
+	if ch.allow(alert, now.Add(time.Hour)) {
+		t.Error("second notification after the rate window exceeded the limit")
+	}
+}
+
+func TestChannelAllowDedupedAlertsDoNotCountAgainstRateLimit(t *testing.T) {
+	ch := newTestChannel(config.NotificationChannelConfig{
+		DedupWindow: config.Duration(time.Hour),
+		RateLimit:   2,
+		RateWindow:  config.Duration(time.Hour),
+	})
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	alert := testAlert()
+
+	ch.allow(alert, now)
+	for i := 1; i <= 3; i++ {
+		if ch.allow(alert, now.Add(time.Duration(i)*time.Minute)) {
+			t.Fatalf("repeat %d was not deduped", i)
+		}
+	}
+	other := alert
+	other.NodeID = "Node02"
+	if !ch.allow(other, now.Add(5*time.Minute)) {
+		t.Error("deduped repeats used up the rate limit")
+	}
+}
+
+func TestChannelRoutesBySeverityAndGreenhouse(t *testing.T) {
+	ch := newTestChannel(config.NotificationChannelConfig{Severities: []string{"critical"}, Greenhouses: []string{"GH1"}})
+	alert := testAlert()
+	if !ch.routes(alert) {
+		t.Error("matching alert was not routed")
+	}
+	warning := alert
+	warning.Severity = "warning"
+	if ch.routes(warning) {
+		t.Error("alert of another severity was routed")
+	}
+	other := alert
+	other.GreenhouseID = "GH2"
+	if ch.routes(other) {
+		t.Error("alert of another greenhouse was routed")
+	}
+}
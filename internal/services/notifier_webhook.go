@@ -0,0 +1,104 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// Webhook signature headers. The signature is "sha256=" followed by the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the channel secret
+const (
+	WebhookSignatureHeader = "X-Alert-Signature"
+	WebhookTimestampHeader = "X-Alert-Timestamp"
+)
+
+// WebhookNotifier POSTs alerts as JSON, retrying with exponential backoff
+type WebhookNotifier struct {
+	cfg    *config.WebhookChannelConfig
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a webhook notifier
+func NewWebhookNotifier(cfg *config.WebhookChannelConfig) *WebhookNotifier {
+	return &WebhookNotifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: time.Duration(cfg.Timeout)},
+	}
+}
+
+// Notify sends the alert, retrying on network errors, 429 and 5xx responses
+func (w *WebhookNotifier) Notify(ctx context.Context, alert models.Alert) error {
+	body, err := json.Marshal(newAlertNotification(alert))
+	if err != nil {
+		return err
+	}
+
+	backoff := time.Duration(w.cfg.RetryBackoff)
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.cfg.MaxRetries {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth retrying
+func (w *WebhookNotifier) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "iot-agriculture-backend")
+	for key, value := range w.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	if w.cfg.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(w.cfg.Secret, timestamp, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
+
+// SignWebhook returns the signature header value for a webhook body
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
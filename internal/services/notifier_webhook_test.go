@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+)
+
+// webhookRequest is a request received by the test server
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+	at     time.Time
+}
+
+// webhookServer answers each request with the next status, then 200
+func webhookServer(t *testing.T, statuses ...int) (*httptest.Server, func() []webhookRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []webhookRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		status := http.StatusOK
+		if len(requests) < len(statuses) {
+			status = statuses[len(requests)]
+		}
+		requests = append(requests, webhookRequest{header: r.Header.Clone(), body: body, at: time.Now()})
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []webhookRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]webhookRequest(nil), requests...)
+	}
+}
+
+func TestWebhookSignsBody(t *testing.T) {
+	server, requests := webhookServer(t)
+	notifier := NewWebhookNotifier(&config.WebhookChannelConfig{
+		URL:     server.URL,
+		Secret:  "s3cret",
+		Headers: map[string]string{"X-Farm": "north"},
+		Timeout: config.Duration(5 * time.Second),
+	})
+
+	if err := notifier.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	got := requests()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(got))
+	}
+	req := got[0]
+
+	timestamp := req.header.Get(WebhookTimestampHeader)
+	if timestamp == "" {
+		t.Fatalf("missing %s header", WebhookTimestampHeader)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(timestamp + "." + string(req.body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if sig := req.header.Get(WebhookSignatureHeader); sig != want {
+		t.Errorf("signature %q, want %q", sig, want)
+	}
+	if req.header.Get("X-Farm") != "north" {
+		t.Errorf("custom header not sent: %v", req.header)
+	}
+
+	var notification AlertNotification
+	if err := json.Unmarshal(req.body, &notification); err != nil {
+		t.Fatalf("invalid body %s: %v", req.body, err)
+	}
+	if notification.Event != "alert.firing" || notification.Alert.ID != "a1" {
+		t.Errorf("unexpected notification %+v", notification)
+	}
+}
+
+func TestWebhookWithoutSecretIsUnsigned(t *testing.T) {
+	server, requests := webhookServer(t)
+	notifier := NewWebhookNotifier(&config.WebhookChannelConfig{URL: server.URL, Timeout: config.Duration(5 * time.Second)})
+
+	if err := notifier.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if sig := requests()[0].header.Get(WebhookSignatureHeader); sig != "" {
+		t.Errorf("unexpected signature %q", sig)
+	}
+}
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	server, requests := webhookServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	backoff := 20 * time.Millisecond
+	notifier := NewWebhookNotifier(&config.WebhookChannelConfig{
+		URL:          server.URL,
+		Timeout:      config.Duration(5 * time.Second),
+		MaxRetries:   3,
+		RetryBackoff: config.Duration(backoff),
+	})
+
+	if err := notifier.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	got := requests()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(got))
+	}
+	// The backoff doubles after every retry
+	if gap := got[1].at.Sub(got[0].at); gap < backoff {
+		t.Errorf("first retry after %v, want at least %v", gap, backoff)
+	}
+	if gap := got[2].at.Sub(got[1].at); gap < 2*backoff {
+		t.Errorf("second retry after %v, want at least %v", gap, 2*backoff)
+	}
+}
+
+func TestWebhookGivesUpAfterMaxRetries(t *testing.T) {
+	server, requests := webhookServer(t, 500, 500, 500, 500)
+	notifier := NewWebhookNotifier(&config.WebhookChannelConfig{
+		URL:          server.URL,
+		Timeout:      config.Duration(5 * time.Second),
+		MaxRetries:   2,
+		RetryBackoff: config.Duration(time.Millisecond),
+	})
+
+	if err := notifier.Notify(context.Background(), testAlert()); err == nil {
+		t.Fatal("expected an error after the retries were used up")
+	}
+	if n := len(requests()); n != 3 {
+		t.Errorf("expected 3 attempts (1 + 2 retries), got %d", n)
+	}
+}
+
+func TestWebhookDoesNotRetryClientErrors(t *testing.T) {
+	server, requests := webhookServer(t, http.StatusBadRequest)
+	notifier := NewWebhookNotifier(&config.WebhookChannelConfig{
+		URL:          server.URL,
+		Timeout:      config.Duration(5 * time.Second),
+		MaxRetries:   3,
+		RetryBackoff: config.Duration(time.Millisecond),
+	})
+
+	if err := notifier.Notify(context.Background(), testAlert()); err == nil {
+		t.Fatal("expected an error for a 400 response")
+	}
+	if n := len(requests()); n != 1 {
+		t.Errorf("expected 1 attempt, got %d", n)
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"iot-agriculture-backend/internal/models"
+)
+
+// RejectionError is a parse failure carrying the reason the message is rejected
+type RejectionError struct {
+	Rejection models.Rejection
+}
+
+// Error returns the rejection message
+func (e *RejectionError) Error() string {
+	return e.Rejection.Message
+}
+
+// reject returns a *RejectionError with a formatted message
+func reject(reason, sensor, format string, args ...interface{}) error {
+	return &RejectionError{Rejection: models.Rejection{
+		Reason:  reason,
+		Sensor:  sensor,
+		Message: fmt.Sprintf(format, args...),
+	}}
+}
+
+// rejectionFor converts a ParseSensorData error to a rejection
+func rejectionFor(err error) models.Rejection {
+	var rejection *RejectionError
+	switch {
+	case errors.As(err, &rejection):
+		return rejection.Rejection
+	case errors.Is(err, ErrIdentityMismatch):
+		return models.Rejection{Reason: models.RejectIdentityMismatch, Message: err.Error()}
+	default:
+		return models.Rejection{Reason: models.RejectInvalidJSON, Message: err.Error()}
+	}
+}
+
+// Validate checks parsed sensor data against the registry: every sensor required
+// for the node's type must be present and every value must lie within its
+// sensor's physical range. Returns nil if the data is valid
+func (r *SensorRegistry) Validate(data models.ESP32SensorData) []models.Rejection {
+	var rejections []models.Rejection
+	for _, name := range r.RequiredForNode(data.NodeID) {
+		if _, ok := data.Values[name]; !ok {
+			rejections = append(rejections, models.Rejection{
+				Reason:  models.RejectMissingField,
+				Sensor:  name,
+				Message: fmt.Sprintf("missing required sensor %s", name),
+			})
+		}
+	}
+	// Registry order keeps the reasons stable across replays
+	for _, s := range r.sensors {
+		value, ok := data.Values[s.Name]
+		if !ok {
+			continue
+		}
+		switch {
+		case s.Min != nil && value < *s.Min:
+			rejections = append(rejections, models.Rejection{
+				Reason:  models.RejectOutOfRange,
+				Sensor:  s.Name,
+				Message: fmt.Sprintf("%s=%s is below the minimum of %s%s", s.Name, formatNumber(value), formatNumber(*s.Min), unitSuffix(s.Unit)),
+			})
+		case s.Max != nil && value > *s.Max:
+			rejections = append(rejections, models.Rejection{
+				Reason:  models.RejectOutOfRange,
+				Sensor:  s.Name,
+				Message: fmt.Sprintf("%s=%s is above the maximum of %s%s", s.Name, formatNumber(value), formatNumber(*s.Max), unitSuffix(s.Unit)),
+			})
+		}
+	}
+	return rejections
+}
+
+// countZeroValues returns the number of readings that are exactly zero
+func countZeroValues(values map[string]float64) int {
+	count := 0
+	for _, value := range values {
+		if value == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// formatNumber formats a value without trailing zeros
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// unitSuffix returns " unit", or "" for unitless sensors
+func unitSuffix(unit string) string {
+	if unit == "" {
+		return ""
+	}
+	return " " + unit
+}
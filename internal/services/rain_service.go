@@ -0,0 +1,211 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// rainMinutesPrecision is the number of decimals daily rainy minutes are reported with
+const rainMinutesPrecision = 1
+
+// rainState tracks the rain flag of one node and the rain event in progress
+type rainState struct {
+	greenhouseID string
+	nodeID       string
+	lastTime     time.Time
+	raining      bool
+	start        time.Time
+}
+
+// RainService turns the 0/1 rain flag of each greenhouse/node into rain events.
+// An event starts at the first reading with rain and ends at the first dry reading;
+// a gap longer than the max gap while raining ends it at the last reading
+type RainService struct {
+	mu        sync.Mutex
+	cfg       *config.RainConfig
+	states    map[string]*rainState // key: greenhouse_id|node_id
+	started   []models.RainEvent
+	completed []models.RainEvent
+	metrics   *MetricsService
+}
+
+// NewRainService creates a new rain detection service
+func NewRainService(cfg *config.RainConfig, metrics *MetricsService) *RainService {
+	return &RainService{
+		cfg:     cfg,
+		states:  make(map[string]*rainState),
+		metrics: metrics,
+	}
+}
+
+// Enabled returns true if a rain sensor is configured
+func (s *RainService) Enabled() bool {
+	return s.cfg.Sensor != ""
+}
+
+// Location returns the time zone of the daily rainy minutes
+func (s *RainService) Location() *time.Location {
+	return s.cfg.Location
+}
+
+// Add feeds the rain flag of a message with the given event time into detection
+// Readings older than the node's last reading are ignored
+func (s *RainService) Add(data models.ESP32SensorData, eventTime time.Time) {
+	if !s.Enabled() {
+		return
+	}
+	value, ok := data.Values[s.cfg.Sensor]
+	if !ok {
+		return
+	}
+	raining := value >= 0.5
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := data.GreenhouseID + "|" + data.NodeID
+	state, ok := s.states[key]
+	if !ok {
+		state = &rainState{greenhouseID: data.GreenhouseID, nodeID: data.NodeID, lastTime: eventTime}
+		s.states[key] = state
+	} else if !eventTime.After(state.lastTime) {
+		return
+	}
+
+	if state.raining && eventTime.Sub(state.lastTime) > s.cfg.MaxGap {
+		s.finish(state, state.lastTime)
+	}
+	switch {
+	case raining && !state.raining:
+		state.raining = true
+		state.start = eventTime
+		s.started = append(s.started, models.RainEvent{
+			GreenhouseID: data.GreenhouseID,
+			NodeID:       data.NodeID,
+			Start:        eventTime,
+			Active:       true,
+		})
+		s.metrics.SetRaining(data.GreenhouseID, data.NodeID, true)
+	case !raining && state.raining:
+		s.finish(state, eventTime)
+	}
+	state.lastTime = eventTime
+}
+
+// Flush ends the rain events whose node has not reported for the max gap by now and
+// returns the events started and completed since the last call, oldest first
+func (s *RainService) Flush(now time.Time) (started, completed []models.RainEvent) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, state := range s.states {
+		if state.raining && now.Sub(state.lastTime) > s.cfg.MaxGap {
+			s.finish(state, state.lastTime)
+		}
+	}
+	started, completed = s.started, s.completed
+	s.started, s.completed = nil, nil
+	sort.Slice(completed, func(i, j int) bool { return completed[i].Start.Before(completed[j].Start) })
+	return started, completed
+}
+
+// Active returns the rain events in progress, optionally filtered by greenhouse and node,
+// with their duration up to the node's last reading
+func (s *RainService) Active(greenhouseID, nodeID string) []models.RainEvent {
+	active := make([]models.RainEvent, 0)
+	if !s.Enabled() {
+		return active
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, state := range s.states {
+		if !state.raining || (greenhouseID != "" && state.greenhouseID != greenhouseID) || (nodeID != "" && state.nodeID != nodeID) {
+			continue
+		}
+		active = append(active, models.RainEvent{
+			GreenhouseID: state.greenhouseID,
+			NodeID:       state.nodeID,
+			Start:        state.start,
+			Duration:     state.lastTime.Sub(state.start).Seconds(),
+			Active:       true,
+		})
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Start.Before(active[j].Start) })
+	return active
+}
+
+// finish records a node's rain event as ended at end
+func (s *RainService) finish(state *rainState, end time.Time) {
+	state.raining = false
+	s.completed = append(s.completed, models.RainEvent{
+		GreenhouseID: state.greenhouseID,
+		NodeID:       state.nodeID,
+		Start:        state.start,
+		End:          &end,
+		Duration:     end.Sub(state.start).Seconds(),
+	})
+	s.metrics.SetRaining(state.greenhouseID, state.nodeID, false)
+	s.metrics.IncrementRainEvents(state.greenhouseID, state.nodeID)
+}
+
+// DailyRain sums events per greenhouse/node and local day, newest day first
+// Events spanning midnight add their minutes to each day they cover
+func DailyRain(events []models.RainEvent, location *time.Location) []models.RainDaily {
+	totals := make(map[string]*models.RainDaily)
+	day := func(event models.RainEvent, t time.Time) *models.RainDaily {
+		date := t.In(location).Format(dayLayout)
+		key := event.GreenhouseID + "|" + event.NodeID + "|" + date
+		d, ok := totals[key]
+		if !ok {
+			d = &models.RainDaily{GreenhouseID: event.GreenhouseID, NodeID: event.NodeID, Date: date}
+			totals[key] = d
+		}
+		return d
+	}
+	for _, event := range events {
+		day(event, event.Start).Events++
+		end := event.Start.Add(time.Duration(event.Duration * float64(time.Second)))
+		for t := event.Start; t.Before(end); {
+			local := t.In(location)
+			next := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, location)
+			segmentEnd := end
+			if next.Before(end) {
+				segmentEnd = next
+			}
+			day(event, t).RainyMinutes += segmentEnd.Sub(t).Minutes()
+			t = segmentEnd
+		}
+	}
+
+	precision := rainMinutesPrecision
+	out := make([]models.RainDaily, 0, len(totals))
+	for _, d := range totals {
+		d.RainyMinutes = roundTo(d.RainyMinutes, &precision)
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Date != out[j].Date {
+			return out[i].Date > out[j].Date
+		}
+		if out[i].GreenhouseID != out[j].GreenhouseID {
+			return out[i].GreenhouseID < out[j].GreenhouseID
+		}
+		return out[i].NodeID < out[j].NodeID
+	})
+	return out
+}
+
+// describeRain formats an event for the console, e.g. "GH1/Node05 from 14:02:11 to 14:25:41 (23.5 min)"
+func describeRain(event models.RainEvent) string {
+	s := fmt.Sprintf("%s/%s from %s", event.GreenhouseID, event.NodeID, event.Start.Format(time.TimeOnly))
+	if event.End != nil {
+		s += fmt.Sprintf(" to %s (%.1f min)", event.End.Format(time.TimeOnly), event.Duration/60)
+	}
+	return s
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// Resolutions of the stored averages, from finest to coarsest
+const (
+	ResolutionRaw = "raw"
+	Resolution15m = "15m"
+	Resolution1h  = "1h"
+	Resolution1d  = "1d"
+)
+
+// RollupLevel describes one rollup resolution and the measurement it is stored in
+type RollupLevel struct {
+	Resolution  string
+	Interval    time.Duration
+	Measurement string
+}
+
+// rollupLevels are the rollups kept in addition to the base window averages
+// Buckets are aligned to UTC boundaries (daily rollups run from 00:00 UTC)
+var rollupLevels = []RollupLevel{
+	{Resolution: Resolution15m, Interval: 15 * time.Minute, Measurement: "sensor_averages_15m"},
+	{Resolution: Resolution1h, Interval: time.Hour, Measurement: "sensor_averages_1h"},
+	{Resolution: Resolution1d, Interval: 24 * time.Hour, Measurement: "sensor_averages_1d"},
+}
+
+// BaseMeasurement is the measurement holding the base window averages
+const BaseMeasurement = "sensor_averages"
+
+// MeasurementForResolution returns the measurement storing averages at a resolution
+func MeasurementForResolution(resolution string) (string, bool) {
+	if resolution == ResolutionRaw {
+		return BaseMeasurement, true
+	}
+	for _, level := range rollupLevels {
+		if level.Resolution == resolution {
+			return level.Measurement, true
+		}
+	}
+	return "", false
+}
+
+// ResolutionForSpan picks the coarsest resolution that still gives a useful
+// number of points for a queried time span
+func ResolutionForSpan(span time.Duration) string {
+	switch {
+	case span <= 6*time.Hour:
+		return ResolutionRaw
+	case span <= 3*24*time.Hour:
+		return Resolution15m
+	case span <= 31*24*time.Hour:
+		return Resolution1h
+	default:
+		return Resolution1d
+	}
+}
+
+// sensorAccumulator merges window stats into a rollup bucket
+type sensorAccumulator struct {
+	count   int
+	sum     float64
+	sumSq   float64
+	min     float64
+	max     float64
+	medians []float64
+}
+
+// rollupBucket holds the accumulated stats of one node for one rollup interval
+type rollupBucket struct {
+	level        RollupLevel
+	greenhouseID string
+	nodeID       string
+	start        time.Time
+	end          time.Time
+	readings     int
+	sensors      map[string]*sensorAccumulator
+	derived      map[string][]float64 // Window values of each derived metric
+}
+
+// RollupService aggregates base window averages into 15-minute, hourly and daily rollups
+type RollupService struct {
+	mu      sync.Mutex
+	buckets map[string]*rollupBucket // key: resolution|greenhouse_id|node_id|bucket start
+	grace   time.Duration
+}
+
+// NewRollupService creates a new rollup service
+// Buckets are flushed once every base window inside them can have been flushed
+func NewRollupService(cfg *config.AveragingConfig) *RollupService {
+	return &RollupService{
+		buckets: make(map[string]*rollupBucket),
+		grace:   cfg.Window + cfg.AllowedLateness + cfg.FlushInterval,
+	}
+}
+
+// Add merges a flushed base window result into every rollup level
+func (r *RollupService) Add(result models.AverageResult) {
+	if result.Readings == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, level := range rollupLevels {
+		start := result.WindowStart.Truncate(level.Interval)
+		end := start.Add(level.Interval)
+		if !end.Add(r.grace).After(now) {
+			log.Printf("Dropping %s window %s/%s ending %s from %s rollup: bucket already flushed",
+				BaseMeasurement, result.GreenhouseID, result.NodeID, result.WindowEnd.Format(time.RFC3339), level.Resolution)
+			continue
+		}
+
+		key := fmt.Sprintf("%s|%s|%s|%d", level.Resolution, result.GreenhouseID, result.NodeID, start.Unix())
+		bucket, ok := r.buckets[key]
+		if !ok {
+			bucket = &rollupBucket{
+				level:        level,
+				greenhouseID: result.GreenhouseID,
+				nodeID:       result.NodeID,
+				start:        start,
+				end:          end,
+				sensors:      make(map[string]*sensorAccumulator),
+				derived:      make(map[string][]float64),
+			}
+			r.buckets[key] = bucket
+		}
+		bucket.readings += result.Readings
+		for name, stats := range result.Stats {
+			bucket.add(name, stats)
+		}
+		for name, value := range result.Derived {
+			bucket.derived[name] = append(bucket.derived[name], value)
+		}
+	}
+}
+
+// add merges one window's stats for a sensor into the bucket
+func (b *rollupBucket) add(name string, stats models.SensorStats) {
+	if stats.Count == 0 {
+		return
+	}
+	acc, ok := b.sensors[name]
+	if !ok {
+		acc = &sensorAccumulator{min: stats.Min, max: stats.Max}
+		b.sensors[name] = acc
+	}
+	n := float64(stats.Count)
+	acc.count += stats.Count
+	acc.sum += stats.Mean * n
+	acc.sumSq += (stats.StdDev*stats.StdDev + stats.Mean*stats.Mean) * n
+	acc.min = math.Min(acc.min, stats.Min)
+	acc.max = math.Max(acc.max, stats.Max)
+	acc.medians = append(acc.medians, stats.Median)
+}
+
+// result converts the bucket into an AverageResult
+// Mean, min, max, stddev and count are exact; the median is the median of window medians
+// and derived metrics are the mean of their window values
+func (b *rollupBucket) result() models.AverageResult {
+	result := models.AverageResult{
+		GreenhouseID: b.greenhouseID,
+		NodeID:       b.nodeID,
+		WindowStart:  b.start,
+		WindowEnd:    b.end,
+		Duration:     b.end.Sub(b.start).Seconds(),
+		Readings:     b.readings,
+		Stats:        make(map[string]models.SensorStats, len(b.sensors)),
+		Derived:      make(map[string]float64, len(b.derived)),
+	}
+	for name, acc := range b.sensors {
+		n := float64(acc.count)
+		mean := acc.sum / n
+		variance := math.Max(acc.sumSq/n-mean*mean, 0)
+		result.Stats[name] = models.SensorStats{
+			Mean:   mean,
+			Min:    acc.min,
+			Max:    acc.max,
+			StdDev: math.Sqrt(variance),
+			Median: median(acc.medians),
+			Count:  acc.count,
+		}
+	}
+	for name, values := range b.derived {
+		m, _ := lookupDerivedMetric(name)
+		result.Derived[name] = roundTo(calculateStats(values).Mean, &m.precision)
+	}
+	return result
+}
+
+// Flush writes every rollup bucket whose interval has closed to InfluxDB
+func (r *RollupService) Flush(influxService *InfluxDBService, metricsService *MetricsService) []models.AverageResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	results := make([]models.AverageResult, 0)
+	for key, bucket := range r.buckets {
+		if bucket.end.Add(r.grace).After(now) {
+			continue
+		}
+		delete(r.buckets, key)
+
+		result := bucket.result()
+		results = append(results, result)
+		if influxService == nil || !influxService.IsWritable() {
+			continue
+		}
+		if err := influxService.LogAveragesTo(bucket.level.Measurement, result); err != nil {
+			fmt.Printf("Warning: Failed to log %s rollup to InfluxDB: %v\n", bucket.level.Resolution, err)
+			if metricsService != nil {
+				metricsService.IncrementInfluxDBWriteErrors()
+			}
+		} else if metricsService != nil {
+			metricsService.IncrementInfluxDBWrites()
+		}
+	}
+	return results
+}
+
+// median returns the median of a slice of floats
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// SensorRegistry provides lookups over the configured sensor definitions
+type SensorRegistry struct {
+	sensors   []config.SensorDefinition
+	byName    map[string]int
+	nodeTypes map[string]string   // key: node_id, value: node type name
+	required  map[string][]string // key: node type name, value: required sensor names
+}
+
+// NewSensorRegistry creates a sensor registry from configuration
+func NewSensorRegistry(cfg *config.SensorsConfig) *SensorRegistry {
+	r := &SensorRegistry{
+		sensors:   cfg.Sensors,
+		byName:    make(map[string]int, len(cfg.Sensors)),
+		nodeTypes: make(map[string]string),
+		required:  make(map[string][]string),
+	}
+	for i, s := range cfg.Sensors {
+		r.byName[s.Name] = i
+	}
+	for _, nt := range cfg.NodeTypes {
+		for _, node := range nt.Nodes {
+			r.nodeTypes[node] = nt.Name
+		}
+		if len(nt.Required) > 0 {
+			r.required[nt.Name] = nt.Required
+		}
+	}
+	return r
+}
+
+// Sensors returns all sensor definitions in registry order
+func (r *SensorRegistry) Sensors() []config.SensorDefinition {
+	return r.sensors
+}
+
+// Names returns all sensor names in registry order
+func (r *SensorRegistry) Names() []string {
+	names := make([]string, len(r.sensors))
+	for i, s := range r.sensors {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// Lookup returns the definition of the named sensor
+func (r *SensorRegistry) Lookup(name string) (config.SensorDefinition, bool) {
+	i, ok := r.byName[name]
+	if !ok {
+		return config.SensorDefinition{}, false
+	}
+	return r.sensors[i], true
+}
+
+// IsValid returns true if the sensor name is defined in the registry
+func (r *SensorRegistry) IsValid(name string) bool {
+	_, ok := r.byName[name]
+	return ok
+}
+
+// IsAggregated returns true if the named sensor is a measurement averaged into windows and rollups
+// State sensors such as a 0/1 flag are stored raw only
+func (r *SensorRegistry) IsAggregated(name string) bool {
+	s, ok := r.Lookup(name)
+	return ok && s.Kind != config.SensorKindState
+}
+
+// NodeType returns the node type of a node, or "" if the node is not assigned one
+func (r *SensorRegistry) NodeType(nodeID string) string {
+	return r.nodeTypes[nodeID]
+}
+
+// RequiredForNode returns the sensors every message from a node must carry
+func (r *SensorRegistry) RequiredForNode(nodeID string) []string {
+	return r.required[r.nodeTypes[nodeID]]
+}
+
+// SensorsForNode returns the sensors a node is expected to publish
+// Nodes without a node type accept every sensor in the registry
+func (r *SensorRegistry) SensorsForNode(nodeID string) []config.SensorDefinition {
+	nodeType, ok := r.nodeTypes[nodeID]
+	if !ok {
+		return r.sensors
+	}
+	out := make([]config.SensorDefinition, 0, len(r.sensors))
+	for _, s := range r.sensors {
+		if len(s.NodeTypes) == 0 || contains(s.NodeTypes, nodeType) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Per-sensor aggregates stored in InfluxDB as {sensor}_{stat} fields
+const (
+	StatAverage = "average"
+	StatMin     = "min"
+	StatMax     = "max"
+	StatStdDev  = "stddev"
+	StatMedian  = "median"
+	StatCount   = "count"
+)
+
+// FieldName returns the InfluxDB field name holding an aggregate of a sensor
+func (r *SensorRegistry) FieldName(name, stat string) string {
+	return name + "_" + stat
+}
+
+// IdentityResolver decides which greenhouse and node a payload is attributed to,
+// given the IDs found in the payload (empty if absent)
+type IdentityResolver func(greenhouseID, nodeID string) (string, string, error)
+
+// ParseSensorData decodes an ESP32 JSON payload using the sensor registry
+// Keys that are not registered for the publishing node are ignored. If resolve
+// is not nil it sets the node identity before the node's sensors are looked up.
+// Malformed payloads return a *RejectionError
+func (r *SensorRegistry) ParseSensorData(payload []byte, resolve IdentityResolver) (models.ESP32SensorData, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return models.ESP32SensorData{}, reject(models.RejectInvalidJSON, "", "invalid JSON: %v", err)
+	}
+
+	data := models.ESP32SensorData{
+		Values: make(map[string]float64),
+	}
+	if err := decodeOptional(raw, "greenhouse_id", &data.GreenhouseID); err != nil {
+		return models.ESP32SensorData{}, err
+	}
+	if err := decodeOptional(raw, "node_id", &data.NodeID); err != nil {
+		return models.ESP32SensorData{}, err
+	}
+	if err := decodeOptional(raw, "timestamp", &data.Timestamp); err != nil {
+		return models.ESP32SensorData{}, err
+	}
+	if resolve != nil {
+		greenhouseID, nodeID, err := resolve(data.GreenhouseID, data.NodeID)
+		if err != nil {
+			return models.ESP32SensorData{}, err
+		}
+		data.GreenhouseID, data.NodeID = greenhouseID, nodeID
+	}
+
+	for _, s := range r.SensorsForNode(data.NodeID) {
+		v, ok := raw[s.JSONKey]
+		if !ok || isJSONNull(v) {
+			continue
+		}
+		var value float64
+		if err := json.Unmarshal(v, &value); err != nil {
+			return models.ESP32SensorData{}, reject(models.RejectInvalidValue, s.Name, "field %s: %v", s.JSONKey, err)
+		}
+		if s.Type == config.SensorTypeInt && value != math.Trunc(value) {
+			return models.ESP32SensorData{}, reject(models.RejectInvalidValue, s.Name, "field %s: %s is not a whole number", s.JSONKey, v)
+		}
+		if s.Scale != nil {
+			value *= *s.Scale
+		}
+		data.Values[s.Name] = roundTo(value, s.Precision)
+	}
+	return data, nil
+}
+
+// FieldValue returns the value to store in an InfluxDB field for a reading:
+// an integer for unscaled int sensors, so existing integer fields keep their type
+func (r *SensorRegistry) FieldValue(name string, v float64) interface{} {
+	if s, ok := r.Lookup(name); ok && s.Type == config.SensorTypeInt && s.Scale == nil {
+		return int64(v)
+	}
+	return v
+}
+
+// roundTo rounds v to the given number of decimal places (nil = unrounded)
+func roundTo(v float64, precision *int) float64 {
+	if precision == nil {
+		return v
+	}
+	p := math.Pow(10, float64(*precision))
+	return math.Round(v*p) / p
+}
+
+// decodeOptional decodes raw[key] into dst if the key is present and not null
+func decodeOptional(raw map[string]json.RawMessage, key string, dst interface{}) error {
+	v, ok := raw[key]
+	if !ok || isJSONNull(v) {
+		return nil
+	}
+	if err := json.Unmarshal(v, dst); err != nil {
+		return reject(models.RejectInvalidValue, "", "field %s: %v", key, err)
+	}
+	return nil
+}
+
+// isJSONNull returns true if the raw JSON value is the literal null
+func isJSONNull(v json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(v), []byte("null"))
+}
+
+// contains returns true if the slice contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
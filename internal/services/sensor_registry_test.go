@@ -0,0 +1,96 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+func parseTestRegistry() *SensorRegistry {
+	tenth, zero, one := 0.1, 0, 1
+	return NewSensorRegistry(&config.SensorsConfig{Sensors: []config.SensorDefinition{
+		{Name: "Air_Temp", JSONKey: "Air_Temp", Unit: "°C", Type: config.SensorTypeFloat},
+		{Name: "Air_Rh", JSONKey: "Air_Rh", Unit: "%", Type: config.SensorTypeFloat, Precision: &one},
+		{Name: "Light_Par", JSONKey: "Light_Par", Unit: "µmol/m²/s", Type: config.SensorTypeInt},
+		{Name: "Drip_Weight", JSONKey: "drip_weight", Unit: "g", Type: config.SensorTypeInt, Scale: &tenth, Precision: &one},
+		{Name: "Co2", JSONKey: "co2_ppm", Unit: "ppm", Type: config.SensorTypeFloat, Precision: &zero},
+		{Name: "Rain", JSONKey: "Rain", Type: config.SensorTypeInt, Kind: config.SensorKindState},
+	}})
+}
+
+func TestParseSensorData(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    map[string]float64
+	}{
+		{"float readings", `{"Air_Temp":23.7,"Air_Rh":61.25}`, map[string]float64{"Air_Temp": 23.7, "Air_Rh": 61.3}},
+		{"integer payloads stay compatible", `{"Air_Temp":23,"Light_Par":850}`, map[string]float64{"Air_Temp": 23, "Light_Par": 850}},
+		{"whole number written as a float", `{"Light_Par":850.0}`, map[string]float64{"Light_Par": 850}},
+		{"scale 0.1", `{"drip_weight":12345}`, map[string]float64{"Drip_Weight": 1234.5}},
+		{"scale without float error", `{"drip_weight":3}`, map[string]float64{"Drip_Weight": 0.3}},
+		{"negative scaled value", `{"drip_weight":-7}`, map[string]float64{"Drip_Weight": -0.7}},
+		{"rounded to whole numbers", `{"co2_ppm":412.5}`, map[string]float64{"Co2": 413}},
+		{"unrounded without a precision", `{"Air_Temp":23.456789}`, map[string]float64{"Air_Temp": 23.456789}},
+		{"state sensor", `{"Rain":1,"Air_Temp":20}`, map[string]float64{"Rain": 1, "Air_Temp": 20}},
+		{"unknown fields are ignored", `{"Air_Temp":20,"Soil_Ph":6.5,"firmware":"1.2.0","extra":{"a":1}}`, map[string]float64{"Air_Temp": 20}},
+		{"JSON key differs from the name", `{"Drip_Weight":100,"drip_weight":100}`, map[string]float64{"Drip_Weight": 10}},
+		{"null readings are absent", `{"Air_Temp":null,"Air_Rh":55}`, map[string]float64{"Air_Rh": 55}},
+	}
+	registry := parseTestRegistry()
+	for _, tt := range tests {
+		data, err := registry.ParseSensorData([]byte(tt.payload), nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(data.Values, tt.want) {
+			t.Errorf("%s: values = %v, want %v", tt.name, data.Values, tt.want)
+		}
+	}
+}
+
+func TestParseSensorDataRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		sensor  string
+	}{
+		{"fraction for an int sensor", `{"Light_Par":850.5}`, "Light_Par"},
+		{"fraction before scaling", `{"drip_weight":12.5}`, "Drip_Weight"},
+		{"fraction for a state sensor", `{"Rain":0.5}`, "Rain"},
+		{"string", `{"Air_Temp":"23.7"}`, "Air_Temp"},
+		{"boolean state", `{"Rain":true}`, "Rain"},
+	}
+	registry := parseTestRegistry()
+	for _, tt := range tests {
+		_, err := registry.ParseSensorData([]byte(tt.payload), nil)
+		var rejection *RejectionError
+		if !errors.As(err, &rejection) || rejection.Rejection.Reason != models.RejectInvalidValue || rejection.Rejection.Sensor != tt.sensor {
+			t.Errorf("%s: expected an %s rejection of %s, got %v", tt.name, models.RejectInvalidValue, tt.sensor, err)
+		}
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	registry := parseTestRegistry()
+	tests := []struct {
+		sensor string
+		value  float64
+		want   interface{}
+	}{
+		{"Light_Par", 850, int64(850)},  // Unscaled int sensors keep integer fields
+		{"Rain", 1, int64(1)},           // So do state sensors
+		{"Drip_Weight", 1234.5, 1234.5}, // Scaled int sensors are floats
+		{"Air_Temp", 23.7, 23.7},        // Float sensors
+		{"Unknown", 2.5, 2.5},           // Unregistered names are stored as given
+	}
+	for _, tt := range tests {
+		if got := registry.FieldValue(tt.sensor, tt.value); got != tt.want {
+			t.Errorf("FieldValue(%s, %v) = %T %v, want %T %v", tt.sensor, tt.value, got, got, tt.want, tt.want)
+		}
+	}
+}
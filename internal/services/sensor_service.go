@@ -3,7 +3,10 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"iot-agriculture-backend/internal/config"
 	"iot-agriculture-backend/internal/models"
@@ -12,17 +15,44 @@ import (
 // SensorService handles sensor data processing
 type SensorService struct {
 	averagingService *AveragingService
+	rollupService    *RollupService
+	alertService     *AlertService
+	notifications    *NotificationService
+	nodeRegistry     *NodeRegistry
+	quarantine       *QuarantineLog
+	deadLetters      *DeadLetterStore
+	light            *LightService
+	irrigation       *IrrigationService
+	substrate        *SubstrateService
+	rain             *RainService
+	crops            *CropService
 	influxService    *InfluxDBService
 	metricsService   *MetricsService
+	sensorRegistry   *SensorRegistry
 	config           *config.Config
 }
 
 // NewSensorService creates a new sensor service
 func NewSensorService(cfg *config.Config) *SensorService {
+	registry := NewSensorRegistry(&cfg.Sensors)
+	metrics := NewMetricsService()
+	influx := NewInfluxDBService(&cfg.InfluxDB, registry, metrics)
 	return &SensorService{
-		averagingService: NewAveragingService(),
-		influxService:    NewInfluxDBService(&cfg.InfluxDB),
-		metricsService:   NewMetricsService(),
+		averagingService: NewAveragingService(&cfg.Averaging, registry),
+		rollupService:    NewRollupService(&cfg.Averaging),
+		alertService:     NewAlertService(&cfg.Alerts, registry),
+		notifications:    NewNotificationService(&cfg.Notifications, metrics),
+		nodeRegistry:     NewNodeRegistry(&cfg.Nodes, registry, metrics),
+		quarantine:       NewQuarantineLog(cfg.MQTT.QuarantineLog),
+		deadLetters:      NewDeadLetterStore(cfg.Ingest.DeadLetterFile, cfg.Ingest.DeadLetterSize, metrics),
+		light:            NewLightService(&cfg.Light, metrics),
+		irrigation:       NewIrrigationService(&cfg.Irrigation, metrics),
+		substrate:        NewSubstrateService(&cfg.Substrate, registry),
+		rain:             NewRainService(&cfg.Rain, metrics),
+		crops:            NewCropService(&cfg.Crops, influx),
+		influxService:    influx,
+		metricsService:   metrics,
+		sensorRegistry:   registry,
 		config:           cfg,
 	}
 }
@@ -35,25 +65,216 @@ func (s *SensorService) ProcessSensorData(ctx context.Context, topic string, pay
 	// Remove per-message logging
 	// fmt.Printf("MQTT: Received sensor data from %s\n", topic)
 
-	var data models.ESP32SensorData
-	if err := json.Unmarshal(payload, &data); err != nil {
-		fmt.Printf("Error parsing JSON: %v\n", err)
-		fmt.Printf("Raw payload: %s\n", string(payload))
+	now := time.Now()
+	data, _, rejections := s.ingest(topic, payload, now, true)
+	if len(rejections) == 0 {
 		return
 	}
+	fmt.Printf("Rejecting message on %s: %s\n", topic, describeRejections(rejections))
+	s.deadLetters.Add(newDeadLetter(topic, payload, data, rejections, now))
+}
+
+// ingest runs a data message received at arrival through parsing, validation and
+// averaging. Live messages also count as a sign of life from the node. Returns the
+// parsed data, whether its window was already closed, and the rejection reasons
+// if the message was rejected
+func (s *SensorService) ingest(topic string, payload []byte, arrival time.Time, live bool) (models.ESP32SensorData, bool, []models.Rejection) {
+	data, err := s.sensorRegistry.ParseSensorData(payload, s.identityResolver(topic, payload))
+	if err != nil {
+		return data, false, s.rejected([]models.Rejection{rejectionFor(err)})
+	}
+
+	// Record node liveness (late and invalid readings included: the node is still publishing)
+	if live {
+		s.nodeRegistry.Observe(data.GreenhouseID, data.NodeID, arrival)
+	}
+
+	if rejections := s.sensorRegistry.Validate(data); len(rejections) > 0 {
+		return data, false, s.rejected(rejections)
+	}
+	s.metricsService.IncrementSensorZeroValues(countZeroValues(data.Values))
 
 	// Add to averaging service
-	s.averagingService.AddSensorData(data)
+	eventTime, accepted := s.averagingService.AddSensorData(data, arrival)
+
+	// Persist the individual reading for nodes with raw persistence enabled (late readings included)
+	s.influxService.LogRawReading(data, eventTime)
+
+	if !accepted {
+		fmt.Printf("Dropping late reading from %s/%s: window already closed\n", data.GreenhouseID, data.NodeID)
+		s.metricsService.IncrementSensorLateReadings()
+		return data, true, nil
+	}
+
+	// Integrate PAR into the node's daily light integral and look for irrigations and rain
+	s.light.Add(data, eventTime)
+	s.irrigation.Add(data, eventTime)
+	s.rain.Add(data, eventTime)
 
 	// Increment sensor readings metric
 	s.metricsService.IncrementSensorReadings()
+	return data, false, nil
+}
+
+// rejected counts each distinct rejection reason and returns the rejections
+func (s *SensorService) rejected(rejections []models.Rejection) []models.Rejection {
+	counted := make(map[string]bool, len(rejections))
+	for _, r := range rejections {
+		if !counted[r.Reason] {
+			counted[r.Reason] = true
+			s.metricsService.IncrementSensorRejections(r.Reason)
+		}
+	}
+	return rejections
+}
+
+// ProcessStatusMessage handles an online/offline message from a status (LWT) topic:
+// greenhouse/{greenhouse_id}/node/{node_id}/status for nodes, or {device}/status for
+// devices such as the ESP32 simulator. Transitions are stored in node_status
+func (s *SensorService) ProcessStatusMessage(ctx context.Context, topic string, payload []byte) {
+	status, err := parseConnectionStatus(payload)
+	if err != nil {
+		fmt.Printf("Ignoring status message on %s: %v\n", topic, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	event := models.NodeStatusEvent{Status: status, Timestamp: now}
+	var changed bool
+	parts := strings.Split(topic, "/")
+	if greenhouseID, nodeID, ok := parseNodeTopic(topic); ok {
+		event.GreenhouseID, event.NodeID = greenhouseID, nodeID
+		changed = s.nodeRegistry.SetConnection(greenhouseID, nodeID, status, now)
+	} else if len(parts) == 2 && parts[1] == "status" {
+		event.Device = parts[0]
+		if ValidateIdentifier("device", event.Device) != nil {
+			fmt.Printf("Ignoring status message on %s: invalid device name\n", topic)
+			return
+		}
+		changed = s.nodeRegistry.SetDeviceConnection(event.Device, status, now)
+	} else {
+		fmt.Printf("Ignoring status message on unexpected topic %s\n", topic)
+		return
+	}
+
+	if changed {
+		if err := s.influxService.LogNodeStatus(event); err != nil {
+			fmt.Printf("Warning: Failed to log node status %s: %v\n", describeStatusEvent(event), err)
+		}
+	}
+}
+
+// ProcessTelemetry handles a node diagnostics message on
+// greenhouse/{greenhouse_id}/node/{node_id}/{suffix}, e.g. {"rssi":-67,"free_heap":21344},
+// storing its numeric fields in the node_telemetry measurement
+func (s *SensorService) ProcessTelemetry(ctx context.Context, topic string, payload []byte) {
+	greenhouseID, nodeID, ok := parseNodeTopic(topic)
+	if !ok {
+		fmt.Printf("Ignoring telemetry on unexpected topic %s\n", topic)
+		return
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		fmt.Printf("Ignoring telemetry from %s/%s: %v\n", greenhouseID, nodeID, err)
+		return
+	}
+	fields := make(map[string]float64, len(raw))
+	for key, value := range raw {
+		if v, ok := value.(float64); ok && ValidateIdentifier("field", key) == nil {
+			fields[key] = v
+		}
+	}
+	if len(fields) == 0 {
+		return
+	}
+	if err := s.influxService.LogNodeTelemetry(greenhouseID, nodeID, fields, time.Now().UTC()); err != nil {
+		fmt.Printf("Warning: Failed to log telemetry of %s/%s: %v\n", greenhouseID, nodeID, err)
+	}
+}
+
+// ProcessAck handles a command acknowledgement on greenhouse/{greenhouse_id}/node/{node_id}/{suffix}
+// Commands are not issued by the backend yet, so acknowledgements are only logged
+func (s *SensorService) ProcessAck(ctx context.Context, topic string, payload []byte) {
+	greenhouseID, nodeID, ok := parseNodeTopic(topic)
+	if !ok {
+		fmt.Printf("Ignoring ack on unexpected topic %s\n", topic)
+		return
+	}
+	if len(payload) > 256 {
+		payload = payload[:256]
+	}
+	fmt.Printf("Ack from %s/%s: %s\n", greenhouseID, nodeID, payload)
+}
+
+// parseNodeTopic extracts the IDs from a greenhouse/{greenhouse_id}/node/{node_id}/{suffix} topic
+func parseNodeTopic(topic string) (string, string, bool) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 5 || parts[0] != "greenhouse" || parts[2] != "node" {
+		return "", "", false
+	}
+	if ValidateIdentifier("greenhouse_id", parts[1]) != nil || ValidateIdentifier("node_id", parts[3]) != nil {
+		return "", "", false
+	}
+	return parts[1], parts[3], true
+}
+
+// parseConnectionStatus reads "online"/"offline", as plain text or {"status": "..."}
+func parseConnectionStatus(payload []byte) (string, error) {
+	value := strings.TrimSpace(string(payload))
+	if strings.HasPrefix(value, "{") {
+		var msg struct {
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return "", fmt.Errorf("invalid JSON: %w", err)
+		}
+		value = msg.Status
+	}
+	switch status := strings.ToLower(strings.TrimSpace(value)); status {
+	case models.ConnectionOnline, models.ConnectionOffline:
+		return status, nil
+	default:
+		return "", fmt.Errorf("unknown status %q (expected online or offline)", value)
+	}
 }
 
-// CalculateAndDisplayAverages delegates to the averaging service with InfluxDB logging
+// CalculateAndDisplayAverages delegates to the averaging service with InfluxDB logging,
+// evaluates alert rules and sends notifications, feeds the flushed windows into the
+// 15-minute, hourly and daily rollups and the substrate profiles, records completed
+// daily light integrals, irrigations and rain events, then checks for nodes that
+// stopped publishing
 func (s *SensorService) CalculateAndDisplayAverages() {
-	s.averagingService.CalculateAndDisplayAveragesWithLogging(s.influxService, s.metricsService)
-	// Increment sensor averages metric
-	s.metricsService.IncrementSensorAverages()
+	results := s.averagingService.CalculateAndDisplayAveragesWithLogging(s.influxService, s.metricsService)
+	for _, result := range results {
+		// Increment sensor averages metric once per flushed window
+		s.metricsService.IncrementSensorAverages()
+		s.notifications.Notify(s.alertService.Evaluate(result))
+		s.rollupService.Add(result)
+		s.substrate.Add(result)
+	}
+	s.rollupService.Flush(s.influxService, s.metricsService)
+	s.recordDailyLight(time.Now())
+	s.recordIrrigations(time.Now())
+	s.recordRain(time.Now())
+	s.metricsService.SetInfluxDBWALStats(s.influxService.WALStats())
+	s.nodeRegistry.Check(time.Now())
+}
+
+// recordDailyLight writes the days completed by now to InfluxDB
+// Days are acknowledged once written or queued on disk; the others are kept for the next flush
+func (s *SensorService) recordDailyLight(now time.Time) {
+	var recorded []models.DailyLightIntegral
+	for _, day := range s.light.Rollover(now) {
+		fmt.Printf("🌞 Daily light integral %s\n", describeDLI(day))
+		err := s.influxService.LogDailyLightIntegral(day, s.config.Light.Location)
+		if err != nil {
+			fmt.Printf("Warning: Failed to log DLI of %s/%s: %v\n", day.GreenhouseID, day.NodeID, err)
+		}
+		if err == nil || errors.Is(err, ErrPointQueued) {
+			recorded = append(recorded, day)
+		}
+	}
+	s.light.Ack(recorded)
 }
 
 // GetInfluxDBService returns the InfluxDB service for external access
@@ -71,9 +292,92 @@ func (s *SensorService) GetMetricsService() *MetricsService {
 	return s.metricsService
 }
 
+// GetAlertService returns the alert service for external access
+func (s *SensorService) GetAlertService() *AlertService {
+	return s.alertService
+}
+
+// GetNotificationService returns the alert notification service for external access
+func (s *SensorService) GetNotificationService() *NotificationService {
+	return s.notifications
+}
+
+// GetNodeRegistry returns the node liveness registry for external access
+func (s *SensorService) GetNodeRegistry() *NodeRegistry {
+	return s.nodeRegistry
+}
+
+// GetDeadLetterStore returns the store of rejected data messages for external access
+func (s *SensorService) GetDeadLetterStore() *DeadLetterStore {
+	return s.deadLetters
+}
+
+// recordIrrigations writes the irrigations completed by now to InfluxDB
+func (s *SensorService) recordIrrigations(now time.Time) {
+	for _, event := range s.irrigation.Flush(now) {
+		fmt.Printf("💧 Irrigation %s\n", describeIrrigation(event))
+		if err := s.influxService.LogIrrigationEvent(event); err != nil {
+			fmt.Printf("Warning: Failed to log irrigation of %s/%s: %v\n", event.GreenhouseID, event.NodeID, err)
+		}
+	}
+}
+
+// GetIrrigationService returns the irrigation detection service for external access
+func (s *SensorService) GetIrrigationService() *IrrigationService {
+	return s.irrigation
+}
+
+// recordRain reports the rain events started by now and writes the completed ones to InfluxDB
+func (s *SensorService) recordRain(now time.Time) {
+	started, completed := s.rain.Flush(now)
+	for _, event := range started {
+		fmt.Printf("🌧️ Rain started %s\n", describeRain(event))
+	}
+	for _, event := range completed {
+		fmt.Printf("🌤️ Rain ended %s\n", describeRain(event))
+		if err := s.influxService.LogRainEvent(event); err != nil {
+			fmt.Printf("Warning: Failed to log rain event of %s/%s: %v\n", event.GreenhouseID, event.NodeID, err)
+		}
+	}
+}
+
+// GetRainService returns the rain detection service for external access
+func (s *SensorService) GetRainService() *RainService {
+	return s.rain
+}
+
+// GetCropService returns the crop planting and GDD service for external access
+func (s *SensorService) GetCropService() *CropService {
+	return s.crops
+}
+
+// GetSubstrateService returns the substrate profile service for external access
+func (s *SensorService) GetSubstrateService() *SubstrateService {
+	return s.substrate
+}
+
+// GetLightService returns the daily light integral service for external access
+func (s *SensorService) GetLightService() *LightService {
+	return s.light
+}
+
+// GetSensorRegistry returns the sensor registry for external access
+func (s *SensorService) GetSensorRegistry() *SensorRegistry {
+	return s.sensorRegistry
+}
+
 // Close closes all services
 func (s *SensorService) Close() {
+	if s.notifications != nil {
+		s.notifications.Close()
+	}
 	if s.influxService != nil {
 		s.influxService.Close()
 	}
+	if s.quarantine != nil {
+		s.quarantine.Close()
+	}
+	if s.light != nil {
+		s.light.Close()
+	}
 }
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+)
+
+func TestRecordDailyLightQueuesDayOnceDuringOutage(t *testing.T) {
+	wal, err := NewDiskQueue(t.TempDir(), 1<<20, 1<<16)
+	if err != nil {
+		t.Fatalf("NewDiskQueue: %v", err)
+	}
+	defer wal.Close()
+
+	// Not connected: every write goes to the WAL
+	influx := &InfluxDBService{status: ConnectionConnecting, wal: wal}
+	lightCfg := config.LightConfig{Sensor: "Light_Par", Location: time.UTC}
+	light := &LightService{
+		cfg:    &lightCfg,
+		states: make(map[string]*lightState),
+		completed: []models.DailyLightIntegral{
+			{GreenhouseID: "GH1", NodeID: "Node05", Date: "2024-06-01", DLI: 14.2, Readings: 1440},
+		},
+	}
+	s := &SensorService{light: light, influxService: influx, config: &config.Config{Light: lightCfg}}
+
+	now := time.Date(2024, 6, 2, 0, 1, 0, 0, time.UTC)
+	for flush := 0; flush < 3; flush++ {
+		s.recordDailyLight(now.Add(time.Duration(flush) * time.Minute))
+	}
+
+	if points := wal.Stats().Points; points != 1 {
+		t.Errorf("expected the day to be queued once, WAL holds %d points", points)
+	}
+	if len(light.completed) != 0 {
+		t.Errorf("queued day was requeued for the next flush: %+v", light.completed)
+	}
+}
+
+func TestQueuePointWrapsErrPointQueued(t *testing.T) {
+	cause := errors.New("InfluxDB not connected")
+	point := influxdb2.NewPoint(DLIMeasurement, map[string]string{"node_id": "Node05"}, map[string]interface{}{"dli": 14.2}, time.Now())
+
+	if err := (&InfluxDBService{}).queuePoint(point, cause); errors.Is(err, ErrPointQueued) {
+		t.Errorf("point reported as queued without a WAL: %v", err)
+	}
+
+	wal, err := NewDiskQueue(t.TempDir(), 1<<20, 1<<16)
+	if err != nil {
+		t.Fatalf("NewDiskQueue: %v", err)
+	}
+	defer wal.Close()
+	err = (&InfluxDBService{wal: wal}).queuePoint(point, cause)
+	if !errors.Is(err, ErrPointQueued) || !errors.Is(err, cause) {
+		t.Errorf("expected an error wrapping the cause and ErrPointQueued, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+// substratePrecision is the number of decimals substrate profile values are reported with
+const substratePrecision = 2
+
+// minDeviationProbes is the number of probes needed to tell which one deviates
+const minDeviationProbes = 3
+
+// substrateWindow holds the probe means of one averaging window
+type substrateWindow struct {
+	end    time.Time
+	probes map[string]float64
+}
+
+// SubstrateService keeps the recent probe means of each node's substrate bag and
+// derives a moisture profile: mean, spread, deviating probes and dry-down rate
+type SubstrateService struct {
+	mu      sync.RWMutex
+	cfg     *config.SubstrateConfig
+	unit    string
+	windows map[string][]substrateWindow // key: greenhouse_id|node_id, oldest first
+}
+
+// NewSubstrateService creates a new substrate profile service
+func NewSubstrateService(cfg *config.SubstrateConfig, registry *SensorRegistry) *SubstrateService {
+	s := &SubstrateService{
+		cfg:     cfg,
+		windows: make(map[string][]substrateWindow),
+	}
+	if len(cfg.Probes) > 0 {
+		if sensor, ok := registry.Lookup(cfg.Probes[0]); ok {
+			s.unit = sensor.Unit
+		}
+	}
+	return s
+}
+
+// Enabled returns true if substrate probes are configured
+func (s *SubstrateService) Enabled() bool {
+	return len(s.cfg.Probes) > 0
+}
+
+// MaxWindows returns the number of windows kept per node
+func (s *SubstrateService) MaxWindows() int {
+	return s.cfg.Windows
+}
+
+// Add records the probe means of a flushed window
+// Windows without probe readings, or older than the node's latest window, are ignored
+func (s *SubstrateService) Add(result models.AverageResult) {
+	if !s.Enabled() {
+		return
+	}
+	probes := make(map[string]float64, len(s.cfg.Probes))
+	for _, probe := range s.cfg.Probes {
+		if stats, ok := result.Stats[probe]; ok && stats.Count > 0 {
+			probes[probe] = stats.Mean
+		}
+	}
+	if len(probes) == 0 {
+		return
+	}
+
+	key := result.GreenhouseID + "|" + result.NodeID
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	windows := s.windows[key]
+	if n := len(windows); n > 0 && !result.WindowEnd.After(windows[n-1].end) {
+		return
+	}
+	windows = append(windows, substrateWindow{end: result.WindowEnd, probes: probes})
+	if len(windows) > s.cfg.Windows {
+		windows = windows[len(windows)-s.cfg.Windows:]
+	}
+	s.windows[key] = windows
+}
+
+// Profile returns the substrate profile of a node, with the dry-down rate fitted
+// over its last n windows (0 = every kept window). Returns false if the node has
+// no windows with probe readings
+func (s *SubstrateService) Profile(greenhouseID, nodeID string, n int) (models.SubstrateProfile, bool) {
+	s.mu.RLock()
+	windows := s.windows[greenhouseID+"|"+nodeID]
+	s.mu.RUnlock()
+	if len(windows) == 0 {
+		return models.SubstrateProfile{}, false
+	}
+	if n > 0 && n < len(windows) {
+		windows = windows[len(windows)-n:]
+	}
+
+	precision := substratePrecision
+	latest := windows[len(windows)-1]
+	values := make([]float64, 0, len(latest.probes))
+	for _, value := range latest.probes {
+		values = append(values, value)
+	}
+	stats := calculateStats(values)
+	profile := models.SubstrateProfile{
+		GreenhouseID: greenhouseID,
+		NodeID:       nodeID,
+		Unit:         s.unit,
+		WindowEnd:    latest.end,
+		Probes:       latest.probes,
+		Mean:         roundTo(stats.Mean, &precision),
+		Spread:       roundTo(stats.Max-stats.Min, &precision),
+		StdDev:       roundTo(stats.StdDev, &precision),
+		Deviating:    s.deviating(latest.probes),
+		Windows:      len(windows),
+	}
+	if rate, ok := dryDownRate(windows); ok {
+		rate = roundTo(rate, &precision)
+		profile.DryDownRate = &rate
+	}
+	return profile, true
+}
+
+// deviating returns the probes further than the deviation threshold from the
+// median of the other probes, in configuration order
+func (s *SubstrateService) deviating(probes map[string]float64) []models.ProbeDeviation {
+	deviating := make([]models.ProbeDeviation, 0)
+	if len(probes) < minDeviationProbes {
+		return deviating
+	}
+	precision := substratePrecision
+	for _, probe := range s.cfg.Probes {
+		value, ok := probes[probe]
+		if !ok {
+			continue
+		}
+		others := make([]float64, 0, len(probes)-1)
+		for name, v := range probes {
+			if name != probe {
+				others = append(others, v)
+			}
+		}
+		if deviation := value - median(others); math.Abs(deviation) > s.cfg.Deviation {
+			deviating = append(deviating, models.ProbeDeviation{
+				Sensor:    probe,
+				Value:     value,
+				Deviation: roundTo(deviation, &precision),
+			})
+		}
+	}
+	return deviating
+}
+
+// dryDownRate fits a least-squares line through the probe mean of each window
+// and returns the loss per hour. Returns false with fewer than 2 windows
+func dryDownRate(windows []substrateWindow) (float64, bool) {
+	if len(windows) < 2 {
+		return 0, false
+	}
+	origin := windows[0].end
+	var sumX, sumY, sumXY, sumXX float64
+	for _, w := range windows {
+		values := make([]float64, 0, len(w.probes))
+		for _, v := range w.probes {
+			values = append(values, v)
+		}
+		x := w.end.Sub(origin).Hours()
+		y := calculateStats(values).Mean
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(windows))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	return -slope, true
+}
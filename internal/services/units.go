@@ -0,0 +1,207 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"iot-agriculture-backend/internal/models"
+)
+
+// Unit systems accepted by ParseUnitSelection
+const (
+	UnitSystemMetric   = "metric"   // The canonical units of the sensor registry
+	UnitSystemImperial = "imperial" // °F, oz and in where a sensor has a metric unit
+)
+
+// unitConversion converts a value from a canonical unit: value*factor + offset
+type unitConversion struct {
+	unit   string
+	factor float64
+	offset float64
+}
+
+// precision returns the number of decimals converted values keep, given the
+// canonical unit's precision: a larger unit gets one more decimal per power of
+// ten it is larger by, so 1234 g is reported as 1.234 kg rather than 1 kg
+func (c unitConversion) precision(canonical *int) *int {
+	if canonical == nil {
+		return nil
+	}
+	extra := int(math.Max(0, math.Ceil(math.Log10(1/c.factor)-1e-9)))
+	precision := *canonical + extra
+	return &precision
+}
+
+// unitConversions lists the units each canonical unit converts to
+var unitConversions = map[string][]unitConversion{
+	"°C": {{unit: "°F", factor: 1.8, offset: 32}, {unit: "K", factor: 1, offset: 273.15}},
+	"g":  {{unit: "oz", factor: 1 / 28.349523125}, {unit: "lb", factor: 1 / 453.59237}, {unit: "kg", factor: 0.001}},
+	"mm": {{unit: "in", factor: 1 / 25.4}, {unit: "cm", factor: 0.1}},
+}
+
+// imperialUnits maps canonical units to their imperial counterpart
+var imperialUnits = map[string]string{
+	"°C": "°F",
+	"g":  "oz",
+	"mm": "in",
+}
+
+// unitAliases maps ASCII spellings accepted in query strings to unit symbols
+var unitAliases = map[string]string{
+	"C":    "°C",
+	"degC": "°C",
+	"F":    "°F",
+	"degF": "°F",
+}
+
+// UnitSelection holds the unit each sensor's and derived metric's values are reported in
+// Names without an entry are reported in their canonical unit
+type UnitSelection struct {
+	registry    *SensorRegistry
+	conversions map[string]unitConversion // key: sensor or derived metric name
+}
+
+// ParseUnitSelection parses a units query value: a unit system ("metric" or
+// "imperial") and/or per-sensor units such as "Air_Temp:°F" or "dew_point:F",
+// comma-separated. Per-sensor units override the system, e.g. "imperial,drip_weight:kg"
+func ParseUnitSelection(value string, registry *SensorRegistry) (*UnitSelection, error) {
+	u := &UnitSelection{registry: registry, conversions: make(map[string]unitConversion)}
+	overrides := make(map[string]string)
+	for _, token := range strings.Split(value, ",") {
+		token = strings.TrimSpace(token)
+		switch {
+		case token == "" || token == UnitSystemMetric:
+		case token == UnitSystemImperial:
+			for _, s := range registry.Sensors() {
+				if target, ok := imperialUnits[s.Unit]; ok {
+					conversion, _ := findConversion(s.Unit, target)
+					u.conversions[s.Name] = conversion
+				}
+			}
+			for _, m := range derivedMetrics {
+				if target, ok := imperialUnits[m.unit]; ok {
+					conversion, _ := findConversion(m.unit, target)
+					u.conversions[m.name] = conversion
+				}
+			}
+		default:
+			name, unit, ok := strings.Cut(token, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid units: %s (expected %s, %s or sensor:unit)", token, UnitSystemMetric, UnitSystemImperial)
+			}
+			if _, _, ok := u.canonical(name); !ok {
+				return nil, fmt.Errorf("invalid sensor in units: %s", name)
+			}
+			overrides[name] = unit
+		}
+	}
+
+	for name, unit := range overrides {
+		canonical, _, _ := u.canonical(name)
+		if alias, ok := unitAliases[unit]; ok {
+			unit = alias
+		}
+		if unit == canonical {
+			delete(u.conversions, name)
+			continue
+		}
+		conversion, ok := findConversion(canonical, unit)
+		if !ok {
+			return nil, fmt.Errorf("invalid unit for %s: %s (supported: %s)", name, unit, strings.Join(convertibleUnits(canonical), ", "))
+		}
+		u.conversions[name] = conversion
+	}
+	return u, nil
+}
+
+// findConversion returns the conversion from a canonical unit to another unit
+func findConversion(from, to string) (unitConversion, bool) {
+	for _, c := range unitConversions[from] {
+		if c.unit == to {
+			return c, true
+		}
+	}
+	return unitConversion{}, false
+}
+
+// convertibleUnits returns the units a canonical unit can be reported in, itself first
+func convertibleUnits(from string) []string {
+	units := make([]string, 0, len(unitConversions[from])+1)
+	for _, c := range unitConversions[from] {
+		units = append(units, c.unit)
+	}
+	sort.Strings(units)
+	return append([]string{from}, units...)
+}
+
+// canonical returns the canonical unit and precision of a sensor or derived metric
+func (u *UnitSelection) canonical(name string) (string, *int, bool) {
+	if sensor, ok := u.registry.Lookup(name); ok {
+		return sensor.Unit, sensor.Precision, true
+	}
+	if m, ok := lookupDerivedMetric(name); ok {
+		return m.unit, &m.precision, true
+	}
+	return "", nil, false
+}
+
+// Unit returns the unit a sensor's or derived metric's values are reported in
+func (u *UnitSelection) Unit(name string) string {
+	if c, ok := u.conversions[name]; ok {
+		return c.unit
+	}
+	unit, _, _ := u.canonical(name)
+	return unit
+}
+
+// Units returns the reported unit of each named sensor
+func (u *UnitSelection) Units(names []string) map[string]string {
+	units := make(map[string]string, len(names))
+	for _, name := range names {
+		units[name] = u.Unit(name)
+	}
+	return units
+}
+
+// Value converts a sensor or derived metric value from its canonical unit, keeping its precision
+// in the target unit
+// Derived temperature differences (leaf_air_delta) only get the factor applied
+func (u *UnitSelection) Value(name string, v float64) float64 {
+	c, ok := u.conversions[name]
+	if !ok {
+		return v
+	}
+	_, canonical, _ := u.canonical(name)
+	precision := c.precision(canonical)
+	if m, ok := lookupDerivedMetric(name); ok && m.difference {
+		return roundTo(v*c.factor, precision)
+	}
+	return roundTo(v*c.factor+c.offset, precision)
+}
+
+// Derived converts a window's derived metrics from their canonical units
+func (u *UnitSelection) Derived(derived map[string]float64) map[string]float64 {
+	out := make(map[string]float64, len(derived))
+	for name, value := range derived {
+		out[name] = u.Value(name, value)
+	}
+	return out
+}
+
+// Stats converts a sensor's window aggregates from its canonical unit
+// The standard deviation is a difference, so only the factor applies to it
+func (u *UnitSelection) Stats(name string, stats models.SensorStats) models.SensorStats {
+	c, ok := u.conversions[name]
+	if !ok {
+		return stats
+	}
+	_, canonical, _ := u.canonical(name)
+	stats.Mean = u.Value(name, stats.Mean)
+	stats.Min = u.Value(name, stats.Min)
+	stats.Max = u.Value(name, stats.Max)
+	stats.Median = u.Value(name, stats.Median)
+	stats.StdDev = roundTo(stats.StdDev*c.factor, c.precision(canonical))
+	return stats
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+
+	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/models"
+)
+
+func unitsTestRegistry() *SensorRegistry {
+	one, two := 1, 2
+	return NewSensorRegistry(&config.SensorsConfig{Sensors: []config.SensorDefinition{
+		{Name: "Air_Temp", JSONKey: "Air_Temp", Unit: "°C", Type: config.SensorTypeFloat, Precision: &two},
+		{Name: "Drip_Weight", JSONKey: "Drip_Weight", Unit: "g", Type: config.SensorTypeFloat, Precision: &one},
+		{Name: "Rain_Level", JSONKey: "Rain_Level", Unit: "mm", Type: config.SensorTypeFloat, Precision: &one},
+	}})
+}
+
+func TestUnitSelectionValue(t *testing.T) {
+	tests := []struct {
+		units string
+		name  string
+		in    float64
+		want  float64
+	}{
+		{"Air_Temp:°F", "Air_Temp", 25, 77},
+		{"Air_Temp:F", "Air_Temp", -40, -40},
+		{"Air_Temp:K", "Air_Temp", 25, 298.15},
+		{"imperial", "Air_Temp", 21.37, 70.47},
+		{"Drip_Weight:kg", "Drip_Weight", 1234, 1.234},
+		{"Drip_Weight:kg", "Drip_Weight", 0.5, 0.0005},
+		{"Drip_Weight:oz", "Drip_Weight", 1000, 35.274},
+		{"Rain_Level:in", "Rain_Level", 12.7, 0.5},
+		{"Rain_Level:cm", "Rain_Level", 12.3, 1.23},
+		{"Drip_Weight:g", "Drip_Weight", 1234.5, 1234.5},
+		{"dew_point:°F", DerivedDewPoint, 10, 50},
+		// A temperature difference only gets the factor, not the offset
+		{"leaf_air_delta:°F", DerivedLeafAirDelta, 2, 3.6},
+		{"leaf_air_delta:K", DerivedLeafAirDelta, -1.5, -1.5},
+		{"imperial", DerivedLeafAirDelta, 2, 3.6},
+	}
+	registry := unitsTestRegistry()
+	for _, tt := range tests {
+		units, err := ParseUnitSelection(tt.units, registry)
+		if err != nil {
+			t.Fatalf("ParseUnitSelection(%q): %v", tt.units, err)
+		}
+		if got := units.Value(tt.name, tt.in); got != tt.want {
+			t.Errorf("%s: %s %v = %v, want %v", tt.units, tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUnitSelectionStats(t *testing.T) {
+	units, err := ParseUnitSelection("Air_Temp:°F,Drip_Weight:kg", unitsTestRegistry())
+	if err != nil {
+		t.Fatalf("ParseUnitSelection: %v", err)
+	}
+
+	got := units.Stats("Air_Temp", models.SensorStats{Mean: 20, Min: 10, Max: 30, Median: 20, StdDev: 5, Count: 4})
+	want := models.SensorStats{Mean: 68, Min: 50, Max: 86, Median: 68, StdDev: 9, Count: 4}
+	if got != want {
+		t.Errorf("Air_Temp stats in °F = %+v, want %+v", got, want)
+	}
+	got = units.Stats("Drip_Weight", models.SensorStats{Mean: 1234.5, Min: 1000, Max: 1500, Median: 1200, StdDev: 12.3, Count: 4})
+	want = models.SensorStats{Mean: 1.2345, Min: 1, Max: 1.5, Median: 1.2, StdDev: 0.0123, Count: 4}
+	if got != want {
+		t.Errorf("Drip_Weight stats in kg = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseUnitSelectionErrors(t *testing.T) {
+	registry := unitsTestRegistry()
+	for _, value := range []string{
+		"Air_Temp:kg",  // Not a temperature unit
+		"Air_Temp:°R",  // Unknown unit
+		"Unknown:°F",   // Unknown sensor
+		"air_vpd:psi",  // No conversions for kPa
+		"Air_Temp",     // Missing unit
+		"customary",    // Unknown unit system
+		"Drip_Weight:", // Empty unit
+	} {
+		if _, err := ParseUnitSelection(value, registry); err == nil {
+			t.Errorf("ParseUnitSelection(%q): expected an error", value)
+		}
+	}
+}
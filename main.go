@@ -12,10 +12,19 @@ import (
 
 	"iot-agriculture-backend/internal/api"
 	"iot-agriculture-backend/internal/config"
+	"iot-agriculture-backend/internal/ingest"
 	"iot-agriculture-backend/internal/mqtt"
 	"iot-agriculture-backend/internal/services"
 )
 
+// controlMessage is a status, telemetry or ack message waiting for the control worker
+type controlMessage struct {
+	Topic   string
+	Payload []byte
+	Ctx     context.Context
+	Process mqtt.MessageHandler
+}
+
 func main() {
 	// Set GOMAXPROCS to number of CPU cores for best performance
 	runtime.GOMAXPROCS(runtime.NumCPU())
@@ -25,8 +34,8 @@ func main() {
 	log.Printf("Starting IoT Agriculture Backend with config: %s", cfg.MQTT.String())
 
 	// Create sensor service
+	// Closed explicitly on shutdown, after the queued messages are processed
 	sensorService := services.NewSensorService(cfg)
-	defer sensorService.Close()
 
 	// Log InfluxDB connection status
 	influxService := sensorService.GetInfluxDBService()
@@ -34,44 +43,49 @@ func main() {
 		log.Printf("InfluxDB Status: %s", influxService.GetConnectionInfo())
 	}
 
-	// Buffered channel for async MQTT processing
-	msgChan := make(chan struct {
-		Topic   string
-		Payload []byte
-		Ctx     context.Context
-	}, 1000) // Buffer size can be tuned
+	// Worker pool for sensor data, sharded by node so each node's messages stay in order
+	ingestPool := ingest.NewPool(&cfg.Ingest, sensorService.ProcessSensorData, sensorService.GetMetricsService())
 
-	// Worker goroutine for processing MQTT messages
+	// Status, telemetry and ack messages are rare but their order matters, so they
+	// share a worker of their own instead of waiting behind sensor data
+	controlChan := make(chan controlMessage, 100)
+	controlDone := make(chan struct{})
 	go func() {
-		for msg := range msgChan {
-			sensorService.ProcessSensorData(msg.Ctx, msg.Topic, msg.Payload)
+		defer close(controlDone)
+		for msg := range controlChan {
+			msg.Process(msg.Ctx, msg.Topic, msg.Payload)
 		}
 	}()
-
-	// MQTT handler pushes messages onto the channel
-	mqttHandler := func(ctx context.Context, topic string, payload []byte) {
-		select {
-		case msgChan <- struct {
-			Topic   string
-			Payload []byte
-			Ctx     context.Context
-		}{Topic: topic, Payload: payload, Ctx: ctx}:
-			// Enqueued successfully
-		default:
-			log.Printf("WARNING: MQTT message channel full, dropping message for topic %s", topic)
+	controlHandler := func(process mqtt.MessageHandler) mqtt.MessageHandler {
+		return func(ctx context.Context, topic string, payload []byte) {
+			select {
+			case controlChan <- controlMessage{Topic: topic, Payload: payload, Ctx: ctx, Process: process}:
+			default:
+				log.Printf("WARNING: MQTT control channel full, dropping message for topic %s", topic)
+			}
 		}
 	}
 
-	// Create MQTT client with async handler and metrics
-	mqttClient, err := mqtt.NewClient(&cfg.MQTT, mqttHandler, sensorService.GetMetricsService())
+	// Route each configured subscription to its handler
+	handlers := map[string]mqtt.MessageHandler{
+		config.HandlerData:      ingestPool.Submit,
+		config.HandlerStatus:    controlHandler(sensorService.ProcessStatusMessage),
+		config.HandlerTelemetry: controlHandler(sensorService.ProcessTelemetry),
+		config.HandlerAcks:      controlHandler(sensorService.ProcessAck),
+	}
+
+	// Create MQTT client with async handlers and metrics
+	mqttClient, err := mqtt.NewClient(&cfg.MQTT, handlers, sensorService.GetMetricsService())
 	if err != nil {
 		log.Fatalf("Failed to create MQTT client: %v", err)
 	}
-	defer mqttClient.Disconnect()
 
-	// Subscribe to MQTT topic
+	// Publish alert notifications of MQTT channels on the same connection
+	sensorService.GetNotificationService().SetPublisher(mqttClient)
+
+	// Subscribe to the configured MQTT topics
 	if err := mqttClient.Subscribe(); err != nil {
-		log.Fatalf("Failed to subscribe to MQTT topic: %v", err)
+		log.Fatalf("Failed to subscribe to MQTT topics: %v", err)
 	}
 
 	// Create rate limiter
@@ -89,8 +103,8 @@ func main() {
 		}
 	}()
 
-	// Start averaging timer
-	ticker := time.NewTicker(60 * time.Second)
+	// Start window flush timer (windows close at their end plus the allowed lateness)
+	ticker := time.NewTicker(cfg.Averaging.FlushInterval)
 	defer ticker.Stop()
 
 	// Setup graceful shutdown
@@ -102,14 +116,17 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	log.Println("IoT Agriculture Backend started. Press Ctrl+C to stop.")
-	log.Println("MQTT data processing and 60-second averaging enabled.")
+	log.Printf("MQTT data processing and %v event-time averaging enabled (allowed lateness %v).",
+		cfg.Averaging.Window, cfg.Averaging.AllowedLateness)
+	log.Printf("MQTT data processed by %d workers (queue %d each, %s when full).",
+		cfg.Ingest.Workers, cfg.Ingest.QueueSize, cfg.Ingest.Backpressure)
 	log.Println("API server enabled on port 8080.")
 
 	// Main event loop
 	for {
 		select {
 		case <-ticker.C:
-			// Calculate and display 60-second averages
+			// Calculate and display averages for closed windows
 			sensorService.CalculateAndDisplayAverages()
 
 		case <-sigChan:
@@ -122,14 +139,17 @@ func main() {
 			// Stop API server
 			apiServer.Stop()
 
-			// Close services (this will cancel the InfluxDB context)
-			sensorService.Close()
+			// Stop receiving messages before their queues are closed; a message
+			// routed to a closed queue would panic
+			mqttClient.Disconnect()
 
-			// Close MQTT message channel
-			close(msgChan)
+			// Process the queued sensor data before the services are closed
+			ingestPool.Close()
+			close(controlChan)
+			<-controlDone
 
-			// Small delay to ensure cleanup completes
-			time.Sleep(200 * time.Millisecond)
+			// Close services (this will cancel the InfluxDB context)
+			sensorService.Close()
 
 			log.Println("Shutdown completed")
 			return